@@ -8,7 +8,7 @@ DISPATCHER_URL="http://dispatcher:8080"
 # Verificar si la imagen existe
 if ! docker image inspect $IMAGE_NAME &> /dev/null; then
   echo "Construyendo imagen $IMAGE_NAME..."
-  docker build -t $IMAGE_NAME -f ./server/Dockerfile ./server
+  docker build -t $IMAGE_NAME -f ./server/Dockerfile .
 fi
 
 # Obtener el último número de worker, excluyendo contenedores detenidos
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"testing"
 	"github.com/stretchr/testify/mock"
 	"net"
+	"strings"
 	"time"
 )
 
@@ -52,58 +54,36 @@ func (m *MockConn) SetWriteDeadline(t time.Time) error {
 	return args.Error(0)
 }
 
-// Dispatcher y Worker simplificados para la prueba
-type Worker struct {
-	ID        int
-	URL       string
-	taskQueue chan *Task
-}
-
-type Dispatcher struct {
-	Workers []*Worker
-}
+// Prueba que el cálculo de Pi responda 400 cuando falta el parámetro 'iterations'
+func TestHandleCalculatePi(t *testing.T) {
+	mockConn := new(MockConn)
+	dispatcher := newDispatcher()
 
-type Task struct {
-	ID    int
-	Conn  net.Conn
-	Params map[string]string
-}
+	// Mocking Write para verificar la respuesta
+	mockConn.On("Write", mock.MatchedBy(func(p []byte) bool {
+		return strings.HasPrefix(string(p), "HTTP/1.0 400 Bad Request")
+	})).Return(0, nil).Once()
 
-func (d *Dispatcher) handleCalculatePi(conn net.Conn, method string, route string, params map[string]string) {
-	iterationsStr := params["iterations"]
-	if iterationsStr == "" {
-		conn.Write([]byte("400 Bad Request: Parámeter 'iterations' requerido"))
-		return
-	}
+	dispatcher.runMapReduce(context.Background(), mockConn, piJob, MapInput{Params: map[string]string{}})
 
-	conn.Write([]byte("200 OK: Cálculo de Pi iniciado"))
+	// Verificamos que la respuesta fue la esperada
+	mockConn.AssertExpectations(t)
 }
 
-// Prueba simple para verificar que la función handleCalculatePi responde correctamente
-func TestHandleCalculatePi(t *testing.T) {
+// Prueba que el cálculo de Pi responda 503 cuando no hay workers registrados
+func TestHandleCalculatePiSinWorkers(t *testing.T) {
 	mockConn := new(MockConn)
+	dispatcher := newDispatcher()
 
-	// Creamos un dispatcher con un worker para la prueba
-	worker := &Worker{
-		ID:        1,
-		URL:       "http://localhost",
-		taskQueue: make(chan *Task, 1),
-	}
-	dispatcher := &Dispatcher{
-		Workers: []*Worker{worker},
-	}
-
-	// Parámetros de prueba
 	params := map[string]string{
 		"iterations": "1000",
 	}
 
-	// Mocking Write para verificar la respuesta
-	mockConn.On("Write", mock.Anything).Return(0, nil)
+	mockConn.On("Write", mock.MatchedBy(func(p []byte) bool {
+		return strings.HasPrefix(string(p), "HTTP/1.0 503 Service Unavailable")
+	})).Return(0, nil).Once()
 
-	// Llamada a handleCalculatePi
-	dispatcher.handleCalculatePi(mockConn, "POST", "/calculatepi", params)
+	dispatcher.runMapReduce(context.Background(), mockConn, piJob, MapInput{Params: params})
 
-	// Verificamos que la respuesta fue la esperada
 	mockConn.AssertExpectations(t)
 }
@@ -1,7 +1,9 @@
 package main
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
+	"http-shared/httpmsg"
+	"http-shared/routes"
 	"log"
 	"net"
 	"strings"
@@ -9,54 +11,192 @@ import (
     "http-servidor/utils"
 )
 
-// revisa el estado del worker
-func (d *Dispatcher) checkWorkerStatus(w *Worker) bool {
-    conn, err := net.DialTimeout("tcp", w.URL, 5*time.Second)
-    if err != nil {
-        w.mu.Lock()
-        w.Status = false
-        w.mu.Unlock()
-		d.redistributeTasks(w)
-        return false
+// Estado de un worker según los heartbeats que envía
+type WorkerState int
+
+const (
+    WorkerAlive   WorkerState = iota // Heartbeats al día
+    WorkerSuspect                    // Perdió algunos heartbeats o falló una solicitud, solo recibe tareas si no hay otro
+    WorkerDead                       // Perdió demasiados heartbeats, no recibe tareas
+)
+
+const (
+    SuspectAfterMissed = 2 // Heartbeats perdidos para pasar a sospechoso
+    DeadAfterMissed    = 5 // Heartbeats perdidos para darlo por muerto
+)
+
+func (s WorkerState) String() string {
+    switch s {
+    case WorkerAlive:
+        return "alive"
+    case WorkerSuspect:
+        return "suspect"
+    default:
+        return "dead"
     }
-    defer conn.Close()
+}
 
-    // Enviar solicitud HTTP de verificación
-    _, err = fmt.Fprintf(conn, "GET /ping HTTP/1.1\r\nHost: %s\r\n\r\n", w.URL)
-    if err != nil {
-        w.mu.Lock()
-        w.Status = false
-        w.mu.Unlock()
-		d.redistributeTasks(w)
-        return false
+// setState cambia el estado del worker y retorna el anterior. Status sigue
+// indicando si el worker puede recibir tareas. Requiere w.mu tomado.
+func (w *Worker) setState(state WorkerState) WorkerState {
+    prev := w.State
+    w.State = state
+    w.Status = state != WorkerDead
+    if prev != state {
+        log.Printf("Worker %d (%s): %s -> %s", w.ID, w.URL, prev, state)
+    }
+    return prev
+}
+
+// revisa el estado del worker con GET /ping sobre una conexión del pool.
+// Solo se usa con los workers registrados sin capacidades, que no envían heartbeats.
+func (d *Dispatcher) checkWorkerStatus(w *Worker) bool {
+    resp, err := d.Pool.Do(w.URL, "GET", "/ping", nil, nil, 5*time.Second)
+    alive := err == nil && resp.StatusCode == 200
+
+    w.mu.Lock()
+    w.lastChecked = time.Now()
+    prev := WorkerDead
+    if alive {
+        w.setState(WorkerAlive)
+    } else {
+        prev = w.setState(WorkerDead)
     }
+    w.mu.Unlock()
 
-    // Verificar respuesta
-    scanner := bufio.NewScanner(conn)
-    if scanner.Scan() && strings.Contains(scanner.Text(), "200 OK") {
-        w.mu.Lock()
-        w.Status = true
-        w.mu.Unlock()
-        return true
+    if !alive && prev != WorkerDead {
+        d.Pool.CloseWorker(w.URL)
+        d.redistributeTasks(w)
     }
+    return alive
+}
 
+// checkHeartbeat calcula el estado del worker según los heartbeats que dejaron de llegar
+func (d *Dispatcher) checkHeartbeat(w *Worker, now time.Time) WorkerState {
     w.mu.Lock()
-    w.Status = false
+    missed := int(now.Sub(w.lastHeartbeat) / w.heartbeatInterval)
+    state := w.State
+    switch {
+    case missed >= DeadAfterMissed:
+        state = WorkerDead
+    case missed >= SuspectAfterMissed:
+        state = WorkerSuspect
+    }
+    prev := w.setState(state)
     w.mu.Unlock()
-    return false
+
+    if state == WorkerDead && prev != WorkerDead {
+        d.Pool.CloseWorker(w.URL)
+        d.redistributeTasks(w)
+        // Sus archivos pasan a otras réplicas
+        d.scheduleRepair()
+    }
+    return state
+}
+
+// reactivate deja disponible a un worker que se volvió a registrar: deja de drenarse,
+// pasa a vivo y se reinicia la cuenta de heartbeats perdidos. Retorna el estado anterior.
+func (w *Worker) reactivate() WorkerState {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.Draining = false
+    w.lastHeartbeat = time.Now()
+    w.lastChecked = w.lastHeartbeat
+    return w.setState(WorkerAlive)
+}
+
+// markSuspect se llama cuando falla una solicitud al worker. En lugar de probarlo
+// en ese momento se deja de preferir hasta que llegue su próximo heartbeat.
+func (d *Dispatcher) markSuspect(w *Worker) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.State == WorkerAlive {
+        w.setState(WorkerSuspect)
+    }
+    d.Pool.CloseWorker(w.URL)
 }
 
-// hace el health check
+// hace el health check. A los workers que envían heartbeats se les revisa cuántos
+// perdieron; solo a los que se registraron sin capacidades se les hace /ping.
 func (d *Dispatcher) HealthCheck() {
-    for _, worker := range d.Workers {
-        
-        d.checkWorkerStatus(worker)
-        
-		log.Printf("Worker %d (%s) estado: %t", worker.ID, worker.URL, worker.Status)
+    d.Mu.RLock()
+    list := d.Workers
+    d.Mu.RUnlock()
+
+    now := time.Now()
+    for _, worker := range list {
+        worker.mu.RLock()
+        legacy := worker.legacy
+        draining := worker.Draining
+        loaded := worker.routes != nil
+        worker.mu.RUnlock()
+
+        alive := false
+        if legacy {
+            alive = d.checkWorkerStatus(worker)
+        } else {
+            alive = d.checkHeartbeat(worker, now) != WorkerDead
+        }
+
+        if !alive {
+            // Un worker en drenaje que ya no responde terminó de apagarse
+            if draining {
+                d.removeWorker(worker)
+            }
+            continue
+        }
+        if !loaded {
+            go d.fetchRoutes(worker)
+        }
     }
-	
 }
 
+// Recibe el heartbeat de un worker con su carga. Un worker que el dispatcher no
+// conoce recibe 404 para que se vuelva a registrar; la respuesta lleva el epoch para
+// que el worker note que el dispatcher se reinició.
+func (d *Dispatcher) heartbeatHandler(conn net.Conn, req *httpmsg.Request) {
+    body, err := req.ReadBody()
+    if err != nil {
+        utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+        return
+    }
+    var hb routes.Heartbeat
+    if err := json.Unmarshal(body, &hb); err != nil {
+        utils.SendResponse(conn, "400 Bad Request", fmt.Sprintf("Heartbeat inválido: %v", err))
+        return
+    }
+
+    worker := d.findWorker(cleanURL(hb.URL))
+    if worker == nil {
+        utils.SendResponse(conn, "404 Not Found", "Worker no registrado")
+        return
+    }
+
+    worker.mu.Lock()
+    worker.legacy = false
+    worker.lastHeartbeat = time.Now()
+    worker.lastChecked = worker.lastHeartbeat
+    worker.heartbeatInterval = hb.Interval()
+    worker.Load = hb
+    if hb.Draining {
+        worker.Draining = true
+    }
+    prev := worker.setState(WorkerAlive)
+    worker.mu.Unlock()
+
+    if prev == WorkerDead {
+        log.Printf("Worker %d (%s) volvió a enviar heartbeats", worker.ID, worker.URL)
+        // Sus copias pueden haber quedado atrás mientras estuvo caído
+        d.scheduleRepair()
+    }
+    d.sendRegistration(conn, 0, "ok")
+}
+
+// cleanURL quita el esquema y la barra final de la URL de un worker
+func cleanURL(url string) string {
+    url = strings.TrimPrefix(strings.TrimPrefix(url, "http://"), "https://")
+    return strings.TrimSuffix(url, "/")
+}
 
 // redistribuye las tareas pendientes de un worker apagado
 /*func (d *Dispatcher) redistributeTasks(failedWorker *Worker) {
@@ -74,7 +214,7 @@ func (d *Dispatcher) HealthCheck() {
                 newWorker := seleccionarWorker(d)
                 if newWorker != nil {
                     newWorker.taskQueue <- task
-					log.Printf("Redistribuyendo tarea %s del worker %d al worker %d", task.ID, failedWorker.ID, newWorker.ID)
+					log.Printf("Redistribuyendo tarea %d del worker %d al worker %d", task.ID, failedWorker.ID, newWorker.ID)
 
                 }
             }
@@ -83,134 +223,253 @@ func (d *Dispatcher) HealthCheck() {
     }
 }*/
 
-// Redistribuye las tareas pendientes de un worker apagado
+// Redistribuye las tareas pendientes de un worker apagado. La cola se vacía con el
+// lock tomado y se reparte después, porque seleccionarWorker también lee el worker.
 func (d *Dispatcher) redistributeTasks(failedWorker *Worker) {
-    failedWorker.mu.Lock()
-    defer failedWorker.mu.Unlock()
-
     var pendingTasks []*Task
-    for {
+    failedWorker.mu.Lock()
+    for drained := false; !drained; {
         select {
         case task := <-failedWorker.taskQueue:
             pendingTasks = append(pendingTasks, task)
         default:
-            // Redistribuir tareas
-            for _, task := range pendingTasks {
-                newWorker := seleccionarWorker(d)
-                if newWorker != nil {
-                    newWorker.taskQueue <- task
-                    log.Printf("Redistribuyendo tarea %s del worker %d al worker %d", task.ID, failedWorker.ID, newWorker.ID)
-                } else {
-                    log.Printf("Redistribución de tarea %s fallida, no hay workers disponibles", task.ID)
-                }
-            }
-            return
+            drained = true
+        }
+    }
+    failedWorker.mu.Unlock()
+
+    // Redistribuir tareas
+    for _, task := range pendingTasks {
+        newWorker := seleccionarWorkerPara(d, task.Request.Path, task.Request.Params)
+        if newWorker != nil && newWorker != failedWorker && newWorker.enqueue(task) {
+            log.Printf("Redistribuyendo tarea %d del worker %d al worker %d", task.ID, failedWorker.ID, newWorker.ID)
+        } else {
+            log.Printf("Redistribución de tarea %d fallida, no hay workers disponibles", task.ID)
+            d.dequeued(task)
+            task.err = errNoWorkers
+            task.done()
         }
     }
 }
 
 
-// selecciona el worker que se va a usar para procesar la tarea
-func seleccionarWorker(d *Dispatcher) *Worker {
-	// Estrategia de round robin
-	if EstrategiaRed == 1 {
-		log.Printf("workers disponibles: %d", len(d.Workers))
-		
-		for i := 0; i < len(d.Workers); i++ {
-			// Buscar el siguiente worker disponible después del último usado
-			log.Printf("estado del worker %d: %v", i, d.Workers[i].Status)
-			idx := (d.lastWorkerIndex + i + 1) % len(d.Workers)
-			worker := d.Workers[idx]
+// selecciona el worker que se va a usar para procesar la tarea. Solo se
+// consideran los workers que declararon la ruta al registrarse; los sospechosos
+// se usan únicamente si no hay ningún worker vivo que la atienda.
+func seleccionarWorker(d *Dispatcher, route string) *Worker {
+	return seleccionarWorkerPara(d, route, nil)
+}
 
-			if worker.Status {
-				d.lastWorkerIndex = idx
+// seleccionarWorkerPara elige entre los candidatos con la estrategia configurada para
+// la ruta. params se usa como llave en la estrategia hash. Con hash los sospechosos
+// siguen siendo dueños de sus llaves: sacarlos del anillo movería sus archivos.
+func seleccionarWorkerPara(d *Dispatcher, route string, params map[string]string) *Worker {
+	return seleccionarWorkerExcepto(d, route, params, nil)
+}
 
-				return worker
-			}
-		}
+// seleccionarWorkerExcepto es seleccionarWorkerPara sin los workers de exclude,
+// por ejemplo los que ya fallaron con la tarea que se reintenta
+func seleccionarWorkerExcepto(d *Dispatcher, route string, params map[string]string, exclude map[*Worker]bool) *Worker {
+	entry := d.Balancers.For(route)
+	key := entry.requestKey(route, params)
+	if entry.keyed() {
+		return entry.balancer.Pick(excluir(d.candidatos(route, WorkerSuspect), exclude), key)
+	}
+	if worker := entry.balancer.Pick(excluir(d.candidatos(route, WorkerAlive), exclude), key); worker != nil {
+		return worker
+	}
+	return entry.balancer.Pick(excluir(d.candidatos(route, WorkerSuspect), exclude), key)
+}
 
+func excluir(list []*Worker, exclude map[*Worker]bool) []*Worker {
+	if len(exclude) == 0 {
+		return list
 	}
-	// Estrategia de least loaded
-	if EstrategiaRed == 2 {
-		var minLoad = -1
-		var selectedWorker *Worker = nil
-
-		for _, worker := range d.Workers {
-			if worker.Status {
-				// Si es el primer worker disponible o tiene menos carga
-				if minLoad == -1 || worker.CompletedTasks < minLoad {
-					minLoad = worker.CompletedTasks
-					selectedWorker = worker
-				}
-			}
+	var kept []*Worker
+	for _, worker := range list {
+		if !exclude[worker] {
+			kept = append(kept, worker)
 		}
+	}
+	return kept
+}
 
-		if selectedWorker != nil {
-			selectedWorker.CompletedTasks++ // Incrementamos su carga
-			return selectedWorker
-		}
+// candidatos retorna los workers que pueden recibir la ruta estando a lo sumo en maxState
+func (d *Dispatcher) candidatos(route string, maxState WorkerState) []*Worker {
+	d.Mu.RLock()
+	workers := d.Workers
+	d.Mu.RUnlock()
 
+	var list []*Worker
+	for _, worker := range workers {
+		if worker.availableIn(route, maxState) {
+			list = append(list, worker)
+		}
 	}
-	return nil // Aquí se implementaría la lógica para seleccionar un worker
-}
-
-// ver si lo paso a worker.go
-func (d *Dispatcher) suscribirHandler(conn net.Conn,params map[string]string) {
-    
-	// Verificar si el worker ya está registrado
-	workerURL, ok := params["url"]
-	cleanWorkerURL := strings.ReplaceAll(workerURL, "%3A", ":")
-    cleanWorkerURL = strings.ReplaceAll(cleanWorkerURL, "%2F", "/")
-	cleanWorkerURL = strings.Replace(cleanWorkerURL, "https:/", "", 1)
-    cleanWorkerURL = strings.ReplaceAll(cleanWorkerURL, "//", "/") 
-	log.Printf("Url del worker: %v", cleanWorkerURL)
-	if !ok || cleanWorkerURL == "" {
-        utils.SendResponse(conn, "400 Bad Request", "URL del worker requerida")
+	return list
+}
+
+// Registra un worker. El worker envía sus capacidades en JSON con POST /suscribir;
+// con GET /suscribir?url=... se registra sin capacidades y se consultan sus rutas en /routes.
+func (d *Dispatcher) suscribirHandler(conn net.Conn, req *httpmsg.Request, params map[string]string) {
+    var caps routes.Capabilities
+    legacy := req.Method != "POST"
+
+    if legacy {
+        // El parámetro ya llega decodificado
+        caps = routes.Capabilities{URL: params["url"], MaxConcurrency: DefaultWorkerCapacity}
+    } else {
+        body, err := req.ReadBody()
+        if err != nil {
+            utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+            return
+        }
+        if err := json.Unmarshal(body, &caps); err != nil {
+            utils.SendResponse(conn, "400 Bad Request", fmt.Sprintf("Capacidades inválidas: %v", err))
+            return
+        }
+    }
+
+    // Se quita el esquema si lo trae
+    caps.URL = cleanURL(caps.URL)
+    if err := caps.Validate(); err != nil {
+        utils.SendResponse(conn, "400 Bad Request", err.Error())
         return
     }
-	log.Printf("Intento de registro de worker: %s", cleanWorkerURL)
+    log.Printf("Intento de registro de worker: %s (versión %q, %d rutas, concurrencia %d)", caps.URL, caps.Version, len(caps.Routes), caps.MaxConcurrency)
 
     d.Mu.Lock()
-    defer d.Mu.Unlock()
-	// Verificar si el worker ya está registrado
+    // Verificar si el worker ya está registrado
     for _, w := range d.Workers {
-        if w.URL == cleanWorkerURL {
-            log.Printf("Worker ya registrado: %s", cleanWorkerURL)
-            utils.SendResponse(conn, "200 OK", `{"status": "already_registered"}`)
+        if w.URL == caps.URL {
+            if !legacy {
+                if w.applyCapabilities(caps) {
+                    w.start(d) // Cambió su concurrencia
+                }
+                d.Routes = routes.NewTable(append(d.Routes.Routes(), caps.Routes...))
+            }
+            // Se reinició: vuelve a recibir tareas aunque se estuviera drenando o estuviera muerto
+            prev := w.reactivate()
+            d.Mu.Unlock()
+            d.Pool.CloseWorker(w.URL) // Las conexiones libres eran del proceso anterior
+            log.Printf("Worker ya registrado: %s (estaba %s)", caps.URL, prev)
+            // Puede haber reiniciado sin sus archivos
+            d.scheduleRepair()
+            d.sendRegistration(conn, w.ID, "already_registered")
             return
         }
     }
-// Crear nuevo worker
-    workerID := len(d.Workers) + 1
-    newWorker := &Worker{
-        ID:           workerID,
-        URL:          cleanWorkerURL,
-        Status:       true,
-        lastChecked:  time.Now(),
-        activeTasks:  0,
-        taskQueue:    make(chan *Task, 1000),
-    }
 
+    // Crear nuevo worker
+    d.nextWorkerID++
+    workerID := d.nextWorkerID
+    newWorker := NewWorker(workerID, caps.URL, caps.MaxConcurrency)
+    newWorker.lastChecked = time.Now()
+    newWorker.legacy = legacy
+    if !legacy {
+        newWorker = newWorkerFromCapabilities(workerID, caps)
+        d.Routes = routes.NewTable(append(d.Routes.Routes(), caps.Routes...))
+    }
     d.Workers = append(d.Workers, newWorker)
+    d.Mu.Unlock()
+    newWorker.start(d)
+
     d.Metrics.mu.Lock()
     d.Metrics.WorkersRegistered++
     d.Metrics.mu.Unlock()
 
-    log.Printf("Worker %d registrado en %s", workerID, cleanWorkerURL)
-    
-    // Construir respuesta similar a sendToWorker
-    response := fmt.Sprintf(`{"id": "%d", "status": "registered"}`, workerID)
-    
-    // se da una respuesta al worker
-    var responseBuilder strings.Builder
-    responseBuilder.WriteString("HTTP/1.1 200 OK\r\n")
-    responseBuilder.WriteString("Content-Type: application/json\r\n")
-    responseBuilder.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(response)))
-    responseBuilder.WriteString("\r\n")
-    responseBuilder.WriteString(response)
-    
-    _, err := conn.Write([]byte(responseBuilder.String()))
-    if err != nil {
-        log.Printf("Error enviando respuesta de registro: %v", err)
+    log.Printf("Worker %d registrado en %s", workerID, caps.URL)
+    if legacy {
+        go d.loadRoutes(newWorker)
+    }
+    // El worker nuevo pasa a ser réplica de parte de los archivos
+    d.scheduleRepair()
+
+    d.sendRegistration(conn, workerID, "registered")
+}
+
+// Marca un worker como en drenaje con /desuscribir?url=... El worker deja de recibir
+// tareas nuevas y se elimina cuando termina las que tiene en curso.
+func (d *Dispatcher) desuscribirHandler(conn net.Conn, params map[string]string) {
+    workerURL := cleanURL(params["url"])
+    if workerURL == "" {
+        utils.SendResponse(conn, "400 Bad Request", "URL del worker requerida")
+        return
+    }
+
+    worker := d.findWorker(workerURL)
+    if worker == nil {
+        utils.SendResponse(conn, "404 Not Found", "Worker no registrado")
+        return
+    }
+
+    worker.mu.Lock()
+    worker.Draining = true
+    worker.mu.Unlock()
+    log.Printf("Worker %d (%s) en drenaje", worker.ID, worker.URL)
+
+    status := "draining"
+    if d.removeIfDrained(worker) {
+        status = "removed"
+    }
+    utils.SendJSON(conn, "200 OK", []byte(fmt.Sprintf(`{"id": "%d", "status": "%s"}`, worker.ID, status)))
+}
+
+// findWorker busca un worker registrado por URL
+func (d *Dispatcher) findWorker(url string) *Worker {
+    d.Mu.RLock()
+    defer d.Mu.RUnlock()
+    for _, w := range d.Workers {
+        if w.URL == url {
+            return w
+        }
+    }
+    return nil
+}
+
+// removeIfDrained elimina el worker si está en drenaje y ya no tiene tareas activas
+func (d *Dispatcher) removeIfDrained(w *Worker) bool {
+    w.mu.RLock()
+    drained := w.Draining && w.activeTasks == 0 && len(w.taskQueue) == 0
+    w.mu.RUnlock()
+    if !drained {
+        return false
+    }
+    d.removeWorker(w)
+    return true
+}
+
+// removeWorker saca al worker de la lista, cierra sus conexiones y rearma la tabla de rutas.
+// Se crea un slice nuevo para no modificar el que pueda estar recorriendo seleccionarWorker.
+func (d *Dispatcher) removeWorker(w *Worker) {
+    d.Mu.Lock()
+    remaining := make([]*Worker, 0, len(d.Workers))
+    for _, other := range d.Workers {
+        if other != w {
+            remaining = append(remaining, other)
+        }
+    }
+    if len(remaining) == len(d.Workers) {
+        d.Mu.Unlock()
+        return
+    }
+    d.Workers = remaining
+    d.rebuildRoutes()
+    d.Mu.Unlock()
+
+    w.stop()
+    d.Pool.CloseWorker(w.URL)
+    log.Printf("Worker %d (%s) eliminado", w.ID, w.URL)
+    d.scheduleRepair()
+}
+
+// rebuildRoutes arma la tabla de rutas con los workers que quedan. Requiere d.Mu tomado.
+func (d *Dispatcher) rebuildRoutes() {
+    var list []routes.Route
+    for _, w := range d.Workers {
+        w.mu.RLock()
+        list = append(list, w.routes.Routes()...)
+        w.mu.RUnlock()
     }
+    d.Routes = routes.NewTable(list)
 }
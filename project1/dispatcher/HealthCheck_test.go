@@ -1,26 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
 	"testing"
+	"time"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/assert"
 )
 
-// MockConn es un mock simple para simular una conexión net.Conn
-type MockConn struct {
-	mock.Mock
-}
-
-func (m *MockConn) Write(p []byte) (n int, err error) {
-	args := m.Called(p)
-	return len(p), args.Error(1)
-}
-
-func (m *MockConn) Close() error {
-	args := m.Called()
-	return args.Error(0)
-}
-
 // Prueba simplificada para Write
 func TestWriteMethodCalled(t *testing.T) {
 	mockConn := new(MockConn)
@@ -45,3 +36,231 @@ func TestCloseMethodCalled(t *testing.T) {
 	mockConn.AssertExpectations(t)
 	assert.NoError(t, err)
 }
+
+// enviar manda una solicitud al dispatcher por un net.Pipe y retorna la respuesta
+func enviar(t *testing.T, d *Dispatcher, method, target string, body []byte) *httpmsg.Response {
+	client, conn := net.Pipe()
+	defer client.Close()
+	go d.HandleConnection(conn)
+	go httpmsg.WriteRequest(client, method, target, nil, body)
+
+	resp, err := httpmsg.ReadResponse(bufio.NewReader(client), httpmsg.DefaultLimits)
+	assert.NoError(t, err)
+	return resp
+}
+
+// registrar envía POST /suscribir al dispatcher con las capacidades
+func registrar(t *testing.T, d *Dispatcher, caps routes.Capabilities) *httpmsg.Response {
+	body, err := json.Marshal(caps)
+	assert.NoError(t, err)
+	return enviar(t, d, "POST", "/suscribir", body)
+}
+
+// Prueba que el registro con capacidades limite las rutas que recibe cada worker
+func TestSuscribirConCapacidades(t *testing.T) {
+	d := newDispatcher()
+	get := []string{"GET"}
+
+	resp := registrar(t, d, routes.Capabilities{
+		URL: "http://files1:8080/", Version: "1.2.0", MaxConcurrency: 6,
+		Labels: map[string]string{"files": "true"},
+		Routes: []routes.Route{{Path: "/createfile", Methods: get, PoolSize: 3}, {Path: "/deletefile", Methods: get, PoolSize: 3}},
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+	resp = registrar(t, d, routes.Capabilities{
+		URL: "cpu1:8080", Version: "1.2.0", MaxConcurrency: 3,
+		Routes: []routes.Route{{Path: "/fibonacci", Methods: get, PoolSize: 3}},
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.Len(t, d.Workers, 2)
+	assert.Equal(t, "files1:8080", d.Workers[0].URL)
+	assert.Equal(t, 6, cap(d.Workers[0].taskQueue))
+	assert.Equal(t, "true", d.Workers[0].Labels["files"])
+	assert.Equal(t, 3, d.Routes.Len())
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "cpu1:8080", seleccionarWorker(d, "/fibonacci").URL)
+		assert.Equal(t, "files1:8080", seleccionarWorker(d, "/createfile").URL)
+	}
+	assert.Nil(t, seleccionarWorker(d, "/hash"))
+
+	// Un registro repetido actualiza las capacidades en lugar de duplicar el worker
+	resp = registrar(t, d, routes.Capabilities{
+		URL: "cpu1:8080", Version: "1.3.0", MaxConcurrency: 3,
+		Routes: []routes.Route{{Path: "/fibonacci", Methods: get, PoolSize: 3}, {Path: "/hash", Methods: get, PoolSize: 2}},
+	})
+	assert.Contains(t, string(resp.Body), "already_registered")
+	assert.Len(t, d.Workers, 2)
+	assert.Equal(t, "1.3.0", d.Workers[1].Version)
+	assert.Equal(t, "cpu1:8080", seleccionarWorker(d, "/hash").URL)
+}
+
+// Prueba que un documento de capacidades incompleto se rechace
+func TestSuscribirCapacidadesInvalidas(t *testing.T) {
+	d := newDispatcher()
+	resp := registrar(t, d, routes.Capabilities{URL: "worker1:8080"})
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Len(t, d.Workers, 0)
+}
+
+// Prueba que un worker en drenaje no reciba tareas y se elimine al terminar las que tiene
+func TestDesuscribirDrenaje(t *testing.T) {
+	d := newDispatcher()
+	get := []string{"GET"}
+	for _, url := range []string{"worker1:8080", "worker2:8080"} {
+		registrar(t, d, routes.Capabilities{URL: url, MaxConcurrency: 2,
+			Routes: []routes.Route{{Path: "/fibonacci", Methods: get, PoolSize: 2}}})
+	}
+	// worker1 tiene una tarea en curso en uno de sus loops
+	worker1 := d.Workers[0]
+	worker1.mu.Lock()
+	worker1.activeTasks = 1
+	worker1.mu.Unlock()
+
+	resp := enviar(t, d, "POST", "/desuscribir?url=worker1%3A8080", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(resp.Body), "draining")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "worker2:8080", seleccionarWorker(d, "/fibonacci").URL)
+	}
+
+	// Al terminar la última tarea el worker sale de la lista
+	d.releaseWorker(worker1)
+	assert.Len(t, d.Workers, 1)
+	assert.Nil(t, d.findWorker("worker1:8080"))
+
+	// Sin tareas en curso se elimina de inmediato, y un worker nuevo no reutiliza el ID
+	resp = enviar(t, d, "POST", "/desuscribir?url=worker2:8080", nil)
+	assert.Contains(t, string(resp.Body), "removed")
+	assert.Len(t, d.Workers, 0)
+	assert.Equal(t, 0, d.Routes.Len())
+
+	registrar(t, d, routes.Capabilities{URL: "worker3:8080", MaxConcurrency: 1, Routes: []routes.Route{{Path: "/ping", Methods: get}}})
+	assert.Equal(t, 3, d.Workers[0].ID)
+
+	assert.Equal(t, 404, enviar(t, d, "POST", "/desuscribir?url=worker9:8080", nil).StatusCode)
+}
+
+// Prueba que un worker que se drenó o murió y se vuelve a registrar reciba tareas otra vez
+func TestSuscribirReactivaWorker(t *testing.T) {
+	d := newDispatcher()
+	caps := routes.Capabilities{URL: "worker1:8080", MaxConcurrency: 2,
+		Routes: []routes.Route{{Path: "/fibonacci", Methods: []string{"GET"}, PoolSize: 2}}}
+	registrar(t, d, caps)
+	worker := d.Workers[0]
+
+	// Se drena con una tarea en curso, así no sale de la lista, y después deja de responder
+	worker.mu.Lock()
+	worker.activeTasks = 1
+	worker.mu.Unlock()
+	assert.Equal(t, 200, enviar(t, d, "POST", "/desuscribir?url=worker1:8080", nil).StatusCode)
+	d.checkHeartbeat(worker, time.Now().Add(time.Hour))
+	assert.Nil(t, seleccionarWorker(d, "/fibonacci"))
+
+	// Reinicia y se registra de nuevo, por ejemplo al notar otro epoch
+	assert.Equal(t, 200, registrar(t, d, caps).StatusCode)
+	assert.Len(t, d.Workers, 1)
+	assert.False(t, worker.Draining)
+	assert.Equal(t, WorkerAlive, worker.State)
+	assert.Equal(t, worker, seleccionarWorker(d, "/fibonacci"))
+	assert.Equal(t, WorkerAlive, d.checkHeartbeat(worker, time.Now()), "los heartbeats perdidos se cuentan desde el registro")
+}
+
+// Prueba que rearmar la tabla de rutas al quitar un worker tome el lock de cada worker,
+// porque otro puede estar actualizando sus capacidades (con -race)
+func TestRebuildRoutesConcurrente(t *testing.T) {
+	d := newDispatcher()
+	caps := routes.Capabilities{URL: "worker1:8080", MaxConcurrency: 1,
+		Routes: []routes.Route{{Path: "/fibonacci", Methods: []string{"GET"}}}}
+	registrar(t, d, caps)
+	worker := d.Workers[0]
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			worker.applyCapabilities(caps)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		d.Mu.Lock()
+		d.rebuildRoutes()
+		d.Mu.Unlock()
+	}
+	<-done
+	assert.Equal(t, 1, d.Routes.Len())
+}
+
+// Prueba que los heartbeats perdidos lleven al worker de vivo a sospechoso y a muerto
+func TestHeartbeatEstados(t *testing.T) {
+	d := newDispatcher()
+	get := []string{"GET"}
+	for _, url := range []string{"worker1:8080", "worker2:8080"} {
+		registrar(t, d, routes.Capabilities{URL: url, MaxConcurrency: 2,
+			Routes: []routes.Route{{Path: "/fibonacci", Methods: get, PoolSize: 2}}})
+	}
+	worker1, worker2 := d.Workers[0], d.Workers[1]
+
+	hb, _ := json.Marshal(routes.Heartbeat{URL: "http://worker1:8080", Seq: 1, IntervalMs: 100, Busy: 2,
+		Pools: map[string]routes.PoolStats{"/fibonacci": {Workers: 2, Busy: 2}}})
+	assert.Equal(t, 200, enviar(t, d, "POST", "/heartbeat", hb).StatusCode)
+	assert.Equal(t, 2, worker1.Load.Pools["/fibonacci"].Busy)
+	assert.Equal(t, 100*time.Millisecond, worker1.heartbeatInterval)
+
+	now := time.Now()
+	assert.Equal(t, WorkerAlive, d.checkHeartbeat(worker1, now.Add(150*time.Millisecond)))
+	assert.Equal(t, WorkerSuspect, d.checkHeartbeat(worker1, now.Add(250*time.Millisecond)))
+	assert.True(t, worker1.Status)
+	// worker2 sigue vivo, así que el sospechoso no recibe tareas
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, worker2, seleccionarWorker(d, "/fibonacci"))
+	}
+
+	assert.Equal(t, WorkerDead, d.checkHeartbeat(worker1, now.Add(600*time.Millisecond)))
+	assert.False(t, worker1.Status)
+
+	// Un heartbeat nuevo lo revive
+	assert.Equal(t, 200, enviar(t, d, "POST", "/heartbeat", hb).StatusCode)
+	assert.Equal(t, WorkerAlive, worker1.State)
+	assert.True(t, worker1.Status)
+
+	// Un worker desconocido recibe 404 para que se vuelva a registrar
+	hb, _ = json.Marshal(routes.Heartbeat{URL: "worker9:8080"})
+	assert.Equal(t, 404, enviar(t, d, "POST", "/heartbeat", hb).StatusCode)
+}
+
+// Prueba que /suscribir y /heartbeat informen el epoch del dispatcher y que cambie al reiniciar
+func TestEpochEnRespuestas(t *testing.T) {
+	d := newDispatcher()
+	caps := routes.Capabilities{URL: "worker1:8080", MaxConcurrency: 1,
+		Routes: []routes.Route{{Path: "/ping", Methods: []string{"GET"}}}}
+
+	var reg routes.Registration
+	assert.NoError(t, json.Unmarshal(registrar(t, d, caps).Body, &reg))
+	assert.Equal(t, routes.Registration{ID: "1", Status: "registered", Epoch: d.Epoch}, reg)
+	assert.NoError(t, json.Unmarshal(registrar(t, d, caps).Body, &reg))
+	assert.Equal(t, "already_registered", reg.Status)
+
+	hb, _ := json.Marshal(routes.Heartbeat{URL: "worker1:8080", Seq: 1})
+	reg = routes.Registration{}
+	assert.NoError(t, json.Unmarshal(enviar(t, d, "POST", "/heartbeat", hb).Body, &reg))
+	assert.Equal(t, "ok", reg.Status)
+	assert.Equal(t, d.Epoch, reg.Epoch)
+
+	assert.NotEmpty(t, d.Epoch)
+	assert.NotEqual(t, d.Epoch, newDispatcher().Epoch)
+}
+
+// Prueba que un error de comunicación marque al worker como sospechoso sin sacarlo del todo
+func TestMarkSuspect(t *testing.T) {
+	d := newDispatcher()
+	registrar(t, d, routes.Capabilities{URL: "worker1:8080", MaxConcurrency: 1,
+		Routes: []routes.Route{{Path: "/ping", Methods: []string{"GET"}}}})
+	worker := d.Workers[0]
+
+	d.markSuspect(worker)
+	assert.Equal(t, WorkerSuspect, worker.State)
+	// Sin workers vivos se usa el sospechoso antes de responder 503
+	assert.Equal(t, worker, seleccionarWorker(d, "/ping"))
+}
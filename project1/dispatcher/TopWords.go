@@ -0,0 +1,49 @@
+// TopWords.go (en módulo dispatcher)
+package main
+
+import (
+	"fmt"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultTopWords = 10
+
+// topWordsJob responde las n palabras más frecuentes del cuerpo de POST /topwords?n=N.
+// Cada worker cuenta las palabras de un grupo de líneas y el dispatcher suma las cuentas.
+var topWordsJob = &MapReduce{
+	Method: "POST",
+	Route:  "/topwords",
+	Fn:     "wordfreq",
+	Split:  SplitLines,
+	Reduce: func(in MapInput, total routes.MapResult) (string, error) {
+		n := DefaultTopWords
+		if value, ok := in.Params["n"]; ok {
+			var err error
+			if n, err = strconv.Atoi(value); err != nil || n <= 0 {
+				return "", &httpmsg.Error{Status: 400, Msg: "Parámetro 'n' debe ser un número entero positivo"}
+			}
+		}
+		words := make([]string, 0, len(total))
+		for word := range total {
+			words = append(words, word)
+		}
+		sort.Slice(words, func(i, k int) bool {
+			if total[words[i]] != total[words[k]] {
+				return total[words[i]] > total[words[k]]
+			}
+			return words[i] < words[k]
+		})
+		if len(words) > n {
+			words = words[:n]
+		}
+		var b strings.Builder
+		for _, word := range words {
+			fmt.Fprintf(&b, "%s %d\n", word, int(total[word]))
+		}
+		return b.String(), nil
+	},
+}
@@ -0,0 +1,234 @@
+// admission.go (en módulo dispatcher)
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"http-servidor/utils"
+	"http-shared/httpmsg"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMaxConnections  = 1000 // Conexiones de clientes atendidas a la vez
+	DefaultRouteQueueLimit = 100  // Tareas de una ruta esperando en las colas de los workers
+	RetryAfterSeconds      = 1    // Valor de Retry-After en las respuestas 503
+	RejectReadTimeout      = 1 * time.Second // Tiempo para leer la solicitud que se va a rechazar
+)
+
+var errRouteFull = errors.New("Demasiadas solicitudes en cola para la ruta, intente de nuevo")
+
+// Rutas que se atienden aunque se haya llegado a MaxConnections, para que los
+// workers no queden sospechosos cuando el dispatcher está saturado
+var controlRoutes = map[string]bool{"/suscribir": true, "/desuscribir": true, "/heartbeat": true}
+
+// Admission limita las conexiones que se atienden a la vez y las tareas de cada
+// ruta que esperan en las colas de los workers
+type Admission struct {
+	MaxConnections int // <= 0 no limita
+
+	connections         int64
+	rejectedConnections int64
+
+	mu           sync.Mutex
+	defaultLimit int // <= 0 no limita
+	limits       map[string]int
+	routes       map[string]*routeQueue
+}
+
+type routeQueue struct {
+	queued   int
+	rejected int
+}
+
+func NewAdmission(maxConnections, routeLimit int) *Admission {
+	return &Admission{
+		MaxConnections: maxConnections,
+		defaultLimit:   routeLimit,
+		limits:         make(map[string]int),
+		routes:         make(map[string]*routeQueue),
+	}
+}
+
+// Configure aplica una lista "ruta=límite" de límites de cola por ruta
+func (a *Admission) Configure(list string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.Index(item, "=")
+		if i <= 0 {
+			return fmt.Errorf("límite de cola inválido %q, use ruta=límite", item)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(item[i+1:]))
+		if err != nil {
+			return fmt.Errorf("límite de cola inválido %q: %v", item, err)
+		}
+		a.limits[strings.TrimSpace(item[:i])] = limit
+	}
+	return nil
+}
+
+// enterConn ocupa un lugar para una conexión. Retorna false si no queda ninguno.
+func (a *Admission) enterConn() bool {
+	if n := atomic.AddInt64(&a.connections, 1); a.MaxConnections > 0 && n > int64(a.MaxConnections) {
+		atomic.AddInt64(&a.connections, -1)
+		return false
+	}
+	return true
+}
+
+func (a *Admission) leaveConn() {
+	atomic.AddInt64(&a.connections, -1)
+}
+
+func (a *Admission) limitOf(route string) int {
+	if limit, ok := a.limits[route]; ok {
+		return limit
+	}
+	return a.defaultLimit
+}
+
+func (a *Admission) queueOf(route string) *routeQueue {
+	q, ok := a.routes[route]
+	if !ok {
+		q = &routeQueue{}
+		a.routes[route] = q
+	}
+	return q
+}
+
+// enterQueue cuenta una tarea de la ruta que va a esperar en una cola. Retorna
+// false si la ruta ya tiene su límite de tareas esperando.
+func (a *Admission) enterQueue(route string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	q := a.queueOf(route)
+	if limit := a.limitOf(route); limit > 0 && q.queued >= limit {
+		q.rejected++
+		return false
+	}
+	q.queued++
+	return true
+}
+
+func (a *Admission) leaveQueue(route string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if q := a.queueOf(route); q.queued > 0 {
+		q.queued--
+	}
+}
+
+// Snapshot retorna el estado de los límites para /workers
+func (a *Admission) Snapshot() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	byRoute := make(map[string]interface{}, len(a.routes))
+	for route, q := range a.routes {
+		byRoute[route] = map[string]int{
+			"queued":   q.queued,
+			"limit":    a.limitOf(route),
+			"rejected": q.rejected,
+		}
+	}
+	return map[string]interface{}{
+		"connections":          atomic.LoadInt64(&a.connections),
+		"max_connections":      a.MaxConnections,
+		"rejected_connections": atomic.LoadInt64(&a.rejectedConnections),
+		"routes":               byRoute,
+	}
+}
+
+// admissionFromEnv arma los límites con MAX_CONNECTIONS, ROUTE_QUEUE_LIMIT (límite
+// por defecto) y ROUTE_QUEUE_LIMITS (por ejemplo "/simulate=10,/fibonacci=50")
+func admissionFromEnv() *Admission {
+	maxConns, routeLimit := DefaultMaxConnections, DefaultRouteQueueLimit
+	if v := os.Getenv("MAX_CONNECTIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			maxConns = n
+		} else {
+			log.Printf("MAX_CONNECTIONS inválido, se usa %d: %v", maxConns, err)
+		}
+	}
+	if v := os.Getenv("ROUTE_QUEUE_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			routeLimit = n
+		} else {
+			log.Printf("ROUTE_QUEUE_LIMIT inválido, se usa %d: %v", routeLimit, err)
+		}
+	}
+	a := NewAdmission(maxConns, routeLimit)
+	if err := a.Configure(os.Getenv("ROUTE_QUEUE_LIMITS")); err != nil {
+		log.Printf("ROUTE_QUEUE_LIMITS inválido: %v", err)
+	}
+	return a
+}
+
+// ServeConn atiende la conexión si no se llegó a MaxConnections. Si se llegó, solo
+// se atienden las rutas de control y el resto recibe 503 con Retry-After.
+func (d *Dispatcher) ServeConn(conn net.Conn) {
+	atomic.AddInt64(&d.inFlight, 1)
+	defer atomic.AddInt64(&d.inFlight, -1)
+	if d.Admission.enterConn() {
+		defer d.Admission.leaveConn()
+		d.HandleConnection(conn)
+		return
+	}
+
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(RejectReadTimeout))
+	req, err := httpmsg.ReadRequest(bufio.NewReader(conn), httpmsg.DefaultLimits)
+	if err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	if route, _, err := httpmsg.ParseRoute(req.Target); err == nil && controlRoutes[route] {
+		d.handleRequest(conn, req)
+		return
+	}
+	atomic.AddInt64(&d.Admission.rejectedConnections, 1)
+	log.Printf("Conexión rechazada: se llegó a %d conexiones", d.Admission.MaxConnections)
+	sendRetryLater(conn, "Dispatcher saturado, intente de nuevo")
+}
+
+// enqueue encola una tarea de cliente en el worker respetando el límite de cola de
+// su ruta. Retorna errRouteFull o errWorkerBusy si la tarea no se encoló.
+func (d *Dispatcher) enqueue(w *Worker, task *Task) error {
+	route := task.Request.Path
+	if !d.Admission.enterQueue(route) {
+		return errRouteFull
+	}
+	task.admitted = true
+	if !w.enqueue(task) {
+		d.dequeued(task)
+		return errWorkerBusy
+	}
+	return nil
+}
+
+// dequeued descuenta la tarea del límite de su ruta cuando sale de la cola
+func (d *Dispatcher) dequeued(task *Task) {
+	if task.admitted {
+		task.admitted = false
+		d.Admission.leaveQueue(task.Request.Path)
+	}
+}
+
+// sendRetryLater responde 503 con Retry-After para que el cliente reintente luego
+func sendRetryLater(conn net.Conn, msg string) {
+	header := httpmsg.Header{}
+	header.Set("Retry-After", strconv.Itoa(RetryAfterSeconds))
+	utils.SendWorkerResponse(conn, 503, header, []byte(msg))
+}
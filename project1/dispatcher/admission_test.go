@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// enviarAdmitido es enviar pasando por el límite de conexiones del dispatcher
+func enviarAdmitido(t *testing.T, d *Dispatcher, method, target string) *httpmsg.Response {
+	client, conn := net.Pipe()
+	defer client.Close()
+	go d.ServeConn(conn)
+	go httpmsg.WriteRequest(client, method, target, nil, nil)
+
+	resp, err := httpmsg.ReadResponse(bufio.NewReader(client), httpmsg.DefaultLimits)
+	assert.NoError(t, err)
+	return resp
+}
+
+func leerAdmision(t *testing.T, d *Dispatcher) map[string]interface{} {
+	var status struct {
+		Admission map[string]interface{} `json:"admission"`
+	}
+	assert.NoError(t, json.Unmarshal(enviar(t, d, "GET", "/workers", nil).Body, &status))
+	return status.Admission
+}
+
+// Prueba que con MaxConnections ocupadas se responda 503 con Retry-After salvo a
+// las rutas de control de los workers
+func TestLimiteDeConexiones(t *testing.T) {
+	d := newDispatcher()
+	d.Admission = NewAdmission(1, 0)
+	assert.True(t, d.Admission.enterConn())
+
+	resp := enviarAdmitido(t, d, "GET", "/fibonacci?num=5")
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+	assert.NotEqual(t, 503, enviarAdmitido(t, d, "POST", "/heartbeat").StatusCode, "los heartbeats no se rechazan")
+
+	admission := leerAdmision(t, d)
+	assert.EqualValues(t, 1, admission["connections"])
+	assert.EqualValues(t, 1, admission["rejected_connections"])
+
+	d.Admission.leaveConn()
+	assert.Equal(t, 200, enviarAdmitido(t, d, "GET", "/workers").StatusCode)
+}
+
+// Prueba que una ruta no tenga más de su límite de tareas esperando en cola y que
+// la espera se vea en /workers
+func TestLimiteDeColaPorRuta(t *testing.T) {
+	d := newDispatcher()
+	d.Admission = NewAdmission(0, 0)
+	assert.NoError(t, d.Admission.Configure("/lento=1"))
+	gw := newGateWorker(t)
+	registrar(t, d, routes.Capabilities{URL: gw.addr, MaxConcurrency: 2,
+		Routes: []routes.Route{{Path: "/lento", Methods: []string{"GET"}}}})
+	worker := d.Workers[0]
+
+	// Se envían de a una: la tarea cuenta en el límite hasta que un loop la toma
+	codes := make(chan int, 3)
+	for i := 1; i <= 3; i++ {
+		go func() { codes <- enviar(t, d, "GET", "/lento", nil).StatusCode }()
+		esperarHasta(t, "La tarea no llegó al worker", func() bool {
+			return gw.inFlight()+worker.queued() == i
+		})
+	}
+	assert.Equal(t, 1, worker.queued())
+
+	// La cola del worker tiene lugar, pero la ruta ya llegó a su límite
+	resp := enviar(t, d, "GET", "/lento", nil)
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+	assert.Equal(t, errRouteFull.Error(), string(resp.Body))
+
+	lento := leerAdmision(t, d)["routes"].(map[string]interface{})["/lento"].(map[string]interface{})
+	assert.EqualValues(t, 1, lento["queued"])
+	assert.EqualValues(t, 1, lento["limit"])
+	assert.EqualValues(t, 1, lento["rejected"])
+
+	gw.open()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 200, <-codes)
+	}
+	lento = leerAdmision(t, d)["routes"].(map[string]interface{})["/lento"].(map[string]interface{})
+	assert.EqualValues(t, 0, lento["queued"])
+
+	var status struct {
+		Workers []map[string]interface{} `json:"workers_status"`
+	}
+	assert.NoError(t, json.Unmarshal(enviar(t, d, "GET", "/workers", nil).Body, &status))
+	assert.EqualValues(t, 0, status.Workers[0]["rejected_tasks"])
+	assert.Greater(t, status.Workers[0]["queue_wait_max_ms"], 0.0, "la tarea en cola esperó al gate")
+}
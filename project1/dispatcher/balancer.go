@@ -0,0 +1,533 @@
+// balancer.go (en módulo dispatcher)
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"http-servidor/utils"
+	"http-shared/httpmsg"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStrategy es la estrategia de las rutas sin configuración propia
+const DefaultStrategy = StrategyRoundRobin
+
+// FileRoutes son las rutas que trabajan sobre files/ del worker. Se reparten por hash
+// del nombre para que el archivo se cree, lea y borre siempre en el mismo worker.
+var FileRoutes = []string{"/createfile", "/deletefile", "/readfile", "/stat", "/appendfile", "/uploadfile"}
+
+// FileKeyParam es el parámetro que identifica el archivo
+const FileKeyParam = "name"
+
+// Balancer elige un worker entre los candidatos. Los candidatos ya están filtrados:
+// declararon la ruta, no se están drenando y su estado lo permite. key identifica la
+// solicitud y solo lo usan las estrategias que mandan la misma llave al mismo worker.
+type Balancer interface {
+	Name() string
+	Pick(candidates []*Worker, key string) *Worker
+}
+
+// Nombres de las estrategias, se usan en la configuración y en /admin/balancer
+const (
+	StrategyRoundRobin  = "round_robin"
+	StrategyLeastActive = "least_active"
+	StrategyWeighted    = "weighted"
+	StrategyP2C         = "p2c"
+	StrategyHash        = "hash"
+)
+
+// NewBalancer crea la estrategia por nombre
+func NewBalancer(name string) (Balancer, error) {
+	switch name {
+	case StrategyRoundRobin, "rr":
+		return &roundRobin{}, nil
+	case StrategyLeastActive, "least":
+		return leastActive{}, nil
+	case StrategyWeighted, "wrr":
+		return &weightedRoundRobin{current: make(map[*Worker]int)}, nil
+	case StrategyP2C:
+		return newPowerOfTwo(rand.New(rand.NewSource(time.Now().UnixNano()))), nil
+	case StrategyHash:
+		return newConsistentHash(HashReplicas), nil
+	}
+	return nil, fmt.Errorf("estrategia desconocida %q, use %s, %s, %s, %s o %s",
+		name, StrategyRoundRobin, StrategyLeastActive, StrategyWeighted, StrategyP2C, StrategyHash)
+}
+
+// activeOf lee las tareas activas del worker, contando las que esperan en su cola
+func activeOf(w *Worker) int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.activeTasks + len(w.taskQueue)
+}
+
+// roundRobin reparte en orden entre los candidatos
+type roundRobin struct {
+	next uint64
+}
+
+func (b *roundRobin) Name() string { return StrategyRoundRobin }
+
+func (b *roundRobin) Pick(candidates []*Worker, key string) *Worker {
+	if len(candidates) == 0 {
+		return nil
+	}
+	n := atomic.AddUint64(&b.next, 1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+// leastActive elige el worker con menos tareas activas; en empate, el primero
+type leastActive struct{}
+
+func (leastActive) Name() string { return StrategyLeastActive }
+
+func (leastActive) Pick(candidates []*Worker, key string) *Worker {
+	var selected *Worker
+	minActive := -1
+	for _, w := range candidates {
+		if active := activeOf(w); minActive == -1 || active < minActive {
+			minActive = active
+			selected = w
+		}
+	}
+	return selected
+}
+
+// weightedRoundRobin reparte en proporción a la capacidad de cada worker (maxCapacity)
+// con el algoritmo suave de nginx, que intercala los workers en lugar de agruparlos
+type weightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[*Worker]int
+}
+
+func (b *weightedRoundRobin) Name() string { return StrategyWeighted }
+
+func (b *weightedRoundRobin) Pick(candidates []*Worker, key string) *Worker {
+	if len(candidates) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total := 0
+	var selected *Worker
+	for _, w := range candidates {
+		w.mu.RLock()
+		weight := w.maxCapacity
+		w.mu.RUnlock()
+		if weight < 1 {
+			weight = 1
+		}
+		total += weight
+		b.current[w] += weight
+		if selected == nil || b.current[w] > b.current[selected] {
+			selected = w
+		}
+	}
+	b.current[selected] -= total
+
+	// Se olvidan los workers que ya no son candidatos para no acumular memoria
+	if len(b.current) > len(candidates) {
+		alive := make(map[*Worker]bool, len(candidates))
+		for _, w := range candidates {
+			alive[w] = true
+		}
+		for w := range b.current {
+			if !alive[w] {
+				delete(b.current, w)
+			}
+		}
+	}
+	return selected
+}
+
+// powerOfTwo toma dos candidatos al azar y se queda con el de menos tareas activas
+type powerOfTwo struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newPowerOfTwo(rnd *rand.Rand) *powerOfTwo {
+	return &powerOfTwo{rnd: rnd}
+}
+
+func (b *powerOfTwo) Name() string { return StrategyP2C }
+
+func (b *powerOfTwo) Pick(candidates []*Worker, key string) *Worker {
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	}
+	b.mu.Lock()
+	i := b.rnd.Intn(len(candidates))
+	j := b.rnd.Intn(len(candidates) - 1)
+	b.mu.Unlock()
+	if j >= i {
+		j++ // j distinto de i
+	}
+	if activeOf(candidates[j]) < activeOf(candidates[i]) {
+		return candidates[j]
+	}
+	return candidates[i]
+}
+
+// HashReplicas es la cantidad de nodos virtuales por worker en el anillo
+const HashReplicas = 100
+
+// consistentHash manda la misma llave al mismo worker. Cuando un worker entra o
+// sale solo cambian de dueño las llaves de su parte del anillo.
+type consistentHash struct {
+	replicas int
+	mu       sync.Mutex
+	ringKey  string // URLs de los candidatos con los que se armó el anillo
+	ring     []ringNode
+}
+
+type ringNode struct {
+	hash   uint32
+	worker *Worker
+}
+
+func newConsistentHash(replicas int) *consistentHash {
+	return &consistentHash{replicas: replicas}
+}
+
+func (b *consistentHash) Name() string { return StrategyHash }
+
+func (b *consistentHash) Pick(candidates []*Worker, key string) *Worker {
+	if len(candidates) == 0 {
+		return nil
+	}
+	return b.owners(candidates, key, 1)[0]
+}
+
+// owners retorna hasta n workers distintos recorriendo el anillo desde la llave.
+// El primero es el que retorna Pick; los demás son las réplicas siguientes.
+func (b *consistentHash) owners(candidates []*Worker, key string, n int) []*Worker {
+	if len(candidates) == 0 {
+		return nil
+	}
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	b.mu.Lock()
+	ring := b.ringFor(candidates)
+	b.mu.Unlock()
+
+	h := hashKey(key)
+	start := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+	list := make([]*Worker, 0, n)
+	seen := make(map[*Worker]bool, n)
+	for i := 0; i < len(ring) && len(list) < n; i++ {
+		w := ring[(start+i)%len(ring)].worker
+		if !seen[w] {
+			seen[w] = true
+			list = append(list, w)
+		}
+	}
+	return list
+}
+
+// ringFor reutiliza el anillo si los candidatos no cambiaron. Requiere b.mu tomado.
+func (b *consistentHash) ringFor(candidates []*Worker) []ringNode {
+	urls := make([]string, len(candidates))
+	for i, w := range candidates {
+		urls[i] = w.URL
+	}
+	sort.Strings(urls)
+	ringKey := strings.Join(urls, ",")
+	if ringKey == b.ringKey && len(b.ring) > 0 {
+		// El anillo guarda punteros, se actualizan por si el worker se volvió a registrar
+		byURL := make(map[string]*Worker, len(candidates))
+		for _, w := range candidates {
+			byURL[w.URL] = w
+		}
+		for i := range b.ring {
+			b.ring[i].worker = byURL[b.ring[i].worker.URL]
+		}
+		return b.ring
+	}
+
+	ring := make([]ringNode, 0, len(candidates)*b.replicas)
+	for _, w := range candidates {
+		// El hash depende de la URL y no del orden ni del ID para que sea estable entre reinicios
+		for r := 0; r < b.replicas; r++ {
+			ring = append(ring, ringNode{hash: hashKey(w.URL + "#" + strconv.Itoa(r)), worker: w})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	b.ringKey = ringKey
+	b.ring = ring
+	return ring
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// balancerEntry es la estrategia de una ruta. keyParam es el parámetro de la query
+// que se usa como llave en hash; vacío usa la ruta con toda la query.
+type balancerEntry struct {
+	balancer Balancer
+	keyParam string
+	spec     string
+}
+
+// Balancers guarda la estrategia por defecto y las que se asignaron a rutas puntuales.
+// Cada ruta tiene su propia instancia para que el estado de una no afecte a otra.
+type Balancers struct {
+	mu          sync.RWMutex
+	defaultSpec string
+	routes      map[string]*balancerEntry
+	fallback    map[string]*balancerEntry // Instancias de la estrategia por defecto, creadas por ruta
+}
+
+// NewBalancers crea el registro con la estrategia por defecto, por ejemplo "least_active"
+func NewBalancers(defaultSpec string) (*Balancers, error) {
+	entry, err := newBalancerEntry(defaultSpec)
+	if err != nil {
+		return nil, err
+	}
+	return &Balancers{
+		defaultSpec: entry.spec,
+		routes:      make(map[string]*balancerEntry),
+		fallback:    make(map[string]*balancerEntry),
+	}, nil
+}
+
+// newBalancerEntry interpreta "estrategia" o "hash:parametro"
+func newBalancerEntry(spec string) (*balancerEntry, error) {
+	name, keyParam := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, keyParam = spec[:i], spec[i+1:]
+	}
+	b, err := NewBalancer(name)
+	if err != nil {
+		return nil, err
+	}
+	if keyParam != "" && b.Name() != StrategyHash {
+		return nil, fmt.Errorf("solo la estrategia %s acepta parámetro de llave", StrategyHash)
+	}
+	spec = b.Name()
+	if keyParam != "" {
+		spec += ":" + keyParam
+	}
+	return &balancerEntry{balancer: b, keyParam: keyParam, spec: spec}, nil
+}
+
+// Set asigna la estrategia de una ruta. Con route vacío o "*" cambia la estrategia por defecto.
+func (bs *Balancers) Set(route, spec string) error {
+	entry, err := newBalancerEntry(spec)
+	if err != nil {
+		return err
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if route == "" || route == "*" {
+		bs.defaultSpec = entry.spec
+		bs.fallback = make(map[string]*balancerEntry)
+		return nil
+	}
+	bs.routes[route] = entry
+	return nil
+}
+
+// Reset hace que la ruta vuelva a usar la estrategia por defecto
+func (bs *Balancers) Reset(route string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	delete(bs.routes, route)
+}
+
+// Configure aplica una lista "ruta=estrategia,ruta=hash:parametro"
+func (bs *Balancers) Configure(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.Index(item, "=")
+		if i <= 0 {
+			return fmt.Errorf("configuración de balanceo inválida %q, use ruta=estrategia", item)
+		}
+		if err := bs.Set(strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// For retorna la estrategia de la ruta
+func (bs *Balancers) For(route string) *balancerEntry {
+	bs.mu.RLock()
+	entry, ok := bs.routes[route]
+	bs.mu.RUnlock()
+	if ok {
+		return entry
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if entry, ok := bs.fallback[route]; ok {
+		return entry
+	}
+	// defaultSpec ya se validó en NewBalancers o Set
+	entry, _ = newBalancerEntry(bs.defaultSpec)
+	bs.fallback[route] = entry
+	return entry
+}
+
+// Snapshot retorna la configuración actual para /admin/balancer
+func (bs *Balancers) Snapshot() map[string]interface{} {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	byRoute := make(map[string]string, len(bs.routes))
+	for route, entry := range bs.routes {
+		byRoute[route] = entry.spec
+	}
+	return map[string]interface{}{
+		"default": bs.defaultSpec,
+		"routes":  byRoute,
+	}
+}
+
+// balancersFromEnv arma el registro con BALANCER (estrategia por defecto) y
+// BALANCER_ROUTES (por ejemplo "/fibonacci=least_active,/hash=p2c"). Las rutas de
+// archivos usan hash sobre name salvo que BALANCER_ROUTES diga otra cosa.
+func balancersFromEnv() *Balancers {
+	spec := os.Getenv("BALANCER")
+	if spec == "" {
+		spec = DefaultStrategy
+	}
+	bs, err := NewBalancers(spec)
+	if err != nil {
+		log.Printf("BALANCER inválido, se usa %s: %v", DefaultStrategy, err)
+		bs, _ = NewBalancers(DefaultStrategy)
+	}
+	for _, route := range FileRoutes {
+		bs.Set(route, StrategyHash+":"+FileKeyParam)
+	}
+	if err := bs.Configure(os.Getenv("BALANCER_ROUTES")); err != nil {
+		log.Printf("BALANCER_ROUTES inválido: %v", err)
+	}
+	return bs
+}
+
+// keyed indica si la estrategia asigna la solicitud a un dueño según su llave
+func (e *balancerEntry) keyed() bool {
+	return e.balancer.Name() == StrategyHash
+}
+
+// requestKey arma la llave de la solicitud para la estrategia hash
+func (e *balancerEntry) requestKey(route string, params map[string]string) string {
+	if e.keyParam != "" {
+		return params[e.keyParam]
+	}
+	if len(params) == 0 {
+		return route
+	}
+	return route + "?" + httpmsg.FromMap(params).Encode()
+}
+
+// balancerHandler atiende /admin/balancer. GET muestra la configuración; POST
+// ?route=/ruta&strategy=hash:name la cambia en caliente. Sin route (o route=*) se
+// cambia la estrategia por defecto y sin strategy la ruta vuelve a la de por defecto.
+func (d *Dispatcher) balancerHandler(conn net.Conn, method string, params map[string]string) {
+	switch method {
+	case "GET":
+	case "POST":
+		route, spec := params["route"], params["strategy"]
+		if spec == "" {
+			if route == "" || route == "*" {
+				utils.SendResponse(conn, "400 Bad Request", "Parámetro 'strategy' requerido")
+				return
+			}
+			d.Balancers.Reset(route)
+		} else if err := d.Balancers.Set(route, spec); err != nil {
+			utils.SendResponse(conn, "400 Bad Request", err.Error())
+			return
+		}
+		log.Printf("Balanceo de %q cambiado a %q", route, spec)
+	default:
+		utils.SendResponse(conn, "405 Method Not Allowed", "Use GET o POST")
+		return
+	}
+
+	jsonData, err := json.MarshalIndent(d.Balancers.Snapshot(), "", "  ")
+	if err != nil {
+		utils.SendResponse(conn, "500 Internal Server Error", "Error generando JSON")
+		return
+	}
+	utils.SendJSON(conn, "200 OK", jsonData)
+}
+
+// ownerHandler atiende /admin/owner?route=/createfile&name=archivo.txt y muestra qué
+// worker es dueño de la llave. Sin route se usa /createfile. Solo aplica a rutas con hash.
+func (d *Dispatcher) ownerHandler(conn net.Conn, params map[string]string) {
+	route := params["route"]
+	if route == "" {
+		route = FileRoutes[0]
+	}
+	entry := d.Balancers.For(route)
+	if !entry.keyed() {
+		utils.SendResponse(conn, "409 Conflict", fmt.Sprintf("La ruta %s usa %s, que no asigna dueño por llave", route, entry.spec))
+		return
+	}
+
+	query := make(map[string]string, len(params))
+	for name, value := range params {
+		if name != "route" {
+			query[name] = value
+		}
+	}
+	if entry.keyParam != "" && query[entry.keyParam] == "" {
+		utils.SendResponse(conn, "400 Bad Request", fmt.Sprintf("Parámetro '%s' requerido", entry.keyParam))
+		return
+	}
+
+	key := entry.requestKey(route, query)
+	worker := seleccionarWorkerPara(d, route, query)
+	if worker == nil {
+		utils.SendResponse(conn, "503 Service Unavailable", "No hay workers disponibles")
+		return
+	}
+	worker.mu.RLock()
+	response := map[string]interface{}{
+		"route":    route,
+		"strategy": entry.spec,
+		"key":      key,
+		"worker":   worker.URL,
+		"pid":      worker.ID,
+		"state":    worker.State.String(),
+	}
+	worker.mu.RUnlock()
+	if d.Replication.Enabled() && isFileRoute(route) {
+		var urls []string
+		for _, w := range d.replicas(query[FileKeyParam]) {
+			urls = append(urls, w.URL)
+		}
+		response["replicas"] = urls
+		response["quorum"] = d.Replication.Quorum
+	}
+
+	jsonData, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		utils.SendResponse(conn, "500 Internal Server Error", "Error generando JSON")
+		return
+	}
+	utils.SendJSON(conn, "200 OK", jsonData)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"http-shared/routes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// workersDePrueba crea n workers con la capacidad indicada
+func workersDePrueba(capacities ...int) []*Worker {
+	list := make([]*Worker, len(capacities))
+	for i, c := range capacities {
+		list[i] = NewWorker(i+1, fmt.Sprintf("worker%d:8080", i+1), c)
+	}
+	return list
+}
+
+func contar(b Balancer, workers []*Worker, n int) map[*Worker]int {
+	picks := make(map[*Worker]int)
+	for i := 0; i < n; i++ {
+		picks[b.Pick(workers, fmt.Sprintf("k%d", i))]++
+	}
+	return picks
+}
+
+func TestRoundRobin(t *testing.T) {
+	workers := workersDePrueba(1, 1, 1)
+	b, _ := NewBalancer(StrategyRoundRobin)
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, workers[i%3], b.Pick(workers, ""))
+	}
+	assert.Nil(t, b.Pick(nil, ""))
+}
+
+func TestLeastActive(t *testing.T) {
+	workers := workersDePrueba(5, 5, 5)
+	workers[0].activeTasks = 3
+	workers[1].activeTasks = 1
+	workers[2].activeTasks = 2
+	b, _ := NewBalancer("least")
+	assert.Equal(t, workers[1], b.Pick(workers, ""))
+
+	workers[1].activeTasks = 4
+	assert.Equal(t, workers[2], b.Pick(workers, ""))
+}
+
+// Prueba que el reparto siga la capacidad y que los workers se intercalen
+func TestWeightedRoundRobin(t *testing.T) {
+	workers := workersDePrueba(3, 1)
+	b, _ := NewBalancer(StrategyWeighted)
+
+	var orden []int
+	for i := 0; i < 4; i++ {
+		orden = append(orden, b.Pick(workers, "").ID)
+	}
+	assert.Equal(t, []int{1, 1, 2, 1}, orden)
+
+	picks := contar(b, workers, 400)
+	assert.Equal(t, 300, picks[workers[0]])
+	assert.Equal(t, 100, picks[workers[1]])
+}
+
+// Prueba que power of two nunca elija al worker más cargado
+func TestPowerOfTwo(t *testing.T) {
+	workers := workersDePrueba(5, 5, 5, 5)
+	workers[0].activeTasks = 1
+	workers[1].activeTasks = 1
+	workers[2].activeTasks = 9
+	workers[3].activeTasks = 2
+	b := newPowerOfTwo(rand.New(rand.NewSource(1)))
+
+	picks := contar(b, workers, 200)
+	assert.Zero(t, picks[workers[2]])
+	assert.Greater(t, picks[workers[0]]+picks[workers[1]], picks[workers[3]])
+
+	assert.Equal(t, workers[3], b.Pick(workers[3:], ""))
+}
+
+// Prueba que la misma llave vaya al mismo worker y que al salir un worker solo
+// cambien de dueño sus llaves
+func TestConsistentHash(t *testing.T) {
+	workers := workersDePrueba(1, 1, 1)
+	b, _ := NewBalancer(StrategyHash)
+
+	owners := make(map[string]*Worker)
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("archivo%d.txt", i)
+		owners[key] = b.Pick(workers, key)
+		assert.Equal(t, owners[key], b.Pick(workers, key))
+	}
+	picks := make(map[*Worker]int)
+	for _, w := range owners {
+		picks[w]++
+	}
+	for _, w := range workers {
+		assert.Greater(t, picks[w], 50, "reparto desbalanceado para %s", w.URL)
+	}
+
+	// El orden de los candidatos no cambia el dueño
+	reversed := []*Worker{workers[2], workers[1], workers[0]}
+	for key, owner := range owners {
+		assert.Equal(t, owner, b.Pick(reversed, key))
+	}
+
+	remaining := workers[:2]
+	for key, owner := range owners {
+		if owner != workers[2] {
+			assert.Equal(t, owner, b.Pick(remaining, key))
+		}
+	}
+}
+
+func TestNewBalancerDesconocido(t *testing.T) {
+	_, err := NewBalancer("random")
+	assert.Error(t, err)
+	_, err = NewBalancers("least_active:name")
+	assert.Error(t, err)
+}
+
+func TestBalancersConfigure(t *testing.T) {
+	bs, err := NewBalancers("least")
+	assert.NoError(t, err)
+	assert.NoError(t, bs.Configure("/createfile=hash:name, /fibonacci=p2c"))
+	assert.Error(t, bs.Configure("/hash"))
+
+	assert.Equal(t, StrategyHash, bs.For("/createfile").balancer.Name())
+	assert.Equal(t, "name", bs.For("/createfile").keyParam)
+	assert.Equal(t, StrategyP2C, bs.For("/fibonacci").balancer.Name())
+	assert.Equal(t, StrategyLeastActive, bs.For("/ping").balancer.Name())
+
+	snapshot := bs.Snapshot()
+	assert.Equal(t, "least_active", snapshot["default"])
+	assert.Equal(t, map[string]string{"/createfile": "hash:name", "/fibonacci": "p2c"}, snapshot["routes"])
+
+	bs.Reset("/fibonacci")
+	assert.Equal(t, StrategyLeastActive, bs.For("/fibonacci").balancer.Name())
+}
+
+// Prueba el cambio de estrategia en caliente con /admin/balancer
+func TestAdminBalancer(t *testing.T) {
+	d := newDispatcher()
+	get := []string{"GET"}
+	for _, url := range []string{"worker1:8080", "worker2:8080", "worker3:8080"} {
+		registrar(t, d, routes.Capabilities{URL: url, MaxConcurrency: 2,
+			Routes: []routes.Route{{Path: "/createfile", Methods: get, PoolSize: 2}}})
+	}
+
+	resp := enviar(t, d, "POST", "/admin/balancer?route=/createfile&strategy=hash:name", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	var config struct {
+		Default string
+		Routes  map[string]string
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body, &config))
+	assert.Equal(t, DefaultStrategy, config.Default)
+	assert.Equal(t, "hash:name", config.Routes["/createfile"])
+
+	// Con hash sobre name el mismo archivo siempre va al mismo worker aunque cambie el contenido
+	owner := seleccionarWorkerPara(d, "/createfile", map[string]string{"name": "a.txt", "content": "1"})
+	for i := 0; i < 5; i++ {
+		params := map[string]string{"name": "a.txt", "content": fmt.Sprint(i)}
+		assert.Equal(t, owner, seleccionarWorkerPara(d, "/createfile", params))
+	}
+
+	assert.Equal(t, 400, enviar(t, d, "POST", "/admin/balancer?route=/createfile&strategy=random", nil).StatusCode)
+	assert.Equal(t, 400, enviar(t, d, "POST", "/admin/balancer", nil).StatusCode)
+	assert.Equal(t, 405, enviar(t, d, "DELETE", "/admin/balancer", nil).StatusCode)
+
+	resp = enviar(t, d, "POST", "/admin/balancer?route=*&strategy=wrr", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.Unmarshal(resp.Body, &config))
+	assert.Equal(t, StrategyWeighted, config.Default)
+	assert.Equal(t, StrategyWeighted, d.Balancers.For("/ping").balancer.Name())
+}
+
+// Prueba que un archivo se cree y se borre en el mismo worker y que al entrar un
+// worker nuevo solo se muevan los archivos que pasan a ser suyos
+func TestArchivosPorHash(t *testing.T) {
+	d := newDispatcher()
+	get := []string{"GET"}
+	fileRoutes := []routes.Route{{Path: "/createfile", Methods: get, PoolSize: 3}, {Path: "/deletefile", Methods: get, PoolSize: 3}}
+	for _, url := range []string{"worker1:8080", "worker2:8080", "worker3:8080"} {
+		registrar(t, d, routes.Capabilities{URL: url, MaxConcurrency: 3, Routes: fileRoutes})
+	}
+
+	owners := make(map[string]*Worker)
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("archivo%d.txt", i)
+		owners[name] = seleccionarWorkerPara(d, "/createfile", map[string]string{"name": name, "content": "hola"})
+		assert.Equal(t, owners[name], seleccionarWorkerPara(d, "/deletefile", map[string]string{"name": name}))
+	}
+
+	// Un sospechoso conserva sus archivos
+	d.markSuspect(d.Workers[0])
+	for name, owner := range owners {
+		assert.Equal(t, owner, seleccionarWorkerPara(d, "/deletefile", map[string]string{"name": name}))
+	}
+
+	registrar(t, d, routes.Capabilities{URL: "worker4:8080", MaxConcurrency: 3, Routes: fileRoutes})
+	worker4 := d.Workers[3]
+	moved := 0
+	for name, owner := range owners {
+		now := seleccionarWorkerPara(d, "/deletefile", map[string]string{"name": name})
+		if now != owner {
+			assert.Equal(t, worker4, now, "%s se movió entre workers viejos", name)
+			moved++
+		}
+	}
+	assert.Greater(t, moved, 0)
+	assert.Less(t, moved, 100)
+
+	resp := enviar(t, d, "GET", "/admin/owner?name=archivo7.txt", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	var owner struct {
+		Route    string
+		Strategy string
+		Worker   string
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body, &owner))
+	assert.Equal(t, "/createfile", owner.Route)
+	assert.Equal(t, "hash:name", owner.Strategy)
+	assert.Equal(t, seleccionarWorkerPara(d, "/createfile", map[string]string{"name": "archivo7.txt"}).URL, owner.Worker)
+
+	assert.Equal(t, 400, enviar(t, d, "GET", "/admin/owner?route=/deletefile", nil).StatusCode)
+	assert.Equal(t, 409, enviar(t, d, "GET", "/admin/owner?route=/fibonacci&name=x", nil).StatusCode)
+}
@@ -0,0 +1,203 @@
+// connpool.go (en módulo dispatcher)
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"http-shared/httpmsg"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	MaxIdleConnsPerWorker = 8                // Conexiones libres que se guardan por worker
+	IdleConnTimeout       = 25 * time.Second // Menor que el IdleTimeout del worker (30s)
+)
+
+// ConnPool reutiliza conexiones HTTP/1.1 keep-alive hacia los workers para que
+// los trabajos que reparten chunks no paguen un handshake TCP por cada chunk
+type ConnPool struct {
+	mu          sync.Mutex
+	idle        map[string][]*pooledConn // Conexiones libres por dirección del worker
+	maxIdle     int
+	idleTimeout time.Duration
+	dialTimeout time.Duration
+}
+
+type pooledConn struct {
+	net.Conn
+	reader   *bufio.Reader
+	lastUsed time.Time
+}
+
+func NewConnPool(maxIdle int, idleTimeout, dialTimeout time.Duration) *ConnPool {
+	return &ConnPool{
+		idle:        make(map[string][]*pooledConn),
+		maxIdle:     maxIdle,
+		idleTimeout: idleTimeout,
+		dialTimeout: dialTimeout,
+	}
+}
+
+// Do envía una consulta idempotente, como /ping o /routes, al worker en addr y lee la
+// respuesta completa. timeout limita toda la operación; 0 significa sin límite.
+func (p *ConnPool) Do(addr, method, target string, header httpmsg.Header, body []byte, timeout time.Duration) (*httpmsg.Response, error) {
+	return p.DoContext(context.Background(), addr, method, target, header, body, timeout, true)
+}
+
+// DoContext es como Do pero corta la operación cuando ctx se cancela o vence su plazo.
+// idempotent indica si la solicitud se puede reenviar por otra conexión, ver staleConn.
+func (p *ConnPool) DoContext(ctx context.Context, addr, method, target string, header httpmsg.Header, body []byte, timeout time.Duration, idempotent bool) (*httpmsg.Response, error) {
+	if header == nil {
+		header = httpmsg.Header{}
+	}
+	if !header.Has("Host") {
+		header.Set("Host", strings.Split(addr, ":")[0])
+	}
+
+	for attempt := 0; ; attempt++ {
+		conn, reused, err := p.get(ctx, addr)
+		if err != nil {
+			return nil, fmt.Errorf("error conectando a worker %s: %w", addr, err)
+		}
+
+		resp, err := p.roundTrip(ctx, conn, method, target, header, body, timeout)
+		if err == nil {
+			if ctx.Err() != nil {
+				conn.Close() // Quedó con el plazo vencido por la cancelación
+			} else {
+				p.put(addr, conn, resp)
+			}
+			return resp, nil
+		}
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("solicitud a worker %s interrumpida: %w", addr, ctx.Err())
+		}
+
+		// Una conexión reutilizada pudo haber sido cerrada por el worker mientras estaba libre,
+		// en ese caso se reintenta una sola vez con una conexión nueva
+		if reused && attempt == 0 && idempotent && staleConn(err) {
+			continue
+		}
+		return nil, fmt.Errorf("error comunicándose con worker %s: %w", addr, err)
+	}
+}
+
+func (p *ConnPool) roundTrip(ctx context.Context, conn *pooledConn, method, target string, header httpmsg.Header, body []byte, timeout time.Duration) (*httpmsg.Response, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	// Al cancelar ctx se vence el plazo de la conexión para destrabar la lectura.
+	// Se espera a la goroutine para que no toque la conexión después de devolverla al pool.
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		exited := make(chan struct{})
+		defer func() {
+			close(stop)
+			<-exited
+		}()
+		go func() {
+			defer close(exited)
+			select {
+			case <-ctx.Done():
+				conn.SetDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+	}
+	if err := httpmsg.WriteRequest(conn, method, target, header, body); err != nil {
+		return nil, &connClosedError{err}
+	}
+	if _, err := conn.reader.Peek(1); err != nil {
+		return nil, &connClosedError{err}
+	}
+	return httpmsg.ReadResponse(conn.reader, httpmsg.DefaultLimits)
+}
+
+// connClosedError es un error de la conexión antes del primer byte de la respuesta
+type connClosedError struct{ err error }
+
+func (e *connClosedError) Error() string { return e.err.Error() }
+func (e *connClosedError) Unwrap() error { return e.err }
+
+// staleConn indica si err muestra que el worker cerró la conexión antes de atender la
+// solicitud: falló la escritura o la conexión se cerró (EOF o reset) sin ningún byte de
+// respuesta. Un plazo vencido nunca cuenta: el worker pudo haber recibido la solicitud.
+func staleConn(err error) bool {
+	var closed *connClosedError
+	if !errors.As(err, &closed) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// get retorna una conexión libre y vigente o abre una nueva
+func (p *ConnPool) get(ctx context.Context, addr string) (*pooledConn, bool, error) {
+	p.mu.Lock()
+	conns := p.idle[addr]
+	for len(conns) > 0 {
+		conn := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		if time.Since(conn.lastUsed) < p.idleTimeout {
+			p.idle[addr] = conns
+			p.mu.Unlock()
+			return conn, true, nil
+		}
+		conn.Close()
+	}
+	p.idle[addr] = conns
+	p.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: p.dialTimeout}
+	raw, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, false, err
+	}
+	return &pooledConn{Conn: raw, reader: bufio.NewReader(raw)}, false, nil
+}
+
+// put devuelve la conexión al pool si el worker no pidió cerrarla
+func (p *ConnPool) put(addr string, conn *pooledConn, resp *httpmsg.Response) {
+	if resp.Close {
+		conn.Close()
+		return
+	}
+	conn.lastUsed = time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle[addr]) >= p.maxIdle {
+		conn.Close()
+		return
+	}
+	p.idle[addr] = append(p.idle[addr], conn)
+}
+
+// CloseWorker cierra las conexiones libres hacia un worker, por ejemplo cuando deja de responder
+func (p *ConnPool) CloseWorker(addr string) {
+	p.mu.Lock()
+	conns := p.idle[addr]
+	delete(p.idle, addr)
+	p.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"http-shared/httpmsg"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeWorker levanta un worker mínimo con keep-alive que responde el path solicitado.
+// Si closeAfterFirst es true cierra cada conexión después de la primera respuesta.
+func fakeWorker(t *testing.T, closeAfterFirst bool) (string, *int32) {
+	tw := newTestWorker(t, func(conn net.Conn, req *httpmsg.Request, body []byte) bool {
+		payload := req.Path + string(body)
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(payload), payload)
+		return !closeAfterFirst
+	})
+	return tw.addr, &tw.accepted
+}
+
+// Prueba que varias solicitudes al mismo worker reutilicen una sola conexión
+func TestConnPoolReutilizaConexion(t *testing.T) {
+	addr, accepted := fakeWorker(t, false)
+	pool := NewConnPool(2, time.Minute, time.Second)
+
+	for i := 0; i < 5; i++ {
+		resp, err := pool.Do(addr, "POST", "/map", nil, []byte(fmt.Sprintf("-%d", i)), time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("/map-%d", i), string(resp.Body))
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(accepted))
+}
+
+// Prueba que una conexión cerrada por el worker mientras estaba libre se reemplace sin error
+func TestConnPoolReintentaConexionCerrada(t *testing.T) {
+	addr, accepted := fakeWorker(t, true)
+	pool := NewConnPool(2, time.Minute, time.Second)
+
+	for i := 0; i < 3; i++ {
+		resp, err := pool.Do(addr, "GET", "/ping", nil, nil, time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, "/ping", string(resp.Body))
+		// Dar tiempo a que el worker cierre su lado de la conexión
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(accepted))
+}
+
+// Prueba que las conexiones vencidas no se reutilicen
+func TestConnPoolDescartaConexionVencida(t *testing.T) {
+	addr, accepted := fakeWorker(t, false)
+	pool := NewConnPool(2, 10*time.Millisecond, time.Second)
+
+	_, err := pool.Do(addr, "GET", "/ping", nil, nil, time.Second)
+	assert.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = pool.Do(addr, "GET", "/ping", nil, nil, time.Second)
+	assert.NoError(t, err)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(accepted))
+}
+
+// stallingWorker responde /ping y, en cualquier otra ruta, lee la solicitud, espera stall
+// y cierra la conexión sin responder. Retorna cuántas solicitudes recibió.
+func stallingWorker(t *testing.T, stall time.Duration) (string, *int32) {
+	var received int32
+	tw := newTestWorker(t, func(conn net.Conn, req *httpmsg.Request, body []byte) bool {
+		atomic.AddInt32(&received, 1)
+		if req.Path != "/ping" {
+			time.Sleep(stall)
+			return false
+		}
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+		return true
+	})
+	return tw.addr, &received
+}
+
+// Prueba que una solicitud que el worker recibió no se reenvíe: ni una ruta no idempotente
+// cuando el worker cierra la conexión, ni ninguna cuando vence el plazo
+func TestConnPoolNoReenviaSolicitudRecibida(t *testing.T) {
+	addr, received := stallingWorker(t, 50*time.Millisecond)
+	pool := NewConnPool(2, time.Minute, time.Second)
+
+	_, err := pool.Do(addr, "GET", "/ping", nil, nil, time.Second)
+	assert.NoError(t, err)
+	_, err = pool.DoContext(context.Background(), addr, "POST", "/appendfile?name=a", nil, []byte("x"), time.Second, false)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(received), "/appendfile debe llegar una sola vez")
+
+	addr, received = stallingWorker(t, 200*time.Millisecond)
+	_, err = pool.Do(addr, "GET", "/ping", nil, nil, time.Second)
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = pool.DoContext(context.Background(), addr, "GET", "/fibonacci?num=30", nil, nil, 50*time.Millisecond, true)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+	time.Sleep(250 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(received), "un plazo vencido no se reintenta")
+}
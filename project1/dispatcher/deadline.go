@@ -0,0 +1,142 @@
+// deadline.go (en módulo dispatcher)
+package main
+
+import (
+	"context"
+	"fmt"
+	"http-shared/httpmsg"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultRequestTimeout = WorkerRequestTimeout // Plazo de las rutas sin uno propio
+	MaxRequestTimeout     = 10 * time.Minute     // Tope del plazo que puede pedir el cliente
+	TimeoutParam          = "timeout"
+)
+
+// Plazos por defecto de las rutas que tardan lo que pida el cliente; ROUTE_TIMEOUTS los reemplaza
+var defaultRouteTimeouts = map[string]time.Duration{
+	"/simulate":    time.Minute,
+	"/sleep":       time.Minute,
+	"/loadtest":    time.Minute,
+	"/countwords":  time.Minute,
+	"/calculatepi": time.Minute,
+	"/topwords":    time.Minute,
+}
+
+// Timeouts guarda el plazo por defecto de cada ruta. Se arma al iniciar y después solo se lee.
+type Timeouts struct {
+	Default time.Duration
+	routes  map[string]time.Duration
+}
+
+func NewTimeouts(def time.Duration) *Timeouts {
+	ts := &Timeouts{Default: def, routes: make(map[string]time.Duration)}
+	for route, timeout := range defaultRouteTimeouts {
+		ts.routes[route] = timeout
+	}
+	return ts
+}
+
+// Configure aplica una lista "ruta=plazo", por ejemplo "/simulate=2m,/fibonacci=500ms"
+func (ts *Timeouts) Configure(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.Index(item, "=")
+		if i <= 0 {
+			return fmt.Errorf("plazo inválido %q, use ruta=plazo", item)
+		}
+		timeout, err := httpmsg.ParseTimeout(item[i+1:])
+		if err != nil {
+			return err
+		}
+		ts.routes[strings.TrimSpace(item[:i])] = timeout
+	}
+	return nil
+}
+
+// For retorna el plazo por defecto de la ruta
+func (ts *Timeouts) For(route string) time.Duration {
+	if timeout, ok := ts.routes[route]; ok {
+		return timeout
+	}
+	return ts.Default
+}
+
+// Request retorna el plazo de la solicitud: el que pide el cliente con el parámetro
+// timeout o el encabezado Timeout, o el de la ruta. Nunca pasa de MaxRequestTimeout.
+func (ts *Timeouts) Request(req *httpmsg.Request, route string, query httpmsg.Values) (time.Duration, error) {
+	value := query.Get(TimeoutParam)
+	if value == "" {
+		value = req.Header.Get(httpmsg.TimeoutHeader)
+	}
+	if value == "" {
+		return ts.For(route), nil
+	}
+	timeout, err := httpmsg.ParseTimeout(value)
+	if err != nil {
+		return 0, err
+	}
+	if timeout > MaxRequestTimeout {
+		timeout = MaxRequestTimeout
+	}
+	return timeout, nil
+}
+
+// timeoutsFromEnv arma los plazos con REQUEST_TIMEOUT (plazo por defecto) y
+// ROUTE_TIMEOUTS (por ejemplo "/simulate=2m,/fibonacci=500ms")
+func timeoutsFromEnv() *Timeouts {
+	def := DefaultRequestTimeout
+	if v := os.Getenv("REQUEST_TIMEOUT"); v != "" {
+		if timeout, err := httpmsg.ParseTimeout(v); err == nil {
+			def = timeout
+		} else {
+			log.Printf("REQUEST_TIMEOUT inválido, se usa %v: %v", def, err)
+		}
+	}
+	ts := NewTimeouts(def)
+	if err := ts.Configure(os.Getenv("ROUTE_TIMEOUTS")); err != nil {
+		log.Printf("ROUTE_TIMEOUTS inválido: %v", err)
+	}
+	return ts
+}
+
+// settle espera a que ctx termine si su plazo ya pasó. El plazo de lectura de la conexión
+// al worker vence junto con el contexto y su error puede llegar antes que ctx.Err().
+func settle(ctx context.Context) {
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		<-ctx.Done()
+	}
+}
+
+// watchClient cancela la solicitud si el cliente cierra la conexión antes de recibir
+// la respuesta. Lo que el cliente envíe después de la solicitud se descarta.
+// Hay que llamar a stop antes de cerrar la conexión.
+func watchClient(conn net.Conn, cancel context.CancelFunc) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 512)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					return
+				}
+				log.Printf("El cliente cerró la conexión, se cancela la solicitud")
+				cancel()
+				return
+			}
+		}
+	}()
+	return func() {
+		conn.SetReadDeadline(time.Now())
+		<-done
+	}
+}
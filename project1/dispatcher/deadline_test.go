@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dispatcherConLento(t *testing.T) (*Dispatcher, *gateWorker) {
+	d := newDispatcher()
+	gw := newGateWorker(t)
+	registrar(t, d, routes.Capabilities{URL: gw.addr, MaxConcurrency: 2,
+		Routes: []routes.Route{{Path: "/lento", Methods: []string{"GET"}}}})
+	return d, gw
+}
+
+// Prueba que el plazo pedido por el cliente llegue al worker en X-Request-Deadline
+// y que al vencer se responda 504 sin esperar al worker
+func TestPlazoDelCliente(t *testing.T) {
+	d, gw := dispatcherConLento(t)
+
+	start := time.Now()
+	resp := enviar(t, d, "GET", "/lento?timeout=200ms", nil)
+	assert.Equal(t, 504, resp.StatusCode)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	req := gw.lastRequest()
+	assert.Equal(t, "/lento", req.Target, "el parámetro timeout no se reenvía")
+	deadline, ok, err := httpmsg.Deadline(req.Header)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.WithinDuration(t, start.Add(200*time.Millisecond), deadline, 100*time.Millisecond)
+	esperarHasta(t, "El worker no se liberó al vencer el plazo", func() bool { return activeOf(d.Workers[0]) == 0 })
+}
+
+// Prueba el encabezado Timeout, el plazo por defecto de la ruta y un valor inválido
+func TestPlazoPorRuta(t *testing.T) {
+	d, gw := dispatcherConLento(t)
+	assert.NoError(t, d.Timeouts.Configure("/lento=300ms"))
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	go d.HandleConnection(conn)
+	header := httpmsg.Header{}
+	header.Set(httpmsg.TimeoutHeader, "1")
+	start := time.Now()
+	go httpmsg.WriteRequest(client, "GET", "/lento", header, nil)
+	resp, err := httpmsg.ReadResponse(bufio.NewReader(client), httpmsg.DefaultLimits)
+	assert.NoError(t, err)
+	assert.Equal(t, 504, resp.StatusCode)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second), "el encabezado reemplaza al plazo de la ruta")
+
+	deadline, _, _ := httpmsg.Deadline(gw.lastRequest().Header)
+	start = time.Now()
+	assert.Equal(t, 504, enviar(t, d, "GET", "/lento", nil).StatusCode)
+	newDeadline, _, _ := httpmsg.Deadline(gw.lastRequest().Header)
+	assert.NotEqual(t, deadline, newDeadline)
+	assert.WithinDuration(t, start.Add(300*time.Millisecond), newDeadline, 100*time.Millisecond)
+
+	assert.Equal(t, 400, enviar(t, d, "GET", "/lento?timeout=pronto", nil).StatusCode)
+	assert.Equal(t, time.Minute, d.Timeouts.For("/simulate"))
+	assert.Equal(t, DefaultRequestTimeout, d.Timeouts.For("/fibonacci"))
+}
+
+// Prueba que si el cliente cierra la conexión se corte la llamada al worker
+func TestClienteSeVa(t *testing.T) {
+	d, gw := dispatcherConLento(t)
+
+	client, conn := net.Pipe()
+	go d.HandleConnection(conn)
+	go httpmsg.WriteRequest(client, "GET", "/lento?timeout=1m", nil, nil)
+	esperarHasta(t, "La solicitud no llegó al worker", func() bool { return gw.inFlight() == 1 })
+	client.Close()
+
+	esperarHasta(t, "El worker siguió ocupado después de que el cliente se fue", func() bool {
+		return activeOf(d.Workers[0]) == 0
+	})
+}
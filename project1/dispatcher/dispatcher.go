@@ -2,12 +2,16 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"http-servidor/utils"
+	"http-shared/httpmsg"
+	"http-shared/routes"
 	"io"
 	"log"
 	"net"
-	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -15,11 +19,17 @@ import (
 
 const (
 	DispatcherPort      = ":8080"
-	HealthCheckInterval = 10 * time.Second
+	HealthCheckInterval = 1 * time.Second // Revisión de heartbeats y /ping de workers sin heartbeat
 	WorkerTimeout       = 10 * time.Second
-	EstrategiaRed       = 1 //cambiar a 2 si se quiere usar least loaded
-	primero             = 1 // Usar round robin para seleccionar el primer worker
+	WorkerRequestTimeout = 5 * time.Second // Límite de una solicitud síncrona reenviada a un worker
 	IdentificadorWorker = 0 // Identificador del worker para el health check
+	RoutesRetries       = 5 // Intentos para obtener /routes de un worker recién registrado
+	RoutesRetryInterval = 2 * time.Second
+	DefaultWorkerCapacity = 3 // Capacidad de los workers que se registran sin capacidades: un pool de un worker base
+	MaxRetries          = 3 // Reintentos de una tarea idempotente en otros workers
+	RetryBaseDelay      = 100 * time.Millisecond
+	RetryMaxDelay       = 2 * time.Second
+	NoTimeout           time.Duration = -1 // Request.Timeout: la llamada solo termina con su contexto
 )
 
 var workers = make(map[string]Worker)
@@ -29,13 +39,26 @@ type Dispatcher struct {
 	ID			 	int
 	Workers         []*Worker
 	TasksChan       chan *Task
-	Tasks           sync.Map // Concurrent map para tareas activas [taskID]*Task
+	Tasks           sync.Map // Trabajos asíncronos [jobID]*Job, ver jobs.go
 	Listener        net.Listener
 	Mu              sync.RWMutex
 	DoneChan        chan struct{}
-	Commands        []string // Pool de workers por comando
+	Routes          *routes.Table // Rutas que publican los workers en /routes
 	Metrics         *DispatcherMetrics
-	lastWorkerIndex int
+	Pool            *ConnPool // Conexiones keep-alive hacia los workers
+	Balancers       *Balancers    // Estrategia de balanceo por ruta
+	Replication     *Replication  // Copias de los archivos entre workers
+	WAL             *WAL          // Log de los trabajos asíncronos, nil si no se guardan en disco
+	Admission       *Admission    // Límite de conexiones y de tareas en cola por ruta
+	Timeouts        *Timeouts     // Plazo por defecto de cada ruta
+	MapReduce       *MapReduceEngine // Trabajos que se reparten entre los workers con /map
+	RegistryPath    string        // Archivo donde se guardan los workers al apagar (REGISTRY_PATH)
+	Epoch           string        // Identifica esta instancia, cambia en cada arranque
+	inFlight        int64         // Conexiones en curso, se usa con atomic
+	asyncJobs       int64         // Trabajos asíncronos en curso, se usa con atomic
+	shuttingDown    int32         // 1 desde que empieza Shutdown
+	nextWorkerID    int // Los IDs no se reutilizan aunque se eliminen workers
+	jobSeq          int64 // Último ID de trabajo asignado
 
 }
 
@@ -49,12 +72,11 @@ type DispatcherMetrics struct {
 	mu sync.Mutex
 }
 
-
-// Estructura para la respuesta de conteo de palabras de un worker (reutilizada para Pi)
-type WorkerResult struct { // Renombrada para ser más genérica
-	WorkerID string
-	Count    int   // Puede ser wordCount o pointsInCircle
-	Error    error
+// total lee TotalRequests, que también se usa como ID de las tareas
+func (m *DispatcherMetrics) total() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.TotalRequests
 }
 
 
@@ -74,22 +96,16 @@ func newDispatcher() *Dispatcher {
 		Workers:   make([]*Worker, 0),
 		TasksChan: make(chan *Task, 1000), // Canal para recibir tareas
 		DoneChan:  make(chan struct{}),
-		Commands: []string{
-			"/help",
-			"/timestamp",
-			"/fibonacci",
-			"/createfile",
-			"/deletefile",
-			"/reverse",
-			"/toupper",
-			"/random",
-			"/hash",
-			"/simulate",
-			"/sleep",
-			"/loadtest",
-			"/wordcount", // Nuevo comando para conteo de palabras
-		},
 		Metrics: metrics,
+		Pool:    NewConnPool(MaxIdleConnsPerWorker, IdleConnTimeout, WorkerTimeout),
+		Balancers: balancersFromEnv(),
+		Replication: replicationFromEnv(),
+		WAL:         walFromEnv(),
+		Admission:   admissionFromEnv(),
+		Timeouts:    timeoutsFromEnv(),
+		RegistryPath: registryPathFromEnv(),
+		Epoch:        newEpoch(),
+		MapReduce:    NewMapReduceEngine(wordCountJob, piJob, topWordsJob),
 	}
 
 	return dispatcher
@@ -101,85 +117,149 @@ func (d *Dispatcher) HandleConnection(conn net.Conn) {
 	print("Nueva conexión aceptada\n")
 	defer conn.Close()
 
-	// Utiliza bufio.Reader para leer la solicitud línea por línea de forma eficiente
 	reader := bufio.NewReader(conn)
-
-	// Leer la primera línea de la solicitud (e.g., "GET /path HTTP/1.1")
-	requestLine, err := reader.ReadString('\n')
+	req, err := httpmsg.ReadRequest(reader, httpmsg.DefaultLimits)
 	if err != nil {
-		log.Printf("Error leyendo request line: %v", err)
-		utils.SendResponse(conn, "400 Bad Request", "Error leyendo la solicitud HTTP")
+		if err != io.EOF {
+			log.Printf("Error leyendo la solicitud: %v", err)
+			utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		}
 		return
 	}
+	d.handleRequest(conn, req)
+}
 
-	method, path := utils.ParseRequestLine(requestLine)
+// handleRequest atiende una solicitud ya leída según su ruta
+func (d *Dispatcher) handleRequest(conn net.Conn, req *httpmsg.Request) {
+	method := req.Method
+	route, query, err := httpmsg.ParseRoute(req.Target)
+	if err != nil {
+		utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+	params := query.Map()
 
-	route, params := utils.ParseRoute(path)
-	
 	if route == "/suscribir" {
-		
 		log.Println("Recibida solicitud de suscripción de worker.")
-		d.suscribirHandler(conn, params) // Pasamos nil porque no estamos usando el request completo
+		d.suscribirHandler(conn, req, params)
+		return
+	}
+	if route == "/desuscribir" {
+		log.Println("Recibida solicitud de desuscripción de worker.")
+		d.desuscribirHandler(conn, params)
+		return
+	}
+	if route == "/heartbeat" {
+		d.heartbeatHandler(conn, req)
 		return
 	}
 	if route == "/workers" {
 		workerStatus(conn, d)
 		return
 	}
-
-	// Leer los encabezados HTTP
-	headers := make(map[string]string)
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			log.Printf("Error leyendo headers: %v", err)
-			utils.SendResponse(conn, "400 Bad Request", "Error leyendo encabezados HTTP")
-			return
-		}
-		if strings.TrimSpace(line) == "" { // Línea vacía indica fin de encabezados
-			break
-		}
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			headers[key] = value
-		}
+	if route == "/admin/balancer" {
+		d.balancerHandler(conn, method, params)
+		return
+	}
+	if route == "/admin/owner" {
+		d.ownerHandler(conn, params)
+		return
+	}
+	if route == "/admin/repair" {
+		d.repairHandler(conn, method)
+		return
+	}
+	if route == "/mapreduce" || strings.HasPrefix(route, "/mapreduce/") {
+		d.mapReduceHandler(conn, method, route)
+		return
 	}
-	
+	if route == "/jobs" || strings.HasPrefix(route, "/jobs/") {
+		d.jobsHandler(conn, req, route)
+		return
+	}
+
 	// Sumar a las metricas
 	d.Metrics.mu.Lock()
 	d.Metrics.TotalRequests++
 	d.Metrics.mu.Unlock()
 
+	timeout, err := d.Timeouts.Request(req, route, query)
+	if err != nil {
+		utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+	delete(query, TimeoutParam) // Los workers reciben el plazo en X-Request-Deadline
+
+	// Con ?async=true se responde el ID del trabajo y la solicitud se atiende en segundo plano
+	if isAsync(query) {
+		d.submitJob(conn, req, route, query)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// Con cuerpo no se vigila la conexión: dispatch todavía lo lee
+	if req.ContentLength == 0 && !req.Chunked {
+		defer watchClient(conn, cancel)()
+	}
+	d.dispatch(ctx, conn, req, route, query)
+}
 
-	if route == "/countwords" && method == "POST" {
-		log.Println("Received /countwords POST request.")
-		d.handleWordCount(conn, method, route, params, headers, reader)
-		return 
+// dispatch atiende una solicitud de cliente ya leída y escribe la respuesta en conn.
+// conn es la conexión del cliente o, para un trabajo asíncrono, la que guarda el resultado.
+// Cancelar ctx interrumpe las llamadas a los workers.
+func (d *Dispatcher) dispatch(ctx context.Context, conn net.Conn, req *httpmsg.Request, route string, query httpmsg.Values) {
+	method := req.Method
+	params := query.Map()
+	var err error
+
+	// Trabajos que el dispatcher reparte entre los workers, ver mapreduce.go
+	if job, ok := d.MapReduce.Lookup(method, route); ok {
+		log.Printf("Recibida solicitud %s %s de map-reduce.", method, route)
+		d.serveMapReduce(ctx, conn, req, job, params)
+		return
 	}
 
-	// Cálculo de Pi (GET con parámetros)
-	if route == "/calculatepi" && method == "GET" {
-		log.Println("Received /calculatepi GET request.")
-		d.handleCalculatePi(conn, method, route, params)
+	// Validar la ruta con la tabla que publican los workers antes de ocupar uno
+	if err := d.validateRoute(method, route, query); err != nil {
+		utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
 		return
 	}
 
-	if method != "GET" {
+	if method != "GET" && method != "POST" {
 		utils.SendResponse(conn, "405 Method Not Allowed", "Solo se permite GET y POST")
 		return
 	}
 
+	var body []byte
+	if method == "POST" {
+		if body, err = req.ReadBody(); err != nil {
+			utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+			return
+		}
+	}
+
 	newRequest := Request{
 		Method: method,
 		Path:   route,
 		Params: params,
-		Done:   make(chan bool),
+		Header: forwardHeader(req.Header),
+		Body:   body,
+		Done:   make(chan bool, 1),
+		Ctx:    ctx,
+	}
+
+	if route == "/listfiles" {
+		d.handleListFiles(conn)
+		return
+	}
+	if d.Replication.Enabled() && isFileRoute(route) {
+		d.handleReplicated(conn, &newRequest)
+		return
 	}
 
 	newTask := Task{
-		ID:         d.Metrics.TotalRequests,
+		ID:         d.Metrics.total(),
 		Conn:       conn,
 		Request:    &newRequest,
 		Response:   nil,
@@ -189,307 +269,291 @@ func (d *Dispatcher) HandleConnection(conn net.Conn) {
 		Content:    "",
 	}
 
-	// agregar la tarea al canal de tareas
-	// Eliminarlo al obtener la respuesta para evitar que sea reenviada por el health check
-
-	worker := seleccionarWorker(d)
-	if worker == nil {
-		utils.SendResponse(conn, "503 Service Unavailable", "No hay workers disponibles")
+	worker, err := d.runTask(ctx, &newTask)
+	switch {
+	case worker == nil:
+		sendRetryLater(conn, err.Error())
 		d.Metrics.mu.Lock()
 		d.Metrics.RequestsFailed++
 		d.Metrics.mu.Unlock()
-		return
+	case ctx.Err() != nil:
+		log.Printf("Tarea %d interrumpida en worker %d: %v", newTask.ID, worker.ID, err)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			utils.SendResponse(conn, "504 Gateway Timeout", "El worker no respondió a tiempo")
+		} else {
+			utils.SendResponse(conn, "503 Service Unavailable", "Solicitud cancelada")
+		}
+	case err != nil:
+		conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\nError al comunicarse con el worker"))
+	default:
+		utils.SendWorkerResponse(conn, newTask.StatusCode, newTask.Header, newTask.Response)
+		log.Printf("Tarea %d completada por worker %d", newTask.ID, worker.ID)
 	}
+}
 
-	path = worker.URL + route
-	print("Ruta del worker: ", path, "\n")
-
-	if !d.checkWorkerStatus(worker) {
-        log.Printf("Worker %d (%s) marcado como inactivo", worker.ID, worker.URL)
-		log.Printf("Cantidad de %s tareas pendientes del worker %d", len(worker.taskQueue), worker.ID)
-		d.redistributeTasks(worker)
-        utils.SendResponse(conn, "503 Service Unavailable", "Worker no disponible")
-        return
-    }
-
-	
-	log.Printf("Enviando tarea %s a worker %d (%s)", newTask.ID, worker.ID, worker.URL)
+var (
+	errNoWorkers  = errors.New("No hay workers disponibles")
+	errWorkerBusy = errors.New("Worker saturado, intente de nuevo")
+)
 
-	worker.taskQueue <- &newTask
+// runTask envía la tarea a un worker. Si la ruta es idempotente (o la tarea pide Retry) y el worker no responde
+// o responde 5xx, la reenvía a otro worker que no la haya intentado, esperando
+// RetryBaseDelay antes del primer reintento y el doble en cada uno hasta RetryMaxDelay.
+// Se rinde después de MaxRetries reintentos y deja en la tarea la última respuesta.
+// Retorna el último worker que la recibió, o nil si ninguno pudo recibirla.
+func (d *Dispatcher) runTask(ctx context.Context, task *Task) (*Worker, error) {
+	route, params := task.Request.Path, task.Request.Params
+	if task.Request.Done == nil {
+		task.Request.Done = make(chan bool, 1)
+	}
+	retry := task.Request.Retry || d.retryable(route)
+	tried := make(map[*Worker]bool)
+	var last *Worker
+	lastErr := errNoWorkers
 
-	worker.mu.Lock()
-	worker.CompletedTasks++ // Incrementamos la carga del worker
-	worker.activeTasks++ // Incrementamos el contador de tareas activas
-	worker.mu.Unlock()
+	for {
+		worker := seleccionarWorkerExcepto(d, route, params, tried)
+		if worker == nil {
+			return last, lastErr
+		}
+		tried[worker] = true
+
+		// Una cola llena no recibió la tarea: se prueba otro worker sin contar un reintento
+		task.StatusCode, task.Header, task.Response = 0, nil, nil
+		if err := d.enqueue(worker, task); err == errRouteFull {
+			// Otro worker no cambia el límite de la ruta
+			if last == nil {
+				lastErr = err
+			}
+			return last, lastErr
+		} else if err != nil {
+			if last == nil {
+				lastErr = err
+			}
+			continue
+		}
+		log.Printf("Tarea %d en la cola del worker %d (%s), intento %d", task.ID, worker.ID, worker.URL, task.RetryCount+1)
+		err := task.wait()
+		settle(ctx)
+		if ctx.Err() != nil {
+			return worker, err
+		}
+		// Si el worker murió con la tarea en cola, otro worker pudo haberla atendido
+		if task.AssignedTo != nil {
+			worker = task.AssignedTo
+		}
+		last, lastErr = worker, err
+		if err != nil {
+			log.Printf("Error enviando tarea a worker %d: %v", worker.ID, err)
+			// No se prueba el worker aquí: queda sospechoso hasta su próximo heartbeat
+			d.markSuspect(worker)
+		} else if task.StatusCode < 500 {
+			return last, nil
+		}
+		if !retry || task.RetryCount >= MaxRetries {
+			return last, lastErr
+		}
 
-	log.Printf("Tareas %s asignadas al worker %d", len(worker.taskQueue), worker.ID)
-	err = d.sendToWorker(worker, &newTask)
-	if err != nil {
-		log.Printf("Error enviando tarea a worker %d: %v", worker.ID, err)
-		// Volver a verificar estado
-        if !d.checkWorkerStatus(worker) {
-            // Redistribuir tareas pendientes si es necesario
-            d.redistributeTasks(worker)
-        }
-		conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\nError al comunicarse con el worker"))
-		return
+		delay := retryDelay(task.RetryCount)
+		log.Printf("Tarea %d falló en worker %d, reintento %d en %v", task.ID, worker.ID, task.RetryCount+1, delay)
+		select {
+		case <-ctx.Done():
+			return last, lastErr
+		case <-time.After(delay):
+		}
+		task.RetryCount++
 	}
-
-	//log.Printf("respuesta enviada al cliente para tarea %s", newTask.Response)
-	//taskFinalizada := <-worker.taskQueue
-	worker.mu.Lock()
-	worker.activeTasks-- // Decrementamos el contador de tareas activas
-	worker.mu.Unlock()
-	//log.Printf("Tarea %s completada por worker %d y sacada de la cola", taskFinalizada.ID, worker.ID)
-	utils.SendResponse(conn, "200 OK", string(newTask.Response))
-	log.Printf("Tarea %s completada por worker %d", newTask.ID, worker.ID)
-	worker.cleanCompletedTasks() // Limpiar tareas completadas del worker
-
 }
 
-// revisa si el endpoint existe
-func (d *Dispatcher) isValidRoute(route string) bool {
-	for _, cmd := range d.Commands {
-		if route == cmd {
-			return true
-		}
+// retryDelay es la espera antes del reintento número attempt+1
+func retryDelay(attempt int) time.Duration {
+	delay := RetryBaseDelay
+	for i := 0; i < attempt && delay < RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > RetryMaxDelay {
+		delay = RetryMaxDelay
 	}
-	return false
+	return delay
 }
 
-func (d *Dispatcher) sendToWorker(worker *Worker, task *Task) error {
-	// Construir URL
-	url := fmt.Sprintf("http://%s%s", worker.URL, task.Request.Path)
-
-	// Crear solicitud HTTP
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("error creando request: %v", err)
-	}
+// retryable indica si una tarea de la ruta se puede reenviar a otro worker: los workers
+// la declararon idempotente y no se asigna a un dueño fijo con la estrategia hash
+func (d *Dispatcher) retryable(route string) bool {
+	d.Mu.RLock()
+	table := d.Routes
+	d.Mu.RUnlock()
 
-	// Añadir parámetros
-	q := req.URL.Query()
-	for key, value := range task.Request.Params {
-		q.Add(key, value)
-	}
-	req.URL.RawQuery = q.Encode()
+	r, ok := table.Lookup(route)
+	return ok && r.Idempotent && !d.Balancers.For(route).keyed()
+}
 
-	// Configurar headers
-	req.Header.Set("Host", worker.URL)
-	req.Header.Set("X-Request-ID", fmt.Sprintf("%d", task.ID))
+// revisa si el endpoint existe y si el método y los parámetros son válidos.
+// Mientras ningún worker haya publicado sus rutas se deja pasar todo y valida el worker.
+func (d *Dispatcher) validateRoute(method, route string, query httpmsg.Values) error {
+	d.Mu.RLock()
+	table := d.Routes
+	d.Mu.RUnlock()
 
-	// Bloquear worker para actualizar estado
-	worker.mu.Lock()
-	//worker.activeTasks++
-	task.Status = TaskProcessing
-	worker.mu.Unlock()
+	if table.Len() == 0 {
+		return nil
+	}
+	_, err := table.Check(method, route, query)
+	return err
+}
 
-	// Enviar solicitud con timeout
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+// fetchRoutes consulta /routes en el worker y agrega sus rutas a la tabla del dispatcher
+func (d *Dispatcher) fetchRoutes(worker *Worker) error {
+	resp, err := d.Pool.Do(worker.URL, "GET", "/routes", nil, nil, 5*time.Second)
 	if err != nil {
-		worker.mu.Lock()
-		worker.activeTasks--
-		worker.mu.Unlock()
-		
-		return fmt.Errorf("error enviando a worker: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	var responseBuilder strings.Builder
-	responseBuilder.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", resp.StatusCode, resp.Status))
-
-	for k, v := range resp.Header {
-		responseBuilder.WriteString(fmt.Sprintf("%s: %s\r\n", k, strings.Join(v, ", ")))
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("worker %s retornó %d en /routes", worker.URL, resp.StatusCode)
 	}
-	responseBuilder.WriteString("\r\n") // separa headers del body
 
-	// Leer la respuesta completa
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("error leyendo respuesta: %v", err)
+	var list []routes.Route
+	if err := json.Unmarshal(resp.Body, &list); err != nil {
+		return fmt.Errorf("tabla de rutas inválida de %s: %v", worker.URL, err)
 	}
-	responseBuilder.Write(body)
 
-	// Guardar la respuesta en la tarea
-	fullResponse := responseBuilder.String()
-	task.Response = []byte(fullResponse)
-	
+	d.Mu.Lock()
+	d.Routes = routes.NewTable(append(d.Routes.Routes(), list...))
+	d.Mu.Unlock()
 
 	worker.mu.Lock()
-	task.Response = body
-	task.Status = TaskCompleted
-	task.CompletedAt = time.Now()
-	//worker.activeTasks--
+	worker.routes = routes.NewTable(list)
 	worker.mu.Unlock()
-
-	_, err = task.Conn.Write([]byte(fullResponse))
-	if err != nil {
-		return fmt.Errorf("error escribiendo al cliente: %v", err)
-	}
-
-	// Forzar flush si es necesario
-	if conn, ok := task.Conn.(interface{ Flush() error }); ok {
-		conn.Flush()
-	}
-
+	log.Printf("Worker %d (%s) publicó %d rutas", worker.ID, worker.URL, len(list))
 	return nil
 }
 
-
-func (w *Worker) cleanCompletedTasks() {
-    w.mu.Lock()
-    defer w.mu.Unlock()
-    
-    // Limpiar queue sin bloquear
-    for {
-        select {
-        case <-w.taskQueue:
-            // Simplemente vaciar
-        default:
-            return
-        }
-    }
+// loadRoutes reintenta fetchRoutes porque el worker se registra antes de empezar a escuchar
+func (d *Dispatcher) loadRoutes(worker *Worker) {
+	for i := 0; i < RoutesRetries; i++ {
+		err := d.fetchRoutes(worker)
+		if err == nil {
+			return
+		}
+		log.Printf("No se pudieron obtener las rutas de %s (intento %d): %v", worker.URL, i+1, err)
+		time.Sleep(RoutesRetryInterval)
+	}
 }
 
+// Encabezados del cliente que se reenvían al worker
+var forwardedRequestHeaders = []string{"Range", "X-Priority", "X-API-Key"}
 
-// Envía una solicitud POST HTTP manual a un worker con el comando y el cuerpo de contenido.
-// Retorna el cuerpo de la respuesta del worker o un error.
-func (d *Dispatcher) sendPostToWorker(worker *Worker, command string, content string) (string, error) {
-	workerHost := strings.Split(worker.URL, ":")[0] // Obtener solo el host para el header Host
-	// workerPort := strings.Split(worker.URL, ":")[1] // Obtener el puerto
-
-	requestBody := []byte(content)
-	requestHeaders := []string{
-		fmt.Sprintf("POST %s HTTP/1.1", command),
-		fmt.Sprintf("Host: %s", workerHost),
-		fmt.Sprintf("Content-Type: text/plain"),
-		fmt.Sprintf("Content-Length: %d", len(requestBody)),
-		"Connection: close", // Indicar al worker que cierre la conexión después de la respuesta
-		"",                  // Línea vacía para separar headers del body
+// forwardHeader copia del cliente solo los encabezados que le importan al worker
+func forwardHeader(from httpmsg.Header) httpmsg.Header {
+	header := httpmsg.Header{}
+	for _, key := range forwardedRequestHeaders {
+		for _, value := range from.Values(key) {
+			header.Add(key, value)
+		}
 	}
-	fullRequest := strings.Join(requestHeaders, "\r\n") + "\r\n" + string(requestBody)
-
-	log.Printf("Full request (%s)", fullRequest)
-
-	// Bloquear worker para actualizar estado (esto se gestionará a un nivel superior si es una "tarea" general)
-	// Aquí solo estamos enviando la solicitud, la gestión de activeTasks del worker
-	// se hará en el handleWordCount o en un nivel de orquestación de tareas más general si se crea.
-	// Por ahora, lo mantenemos simple para esta función específica de comunicación.
+	return header
+}
 
-	// Establecer conexión TCP con el worker
-	workerConn, err := net.DialTimeout("tcp", worker.URL, WorkerTimeout)
-	if err != nil {
-		return "", fmt.Errorf("error conectando a worker %s: %w", worker.URL, err)
+// process atiende una tarea desde uno de los loops del worker y avisa al cliente
+// por Request.Done. Una tarea cuyo cliente ya se fue no se envía.
+func (d *Dispatcher) process(w *Worker, task *Task) {
+	d.dequeued(task)
+	w.recordWait(time.Since(task.queuedAt))
+	ctx := task.Request.context()
+	if err := ctx.Err(); err != nil {
+		task.err = err
+		task.done()
+		return
 	}
-	defer workerConn.Close()
 
-	// Enviar la solicitud HTTP al worker
-	_, err = workerConn.Write([]byte(fullRequest))
-	if err != nil {
-		return "", fmt.Errorf("error enviando solicitud a worker %s: %w", worker.URL, err)
-	}
+	w.mu.Lock()
+	w.CompletedTasks++ // Incrementamos la carga del worker
+	w.activeTasks++    // Incrementamos el contador de tareas activas
+	w.mu.Unlock()
+	task.AssignedTo = w
+	task.StartedAt = time.Now()
+	d.walAssigned(ctx, w)
 
-	// Leer la respuesta del worker
-	workerReader := bufio.NewReader(workerConn)
-	responseStatusLine, err := workerReader.ReadString('\n')
-	if err != nil {
-		return "", fmt.Errorf("error leyendo status line de worker %s: %w", worker.URL, err)
-	}
-	if !strings.Contains(responseStatusLine, "200 OK") {
-		// Leer el resto de la respuesta para el log de error
-		responseBody, _ := io.ReadAll(workerReader)
-		return "", fmt.Errorf("worker %s retornó status no OK: %s - %s", worker.URL, strings.TrimSpace(responseStatusLine), string(responseBody))
+	task.err = d.sendToWorker(w, task)
+	task.done()
+	d.releaseWorker(w)
+}
+
+func (d *Dispatcher) sendToWorker(worker *Worker, task *Task) error {
+	target := task.Request.Path
+	if len(task.Request.Params) > 0 {
+		target += "?" + httpmsg.FromMap(task.Request.Params).Encode()
 	}
 
-	// Leer y descartar headers del worker
-	for {
-		line, err := workerReader.ReadString('\n')
-		if err != nil {
-			return "", fmt.Errorf("error leyendo headers de worker %s: %w", worker.URL, err)
-		}
-		if strings.TrimSpace(line) == "" {
-			break // Fin de los headers
-		}
+	// Configurar headers
+	header := httpmsg.Header{}
+	for key, values := range task.Request.Header {
+		header[key] = values
 	}
+	header.Set("X-Request-ID", fmt.Sprintf("%d", task.ID))
 
-	log.Printf("Before reading the body of worker %s", worker.URL)
+	// Bloquear worker para actualizar estado
+	worker.mu.Lock()
+	task.Status = TaskProcessing
+	worker.mu.Unlock()
 
-	// Leer el cuerpo de la respuesta (el conteo de palabras)
-	wordCountBody, err := io.ReadAll(workerReader)
+	// Enviar solicitud con timeout por una conexión reutilizable
+	method := task.Request.Method
+	if method == "" {
+		method = "GET"
+	}
+	// Un contexto con plazo reemplaza al límite por defecto. El worker recibe el plazo
+	// en X-Request-Deadline para dejar de trabajar cuando venza.
+	ctx := task.Request.context()
+	timeout := task.Request.Timeout
+	if timeout == 0 {
+		timeout = WorkerRequestTimeout
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		httpmsg.SetDeadline(header, deadline)
+		timeout = 0
+	} else if timeout == NoTimeout {
+		timeout = 0
+	} else {
+		httpmsg.SetDeadline(header, time.Now().Add(timeout))
+	}
+	idempotent := task.Request.Retry || d.retryable(task.Request.Path)
+	resp, err := d.Pool.DoContext(ctx, worker.URL, method, target, header, task.Request.Body, timeout, idempotent)
 	if err != nil {
-		return "", fmt.Errorf("error leyendo body de worker %s: %w", worker.URL, err)
+		return fmt.Errorf("error enviando a worker: %v", err)
 	}
 
-	log.Printf("Result worker %s: %d", worker.URL, wordCountBody)
+	// Guardar la respuesta en la tarea, el cliente la recibe desde HandleConnection
+	worker.mu.Lock()
+	task.Response = resp.Body
+	task.StatusCode = resp.StatusCode
+	task.Header = resp.Header
+	task.Status = TaskCompleted
+	task.CompletedAt = time.Now()
+	worker.mu.Unlock()
 
-	return strings.TrimSpace(string(wordCountBody)), nil
+	return nil
 }
 
 
-
-// sendGetToWorker: Nueva función para enviar solicitudes GET manuales a un worker.
-// Retorna el cuerpo de la respuesta del worker o un error.
-func (d *Dispatcher) sendGetToWorker(worker *Worker, command string, params map[string]string) (string, error) {
-	workerHost := strings.Split(worker.URL, ":")[0]
-
-	// Construir los parámetros de la URL
-	queryParams := ""
-	if len(params) > 0 {
-		var q []string
-		for k, v := range params {
-			q = append(q, fmt.Sprintf("%s=%s", k, v))
-		}
-		queryParams = "?" + strings.Join(q, "&")
+// call envía una solicitud al worker a través de su cola, como cualquier otra tarea.
+// Retorna errWorkerBusy si la cola está llena.
+func (d *Dispatcher) call(worker *Worker, req *Request) (*httpmsg.Response, error) {
+	req.Done = make(chan bool, 1)
+	task := &Task{Request: req, Status: TaskPending, CreatedAt: time.Now()}
+	if !worker.enqueue(task) {
+		return nil, fmt.Errorf("%w: worker %d", errWorkerBusy, worker.ID)
 	}
-
-	requestHeaders := []string{
-		fmt.Sprintf("GET %s%s HTTP/1.1", command, queryParams),
-		fmt.Sprintf("Host: %s", workerHost),
-		"Connection: close", // Indicar al worker que cierre la conexión después de la respuesta
-		"",                  // Línea vacía final para separar headers del body (aunque no hay body en GET)
-	}
-	fullRequest := strings.Join(requestHeaders, "\r\n") + "\r\n"
-
-	workerConn, err := net.DialTimeout("tcp", worker.URL, WorkerTimeout)
-	if err != nil {
-		return "", fmt.Errorf("error conectando a worker %s: %w", worker.URL, err)
-	}
-	defer workerConn.Close()
-
-	_, err = workerConn.Write([]byte(fullRequest))
-	if err != nil {
-		return "", fmt.Errorf("error enviando solicitud GET a worker %s: %w", worker.URL, err)
-	}
-
-	workerReader := bufio.NewReader(workerConn)
-	responseStatusLine, err := workerReader.ReadString('\n')
-	if err != nil {
-		return "", fmt.Errorf("error leyendo status line de worker %s: %w", worker.URL, err)
-	}
-	if !strings.Contains(responseStatusLine, "200 OK") {
-		responseBody, _ := io.ReadAll(workerReader)
-		return "", fmt.Errorf("worker %s retornó status no OK: %s - %s", worker.URL, strings.TrimSpace(responseStatusLine), string(responseBody))
-	}
-
-	// Leer y descartar headers del worker
-	for {
-		line, err := workerReader.ReadString('\n')
-		if err != nil {
-			return "", fmt.Errorf("error leyendo headers de worker %s: %w", worker.URL, err)
-		}
-		if strings.TrimSpace(line) == "" {
-			break // Fin de los headers
-		}
+	if err := task.wait(); err != nil {
+		return nil, err
 	}
+	return &httpmsg.Response{StatusCode: task.StatusCode, Reason: httpmsg.StatusText(task.StatusCode), Header: task.Header, Body: task.Response}, nil
+}
 
-	// Leer el cuerpo de la respuesta (el resultado del cálculo)
-	responseBody, err := io.ReadAll(workerReader)
-	if err != nil {
-		return "", fmt.Errorf("error leyendo body de worker %s: %w", worker.URL, err)
+// workerResult retorna el cuerpo de una respuesta 200 o un error con el estado del worker
+func workerResult(worker *Worker, resp *httpmsg.Response) (string, error) {
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("worker %s retornó status no OK: %d %s - %s", worker.URL, resp.StatusCode, resp.Reason, string(resp.Body))
 	}
-
-	return strings.TrimSpace(string(responseBody)), nil
-
+	return strings.TrimSpace(string(resp.Body)), nil
 }
-
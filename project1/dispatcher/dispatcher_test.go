@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Prueba que sin rutas publicadas se deje validar al worker y que con tabla se apliquen 404/405/400
+func TestValidateRoute(t *testing.T) {
+	d := newDispatcher()
+	assert.NoError(t, d.validateRoute("GET", "/cualquiera", nil))
+
+	d.Routes = routes.NewTable([]routes.Route{
+		{Path: "/fibonacci", Methods: []string{"GET"}, Params: []routes.Param{routes.IntMin("num", true, 0)}},
+	})
+
+	assert.NoError(t, d.validateRoute("GET", "/fibonacci", httpmsg.Values{"num": {"7"}}))
+	assert.Equal(t, 404, httpmsg.StatusOf(d.validateRoute("GET", "/noexiste", nil)))
+	assert.Equal(t, 405, httpmsg.StatusOf(d.validateRoute("POST", "/fibonacci", httpmsg.Values{"num": {"7"}})))
+	assert.Equal(t, 400, httpmsg.StatusOf(d.validateRoute("GET", "/fibonacci", httpmsg.Values{"num": {"-1"}})))
+}
+
+// fixedWorker es un worker de prueba que responde siempre con el mismo código.
+// Con status 0 cierra la conexión sin responder.
+type fixedWorker struct {
+	*testWorker
+	status int
+	hits   int64
+}
+
+func newFixedWorker(t *testing.T, status int) *fixedWorker {
+	fw := &fixedWorker{status: status}
+	fw.testWorker = newTestWorker(t, fw.handle)
+	return fw
+}
+
+func (fw *fixedWorker) handle(conn net.Conn, req *httpmsg.Request, body []byte) bool {
+	atomic.AddInt64(&fw.hits, 1)
+	if fw.status == 0 {
+		return false
+	}
+	return reply(conn, req, fw.status, fmt.Sprintf("Respuesta %d de %s", fw.status, conn.LocalAddr()))
+}
+
+// dispatcherConFijos registra un worker por cada código con /fibonacci idempotente
+// y /contar sin marcar
+func dispatcherConFijos(t *testing.T, codes ...int) (*Dispatcher, []*fixedWorker) {
+	d := newDispatcher()
+	var list []*fixedWorker
+	for _, code := range codes {
+		fw := newFixedWorker(t, code)
+		registrar(t, d, routes.Capabilities{URL: fw.addr, MaxConcurrency: 4, Routes: []routes.Route{
+			{Path: "/fibonacci", Methods: []string{"GET"}, Idempotent: true},
+			{Path: "/contar", Methods: []string{"POST"}},
+		}})
+		list = append(list, fw)
+	}
+	return d, list
+}
+
+func totalHits(list []*fixedWorker) int64 {
+	var total int64
+	for _, fw := range list {
+		total += atomic.LoadInt64(&fw.hits)
+	}
+	return total
+}
+
+// Prueba que una tarea idempotente se reenvíe a otro worker hasta obtener una respuesta
+func TestReintentoEnOtroWorker(t *testing.T) {
+	d, list := dispatcherConFijos(t, 0, 503, 200)
+
+	task := &Task{ID: 1, Request: &Request{Method: "GET", Path: "/fibonacci"}}
+	worker, err := d.runTask(context.Background(), task)
+	assert.NoError(t, err)
+	assert.Equal(t, list[2].addr, worker.URL)
+	assert.Equal(t, 200, task.StatusCode)
+	assert.Equal(t, int64(task.RetryCount+1), totalHits(list))
+	for _, fw := range list {
+		assert.LessOrEqual(t, atomic.LoadInt64(&fw.hits), int64(1), "cada worker se intenta una sola vez")
+	}
+
+	resp := enviar(t, d, "GET", "/fibonacci?num=5", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+// Prueba que después de MaxRetries reintentos el cliente reciba la última respuesta
+func TestReintentosAgotados(t *testing.T) {
+	d, list := dispatcherConFijos(t, 500, 500, 500, 500, 500)
+
+	resp := enviar(t, d, "GET", "/fibonacci?num=5", nil)
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Equal(t, int64(MaxRetries+1), totalHits(list))
+}
+
+// Prueba que una ruta no idempotente no se repita
+func TestSinReintentoNoIdempotente(t *testing.T) {
+	d, list := dispatcherConFijos(t, 500, 500)
+
+	resp := enviar(t, d, "POST", "/contar", []byte("x"))
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Equal(t, int64(1), totalHits(list))
+
+	// Sin respuesta del worker el cliente recibe 502
+	d, list = dispatcherConFijos(t, 0, 0)
+	resp = enviar(t, d, "POST", "/contar", []byte("x"))
+	assert.Equal(t, 502, resp.StatusCode)
+	assert.Equal(t, int64(1), totalHits(list))
+}
+
+func TestRetryDelay(t *testing.T) {
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 1600 * time.Millisecond, 2 * time.Second, 2 * time.Second}
+	for attempt, delay := range expected {
+		assert.Equal(t, delay, retryDelay(attempt), "intento %d", attempt)
+	}
+}
+
+// Prueba que la prioridad y la API key del cliente lleguen al worker
+func TestReenviaPrioridad(t *testing.T) {
+	d := newDispatcher()
+	gw := newGateWorker(t)
+	gw.open()
+	registrar(t, d, routes.Capabilities{URL: gw.addr, MaxConcurrency: 1,
+		Routes: []routes.Route{{Path: "/lento", Methods: []string{"GET"}}}})
+
+	header := httpmsg.Header{}
+	header.Set("X-Priority", "high")
+	header.Set("X-API-Key", "oro")
+	header.Set("X-Otro", "no")
+	assert.Equal(t, 200, enviarConHeader(t, d, "GET", "/lento", header, nil).StatusCode)
+
+	req := gw.lastRequest()
+	assert.Equal(t, "high", req.Header.Get("X-Priority"))
+	assert.Equal(t, "oro", req.Header.Get("X-API-Key"))
+	assert.Empty(t, req.Header.Get("X-Otro"))
+}
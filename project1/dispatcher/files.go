@@ -0,0 +1,99 @@
+// files.go (en módulo dispatcher)
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"http-servidor/utils"
+	"http-shared/routes"
+	"log"
+	"net"
+	"sort"
+	"sync"
+)
+
+// handleListFiles une el /listfiles de todos los workers. Cada archivo aparece una
+// vez con la lista de workers que lo tienen; si las copias difieren se muestra la
+// modificada más recientemente. Los workers que no responden se informan en
+// "unreachable" en lugar de hacer fallar el listado.
+func (d *Dispatcher) handleListFiles(conn net.Conn) {
+	workers := d.candidatos("/listfiles", WorkerSuspect)
+	if len(workers) == 0 {
+		utils.SendResponse(conn, "503 Service Unavailable", "No hay workers disponibles")
+		return
+	}
+
+	lists := make([][]routes.FileInfo, len(workers))
+	errs := make([]error, len(workers))
+	var wg sync.WaitGroup
+	for i, w := range workers {
+		wg.Add(1)
+		go func(i int, w *Worker) {
+			defer wg.Done()
+			lists[i], errs[i] = d.fetchFileList(w)
+		}(i, w)
+	}
+	wg.Wait()
+
+	merged := make(map[string]*routes.FileInfo)
+	unreachable := []string{}
+	for i, w := range workers {
+		if errs[i] != nil {
+			log.Printf("No se pudo listar los archivos de %s: %v", w.URL, errs[i])
+			unreachable = append(unreachable, w.URL)
+			continue
+		}
+		for _, info := range lists[i] {
+			current, ok := merged[info.Name]
+			if !ok {
+				entry := info // info se reutiliza en cada vuelta
+				entry.Replicas = []string{w.URL}
+				merged[info.Name] = &entry
+				continue
+			}
+			current.Replicas = append(current.Replicas, w.URL)
+			if info.ModTime.After(current.ModTime) {
+				current.Size, current.ModTime = info.Size, info.ModTime
+			}
+		}
+	}
+	if len(unreachable) == len(workers) {
+		utils.SendResponse(conn, "503 Service Unavailable", "Ningún worker respondió")
+		return
+	}
+
+	list := make([]routes.FileInfo, 0, len(merged))
+	for _, info := range merged {
+		sort.Strings(info.Replicas)
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	response := map[string]interface{}{
+		"files":       list,
+		"workers":     len(workers),
+		"unreachable": unreachable,
+	}
+	jsonData, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		utils.SendResponse(conn, "500 Internal Server Error", "Error generando JSON")
+		return
+	}
+	utils.SendJSON(conn, "200 OK", jsonData)
+}
+
+// fetchFileList consulta /listfiles en el worker
+func (d *Dispatcher) fetchFileList(w *Worker) ([]routes.FileInfo, error) {
+	resp, err := d.callReplica(w, "GET", "/listfiles", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("código %d", resp.StatusCode)
+	}
+	var list []routes.FileInfo
+	if err := json.Unmarshal(resp.Body, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// enviarConHeader es como enviar pero con encabezados, por ejemplo Range
+func enviarConHeader(t *testing.T, d *Dispatcher, method, target string, header httpmsg.Header, body []byte) *httpmsg.Response {
+	client, conn := net.Pipe()
+	defer client.Close()
+	go d.HandleConnection(conn)
+	go httpmsg.WriteRequest(client, method, target, header, body)
+
+	resp, err := httpmsg.ReadResponse(bufio.NewReader(client), httpmsg.DefaultLimits)
+	assert.NoError(t, err)
+	return resp
+}
+
+// Prueba subir, agregar, leer por rangos y consultar un archivo a través del dispatcher,
+// con y sin replicación
+func TestArchivosPorDispatcher(t *testing.T) {
+	for _, factor := range []int{1, 3} {
+		d, _ := dispatcherConArchivos(t, 3, factor)
+
+		resp := enviar(t, d, "POST", "/uploadfile?name=a.txt", []byte("0123456789"))
+		assert.Equal(t, 200, resp.StatusCode, "factor %d", factor)
+		resp = enviar(t, d, "POST", "/appendfile?name=a.txt", []byte("AB"))
+		assert.Equal(t, 200, resp.StatusCode, "factor %d", factor)
+
+		header := httpmsg.Header{}
+		header.Set("Range", "bytes=8-")
+		resp = enviarConHeader(t, d, "GET", "/readfile?name=a.txt", header, nil)
+		assert.Equal(t, 206, resp.StatusCode, "factor %d", factor)
+		assert.Equal(t, "89AB", string(resp.Body))
+		assert.Equal(t, "bytes 8-11/12", resp.Header.Get("Content-Range"))
+
+		resp = enviar(t, d, "GET", "/stat?name=a.txt", nil)
+		assert.Equal(t, 200, resp.StatusCode, "factor %d", factor)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var info routes.FileInfo
+		assert.NoError(t, json.Unmarshal(resp.Body, &info))
+		assert.Equal(t, int64(12), info.Size)
+		assert.Equal(t, digestOf("0123456789AB"), info.SHA256)
+
+		assert.Equal(t, 404, enviar(t, d, "GET", "/stat?name=b.txt", nil).StatusCode)
+	}
+}
+
+// Prueba que /listfiles una los listados de todos los workers
+func TestListFilesUnido(t *testing.T) {
+	d, workers := dispatcherConArchivos(t, 3, 1)
+	var list []*fileWorker
+	for _, w := range d.Workers {
+		list = append(list, workers[w.URL])
+	}
+	list[0].put("a.txt", "1")
+	list[1].put("a.txt", "1")
+	list[1].put("b.txt", "22")
+	list[2].put("c.txt", "333")
+	list[2].stop()
+
+	resp := enviar(t, d, "GET", "/listfiles", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	var result struct {
+		Files       []routes.FileInfo
+		Workers     int
+		Unreachable []string
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body, &result))
+	assert.Equal(t, 3, result.Workers)
+	assert.Equal(t, []string{d.Workers[2].URL}, result.Unreachable)
+	if assert.Len(t, result.Files, 2) {
+		assert.Equal(t, "a.txt", result.Files[0].Name)
+		assert.Len(t, result.Files[0].Replicas, 2)
+		assert.Equal(t, "b.txt", result.Files[1].Name)
+		assert.Equal(t, int64(2), result.Files[1].Size)
+	}
+}
@@ -0,0 +1,417 @@
+// jobs.go (en módulo dispatcher)
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"http-servidor/utils"
+	"http-shared/httpmsg"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	JobTimeout   = 30 * time.Minute // Plazo de un trabajo asíncrono, reemplaza a WorkerRequestTimeout
+	JobRetention = 10 * time.Minute // Tiempo que se guarda un trabajo terminado para consultarlo
+)
+
+// Job es una solicitud que se atiende en segundo plano. El cliente recibe el ID al
+// enviarla y consulta el estado y el resultado en /jobs/{id}.
+type Job struct {
+	mu     sync.Mutex
+	task   Task // Estado, tiempos y respuesta; task.Request guarda la solicitud original
+	cancel context.CancelFunc
+}
+
+// jobView es lo que se responde en /jobs
+type jobView struct {
+	ID          int               `json:"id"`
+	URL         string            `json:"url"`
+	Status      string            `json:"status"`
+	Method      string            `json:"method"`
+	Route       string            `json:"route"`
+	Params      map[string]string `json:"params,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	WaitMs      int64             `json:"wait_ms"` // De creado a iniciado
+	RunMs       int64             `json:"run_ms"`  // De iniciado a terminado, o hasta ahora si sigue en curso
+	StatusCode  int               `json:"status_code,omitempty"`
+	Result      string            `json:"result,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// jobSubmission es el cuerpo de POST /jobs
+type jobSubmission struct {
+	Method string `json:"method"` // GET por defecto
+	Target string `json:"target"` // Ruta con su query, por ejemplo /simulate?seconds=30
+	Body   string `json:"body"`
+}
+
+// isAsync indica si el cliente pidió atender la solicitud como trabajo
+func isAsync(query httpmsg.Values) bool {
+	value := query.Get("async")
+	return value == "true" || value == "1"
+}
+
+// coordinated indica si la ruta la reparte el propio dispatcher entre los workers
+func (d *Dispatcher) coordinated(method, route string) bool {
+	_, ok := d.MapReduce.Lookup(method, route)
+	return ok
+}
+
+// checkJob aplica antes de aceptar el trabajo las mismas validaciones que dispatch,
+// así una ruta inválida se rechaza de inmediato y no como trabajo fallido
+func (d *Dispatcher) checkJob(method, route string, query httpmsg.Values) error {
+	if d.coordinated(method, route) {
+		return nil
+	}
+	if err := d.validateRoute(method, route, query); err != nil {
+		return err
+	}
+	if method != "GET" && method != "POST" {
+		return &httpmsg.Error{Status: 405, Msg: "Solo se permite GET y POST"}
+	}
+	return nil
+}
+
+// submitJob atiende una solicitud con ?async=true: lee el cuerpo, crea el trabajo y
+// responde 202 con su ID sin esperar al worker
+func (d *Dispatcher) submitJob(conn net.Conn, req *httpmsg.Request, route string, query httpmsg.Values) {
+	delete(query, "async") // Los workers no conocen el parámetro
+	if err := d.checkJob(req.Method, route, query); err != nil {
+		utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+	body, err := req.ReadBody()
+	if err != nil {
+		utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+
+	job := d.startJob(req.Method, route, query, forwardHeader(req.Header), body)
+	sendJobJSON(conn, "202 Accepted", job.view(false))
+}
+
+// submitJobJSON atiende POST /jobs con un jobSubmission en el cuerpo
+func (d *Dispatcher) submitJobJSON(conn net.Conn, req *httpmsg.Request) {
+	raw, err := req.ReadBody()
+	if err != nil {
+		utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+	var sub jobSubmission
+	if err := json.Unmarshal(raw, &sub); err != nil || sub.Target == "" {
+		utils.SendResponse(conn, "400 Bad Request", `Se espera {"method": "GET", "target": "/ruta?param=valor", "body": ""}`)
+		return
+	}
+	method := strings.ToUpper(sub.Method)
+	if method == "" {
+		method = "GET"
+	}
+	route, query, err := httpmsg.ParseRoute(sub.Target)
+	if err != nil {
+		utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+	if err := d.checkJob(method, route, query); err != nil {
+		utils.SendResponse(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+
+	job := d.startJob(method, route, query, httpmsg.Header{}, []byte(sub.Body))
+	sendJobJSON(conn, "202 Accepted", job.view(false))
+}
+
+// startJob registra el trabajo en d.Tasks y lo ejecuta en segundo plano
+func (d *Dispatcher) startJob(method, route string, query httpmsg.Values, header httpmsg.Header, body []byte) *Job {
+	id := int(atomic.AddInt64(&d.jobSeq, 1))
+	job := d.newJob(id, time.Now(), method, route, query, header, body)
+	log.Printf("Trabajo %d creado para %s %s", id, method, route)
+	d.walAccepted(job)
+
+	atomic.AddInt64(&d.asyncJobs, 1)
+	go func() {
+		defer atomic.AddInt64(&d.asyncJobs, -1)
+		d.runJob(job, query)
+	}()
+	return job
+}
+
+// newJob crea el trabajo y lo guarda en d.Tasks. El plazo JobTimeout corre desde createdAt.
+func (d *Dispatcher) newJob(id int, createdAt time.Time, method, route string, query httpmsg.Values, header httpmsg.Header, body []byte) *Job {
+	ctx, cancel := context.WithDeadline(withJobID(context.Background(), id), createdAt.Add(JobTimeout))
+	job := &Job{
+		cancel: cancel,
+		task: Task{
+			ID:        id,
+			Request:   &Request{Method: method, Path: route, Params: query.Map(), Header: header, Body: body, Ctx: ctx},
+			Status:    TaskPending,
+			CreatedAt: createdAt,
+		},
+	}
+	d.Tasks.Store(id, job)
+	return job
+}
+
+func (d *Dispatcher) runJob(job *Job, query httpmsg.Values) {
+	defer d.expireJob(job)
+	defer job.cancel()
+
+	job.mu.Lock()
+	if job.task.Status != TaskPending { // Se canceló antes de empezar
+		job.mu.Unlock()
+		return
+	}
+	job.task.Status = TaskProcessing
+	job.task.StartedAt = time.Now()
+	job.mu.Unlock()
+
+	// dispatch vuelve a leer el cuerpo desde la solicitud, como con una conexión de cliente
+	r := job.task.Request
+	replay := &httpmsg.Request{Method: r.Method, Path: r.Path, Header: r.Header, Body: bytes.NewReader(r.Body)}
+	conn := &captureConn{}
+	d.dispatch(r.Ctx, conn, replay, r.Path, query)
+	resp, err := conn.response()
+	if d.stoppedByShutdown(job) {
+		log.Printf("Trabajo %d interrumpido por el apagado, queda pendiente en el WAL", job.task.ID)
+		return
+	}
+	if job.finish(resp, err) {
+		d.walFinished(job)
+	}
+	log.Printf("Trabajo %d terminado: %s", job.task.ID, job.status())
+}
+
+// stoppedByShutdown indica si Shutdown canceló el trabajo. Su fin no se registra en el
+// WAL para que se reanude (o quede fallido si no es idempotente) al volver.
+func (d *Dispatcher) stoppedByShutdown(job *Job) bool {
+	return d.isShuttingDown() && errors.Is(job.task.Request.context().Err(), context.Canceled)
+}
+
+// expireJob olvida el trabajo terminado después de JobRetention
+func (d *Dispatcher) expireJob(job *Job) {
+	time.AfterFunc(JobRetention, func() { d.Tasks.Delete(job.task.ID) })
+}
+
+// finish guarda la respuesta. Un código 5xx deja el trabajo fallido.
+// Retorna false si el trabajo ya se había cancelado.
+func (j *Job) finish(resp *httpmsg.Response, err error) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.task.Status != TaskProcessing { // Cancelado mientras corría
+		return false
+	}
+	j.task.CompletedAt = time.Now()
+	if err != nil {
+		j.task.Status = TaskFailed
+		j.task.Error = err.Error()
+		return true
+	}
+	j.task.StatusCode = resp.StatusCode
+	j.task.Header = resp.Header
+	j.task.Response = resp.Body
+	j.task.Status = TaskCompleted
+	if resp.StatusCode >= 500 {
+		j.task.Status = TaskFailed
+		j.task.Error = strings.TrimSpace(string(resp.Body))
+	}
+	return true
+}
+
+// Cancel interrumpe el trabajo y sus llamadas a los workers. Retorna false si ya había terminado.
+func (j *Job) Cancel() bool {
+	return j.abort("Cancelado por el cliente")
+}
+
+// abort deja el trabajo fallido con reason y cancela su contexto
+func (j *Job) abort(reason string) bool {
+	j.mu.Lock()
+	if j.task.Status == TaskCompleted || j.task.Status == TaskFailed {
+		j.mu.Unlock()
+		return false
+	}
+	j.task.Status = TaskFailed
+	j.task.Error = reason
+	j.task.CompletedAt = time.Now()
+	j.mu.Unlock()
+
+	j.cancel()
+	return true
+}
+
+func (j *Job) status() TaskStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.task.Status
+}
+
+func (j *Job) view(withResult bool) jobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	t := j.task
+	v := jobView{
+		ID:         t.ID,
+		URL:        fmt.Sprintf("/jobs/%d", t.ID),
+		Status:     t.Status.String(),
+		Method:     t.Request.Method,
+		Route:      t.Request.Path,
+		Params:     t.Request.Params,
+		CreatedAt:  t.CreatedAt,
+		StatusCode: t.StatusCode,
+		Error:      t.Error,
+	}
+	if !t.StartedAt.IsZero() {
+		v.StartedAt = &t.StartedAt
+		v.WaitMs = t.StartedAt.Sub(t.CreatedAt).Milliseconds()
+		end := time.Now()
+		if !t.CompletedAt.IsZero() {
+			end = t.CompletedAt
+		}
+		v.RunMs = end.Sub(t.StartedAt).Milliseconds()
+	}
+	if !t.CompletedAt.IsZero() {
+		v.CompletedAt = &t.CompletedAt
+	}
+	if withResult {
+		v.Result = string(t.Response)
+	}
+	return v
+}
+
+// jobsHandler atiende /jobs, /jobs/{id} y /jobs/{id}/result
+func (d *Dispatcher) jobsHandler(conn net.Conn, req *httpmsg.Request, route string) {
+	if route == "/jobs" {
+		switch req.Method {
+		case "GET":
+			d.listJobs(conn)
+		case "POST":
+			d.submitJobJSON(conn, req)
+		default:
+			utils.SendResponse(conn, "405 Method Not Allowed", "Use GET o POST")
+		}
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(route, "/jobs/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "result") {
+		utils.SendResponse(conn, "404 Not Found", "Ruta no encontrada")
+		return
+	}
+	value, ok := d.Tasks.Load(id)
+	if !ok {
+		utils.SendResponse(conn, "404 Not Found", fmt.Sprintf("El trabajo %d no existe o ya expiró", id))
+		return
+	}
+	job := value.(*Job)
+
+	if len(parts) == 2 {
+		if req.Method != "GET" {
+			utils.SendResponse(conn, "405 Method Not Allowed", "Use GET")
+			return
+		}
+		job.sendResult(conn)
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		sendJobJSON(conn, "200 OK", job.view(true))
+	case "DELETE":
+		// Un trabajo en curso se cancela y se conserva para consultarlo; uno terminado se olvida
+		if !job.Cancel() {
+			d.Tasks.Delete(id)
+		} else {
+			log.Printf("Trabajo %d cancelado", id)
+			d.walFinished(job)
+		}
+		sendJobJSON(conn, "200 OK", job.view(false))
+	default:
+		utils.SendResponse(conn, "405 Method Not Allowed", "Use GET o DELETE")
+	}
+}
+
+// sendResult reenvía la respuesta del trabajo tal como la habría recibido el cliente
+func (j *Job) sendResult(conn net.Conn) {
+	j.mu.Lock()
+	t := j.task
+	j.mu.Unlock()
+
+	if t.StatusCode == 0 {
+		if t.Status == TaskFailed {
+			utils.SendResponse(conn, "409 Conflict", "El trabajo falló sin respuesta: "+t.Error)
+		} else {
+			utils.SendResponse(conn, "409 Conflict", "El trabajo sigue "+t.Status.String())
+		}
+		return
+	}
+	utils.SendWorkerResponse(conn, t.StatusCode, t.Header, t.Response)
+}
+
+func (d *Dispatcher) listJobs(conn net.Conn) {
+	list := []jobView{}
+	d.Tasks.Range(func(_, value interface{}) bool {
+		if job, ok := value.(*Job); ok {
+			list = append(list, job.view(false))
+		}
+		return true
+	})
+	sort.Slice(list, func(i, k int) bool { return list[i].ID < list[k].ID })
+	sendJobJSON(conn, "200 OK", list)
+}
+
+func sendJobJSON(conn net.Conn, status string, v interface{}) {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		utils.SendResponse(conn, "500 Internal Server Error", "Error generando JSON")
+		return
+	}
+	utils.SendJSON(conn, status, jsonData)
+}
+
+// captureConn es la conexión de un trabajo: guarda lo que dispatch le escribiría al
+// cliente para leerlo después como respuesta HTTP
+type captureConn struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *captureConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+func (c *captureConn) Read(p []byte) (int, error)         { return 0, io.EOF }
+func (c *captureConn) Close() error                       { return nil }
+func (c *captureConn) LocalAddr() net.Addr                { return jobAddr{} }
+func (c *captureConn) RemoteAddr() net.Addr               { return jobAddr{} }
+func (c *captureConn) SetDeadline(t time.Time) error      { return nil }
+func (c *captureConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *captureConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (c *captureConn) response() (*httpmsg.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.buf.Len() == 0 {
+		return nil, errors.New("el trabajo terminó sin respuesta")
+	}
+	return httpmsg.ReadResponse(bufio.NewReader(bytes.NewReader(c.buf.Bytes())), httpmsg.DefaultLimits)
+}
+
+type jobAddr struct{}
+
+func (jobAddr) Network() string { return "job" }
+func (jobAddr) String() string  { return "job" }
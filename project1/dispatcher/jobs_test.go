@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowWorker es un worker de prueba: /simulate responde de inmediato y /bloquear
+// no responde hasta que el dispatcher cierre la conexión
+type slowWorker struct {
+	*testWorker
+	targets chan string // Target de cada solicitud recibida
+	closed  chan bool   // Se avisa cuando el dispatcher abandona /bloquear
+}
+
+func newSlowWorker(t *testing.T) *slowWorker {
+	sw := &slowWorker{targets: make(chan string, 10), closed: make(chan bool, 10)}
+	sw.testWorker = newTestWorker(t, sw.handle)
+	return sw
+}
+
+func (sw *slowWorker) handle(conn net.Conn, req *httpmsg.Request, body []byte) bool {
+	sw.targets <- req.Target
+	if req.Path == "/bloquear" {
+		conn.Read(make([]byte, 1)) // Retorna cuando el dispatcher cierra la conexión
+		sw.closed <- true
+		return false
+	}
+	return reply(conn, req, 200, "Tarea completada")
+}
+
+func dispatcherConTrabajos(t *testing.T) (*Dispatcher, *slowWorker) {
+	d := newDispatcher()
+	sw := newSlowWorker(t)
+	get := []string{"GET"}
+	registrar(t, d, routes.Capabilities{URL: sw.addr, MaxConcurrency: 4, Routes: []routes.Route{
+		{Path: "/simulate", Methods: get, Params: []routes.Param{routes.IntMin("seconds", true, 1)}},
+		{Path: "/bloquear", Methods: get, Idempotent: true},
+		{Path: "/agregar", Methods: []string{"POST"}},
+	}})
+	return d, sw
+}
+
+func leerTrabajo(t *testing.T, resp *httpmsg.Response) jobView {
+	var view jobView
+	assert.NoError(t, json.Unmarshal(resp.Body, &view))
+	return view
+}
+
+// esperarTrabajo consulta /jobs/{id} hasta que el trabajo termine
+func esperarTrabajo(t *testing.T, d *Dispatcher, id int) jobView {
+	for i := 0; i < 100; i++ {
+		view := leerTrabajo(t, enviar(t, d, "GET", fmt.Sprintf("/jobs/%d", id), nil))
+		if view.Status == "completed" || view.Status == "failed" {
+			return view
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("El trabajo %d no terminó", id)
+	return jobView{}
+}
+
+// Prueba el ciclo completo: 202 con el ID, consulta del estado y del resultado
+func TestTrabajoAsincrono(t *testing.T) {
+	d, sw := dispatcherConTrabajos(t)
+
+	resp := enviar(t, d, "GET", "/simulate?seconds=1&async=true", nil)
+	assert.Equal(t, 202, resp.StatusCode)
+	job := leerTrabajo(t, resp)
+	assert.Equal(t, "/jobs/1", job.URL)
+	assert.Equal(t, "/simulate", job.Route)
+
+	view := esperarTrabajo(t, d, job.ID)
+	assert.Equal(t, "completed", view.Status)
+	assert.Equal(t, 200, view.StatusCode)
+	assert.Equal(t, "Tarea completada", view.Result)
+	assert.NotNil(t, view.StartedAt)
+	assert.NotNil(t, view.CompletedAt)
+	assert.Equal(t, "/simulate?seconds=1", <-sw.targets, "el parámetro async no debe llegar al worker")
+
+	resp = enviar(t, d, "GET", "/jobs/1/result", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "Tarea completada", string(resp.Body))
+
+	// POST /jobs con la solicitud en JSON
+	resp = enviar(t, d, "POST", "/jobs", []byte(`{"target": "/simulate?seconds=2"}`))
+	assert.Equal(t, 202, resp.StatusCode)
+	assert.Equal(t, "completed", esperarTrabajo(t, d, leerTrabajo(t, resp).ID).Status)
+
+	var list []jobView
+	assert.NoError(t, json.Unmarshal(enviar(t, d, "GET", "/jobs", nil).Body, &list))
+	assert.Len(t, list, 2)
+
+	// Borrar un trabajo terminado lo olvida
+	assert.Equal(t, 200, enviar(t, d, "DELETE", "/jobs/1", nil).StatusCode)
+	assert.Equal(t, 404, enviar(t, d, "GET", "/jobs/1", nil).StatusCode)
+}
+
+// Prueba que DELETE corte la llamada al worker sin marcarlo sospechoso
+func TestTrabajoCancelado(t *testing.T) {
+	d, sw := dispatcherConTrabajos(t)
+
+	resp := enviar(t, d, "GET", "/bloquear?async=1", nil)
+	assert.Equal(t, 202, resp.StatusCode)
+	id := leerTrabajo(t, resp).ID
+	<-sw.targets // El worker ya recibió la solicitud
+
+	resp = enviar(t, d, "DELETE", fmt.Sprintf("/jobs/%d", id), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	view := leerTrabajo(t, resp)
+	assert.Equal(t, "failed", view.Status)
+	assert.Equal(t, "Cancelado por el cliente", view.Error)
+
+	select {
+	case <-sw.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("El dispatcher no cerró la conexión con el worker")
+	}
+	assert.Equal(t, 409, enviar(t, d, "GET", fmt.Sprintf("/jobs/%d/result", id), nil).StatusCode)
+	assert.Equal(t, WorkerAlive, d.Workers[0].State)
+}
+
+// Prueba que las solicitudes inválidas se rechacen antes de crear el trabajo
+func TestTrabajoInvalido(t *testing.T) {
+	d, _ := dispatcherConTrabajos(t)
+
+	assert.Equal(t, 404, enviar(t, d, "GET", "/noexiste?async=true", nil).StatusCode)
+	assert.Equal(t, 400, enviar(t, d, "GET", "/simulate?seconds=0&async=true", nil).StatusCode)
+	assert.Equal(t, 400, enviar(t, d, "POST", "/jobs", []byte(`{}`)).StatusCode)
+	assert.Equal(t, 405, enviar(t, d, "POST", "/jobs", []byte(`{"method": "DELETE", "target": "/simulate?seconds=1"}`)).StatusCode)
+	assert.Equal(t, 404, enviar(t, d, "GET", "/jobs/99", nil).StatusCode)
+	assert.Equal(t, 404, enviar(t, d, "GET", "/jobs/abc", nil).StatusCode)
+	assert.Equal(t, 405, enviar(t, d, "PUT", "/jobs", nil).StatusCode)
+
+	var list []jobView
+	assert.NoError(t, json.Unmarshal(enviar(t, d, "GET", "/jobs", nil).Body, &list))
+	assert.Empty(t, list)
+}
@@ -1,48 +1,65 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
-	"time"
-
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
 	dispatcher := newDispatcher()
 	
+	// Reanuda los trabajos que quedaron sin terminar antes del último reinicio
+	if dispatcher.WAL.Enabled() {
+		if _, err := dispatcher.Recover(); err != nil {
+			log.Fatalf("Error leyendo el WAL: %v", err)
+		}
+		go dispatcher.runWAL(context.Background())
+	}
 
-	// Inicia health checks periódicos
-	go func() {
-		ticker := time.NewTicker(HealthCheckInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				dispatcher.HealthCheck()
-			}
+	// Vuelve a cargar los workers que estaban registrados al apagarse, sin esperar a que se suscriban
+	if dispatcher.RegistryPath != "" {
+		if _, err := dispatcher.LoadRegistry(dispatcher.RegistryPath); err != nil {
+			log.Printf("No se pudo cargar el registro de workers: %v", err)
 		}
-	}()
+	}
+
+	// Inicia health checks periódicos
+	go dispatcher.runHealthChecks()
 
 	// Inicia el servidor HTTP del dispatcher
 	ln, err := net.Listen("tcp", DispatcherPort)
 	if err != nil {
 		log.Fatalf("Error al iniciar dispatcher: %v", err)
 	}
-	defer ln.Close()
 
 	log.Printf("Dispatcher escuchando en %s", DispatcherPort)
 
-	// Bucle principal para aceptar conexiones
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			log.Printf("Error aceptando conexión: %v", err)
-			continue
-		}
+	// Con SIGTERM o SIGINT deja de aceptar conexiones, espera las solicitudes en curso
+	// y guarda el registro de workers. Una segunda señal sale sin esperar.
+	grace := shutdownGraceFromEnv()
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	stopped := make(chan error, 1)
+	go func() {
+		sig := <-signals
+		log.Printf("Señal %v recibida", sig)
+		go func() {
+			sig := <-signals
+			log.Printf("Señal %v durante el apagado, se sale sin esperar", sig)
+			os.Exit(2)
+		}()
+		stopped <- dispatcher.Shutdown(grace)
+	}()
 
-		go dispatcher.HandleConnection(conn)
-	} 
+	// Bucle principal para aceptar conexiones
+	dispatcher.Serve(ln)
+	if err := <-stopped; err != nil {
+		log.Printf("Dispatcher detenido con errores: %v", err)
+		os.Exit(1)
+	}
+	log.Printf("Dispatcher detenido")
 }
-
-
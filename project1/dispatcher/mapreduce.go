@@ -0,0 +1,470 @@
+// mapreduce.go (en módulo dispatcher)
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"http-servidor/utils"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const MapReduceHistory = 50 // Ejecuciones que se guardan para /mapreduce
+
+// MapInput es la solicitud del cliente que se reparte entre los workers
+type MapInput struct {
+	Params map[string]string
+	Body   []byte
+}
+
+// Chunk es la parte del trabajo que recibe un worker en /map. Params se agregan a los de
+// la llamada y Units es cuánto del trabajo lleva (líneas, bytes, iteraciones).
+type Chunk struct {
+	Params map[string]string
+	Body   []byte
+	Units  int
+}
+
+// Splitter divide la entrada en a lo sumo n chunks, n es la cantidad de workers.
+// Un error *httpmsg.Error se responde al cliente con su código.
+type Splitter func(in MapInput, n int) ([]Chunk, error)
+
+// Combiner agrega a acc el resultado de un chunk
+type Combiner func(acc, part routes.MapResult)
+
+// Reducer arma la respuesta al cliente con los resultados combinados
+type Reducer func(in MapInput, total routes.MapResult) (string, error)
+
+// MapReduce es un trabajo que el dispatcher reparte: Split divide la entrada, cada worker
+// aplica la función Fn de /map a su chunk, Combine junta los resultados y Reduce responde.
+type MapReduce struct {
+	Method  string
+	Route   string
+	Fn      string   // Función de /map en el worker
+	Split   Splitter
+	Combine Combiner // nil suma los valores por llave
+	Reduce  Reducer
+}
+
+// SumResults es el Combiner por defecto: suma los valores de cada llave
+func SumResults(acc, part routes.MapResult) {
+	for key, value := range part {
+		acc[key] += value
+	}
+}
+
+// spread reparte total unidades en a lo sumo n partes; las primeras llevan una más
+func spread(total, n int) []int {
+	if n > total {
+		n = total
+	}
+	sizes := make([]int, n)
+	for i := range sizes {
+		sizes[i] = total / n
+		if i < total%n {
+			sizes[i]++
+		}
+	}
+	return sizes
+}
+
+// SplitRecords divide el cuerpo en registros separados por sep y reparte los registros.
+// Un último registro vacío (el cuerpo termina en sep) no cuenta.
+func SplitRecords(sep string) Splitter {
+	return func(in MapInput, n int) ([]Chunk, error) {
+		records := strings.Split(string(in.Body), sep)
+		if len(records) > 0 && strings.TrimSpace(records[len(records)-1]) == "" {
+			records = records[:len(records)-1]
+		}
+		var chunks []Chunk
+		start := 0
+		for _, size := range spread(len(records), n) {
+			body := strings.Join(records[start:start+size], sep)
+			chunks = append(chunks, Chunk{Body: []byte(body), Units: size})
+			start += size
+		}
+		return chunks, nil
+	}
+}
+
+// SplitLines reparte las líneas del cuerpo
+var SplitLines = SplitRecords("\n")
+
+// SplitBytes reparte el cuerpo en partes de tamaño parecido sin cortar un carácter UTF-8
+func SplitBytes(in MapInput, n int) ([]Chunk, error) {
+	var chunks []Chunk
+	start, end := 0, 0
+	for _, size := range spread(len(in.Body), n) {
+		end += size
+		cut := end
+		if cut < start {
+			cut = start
+		}
+		for cut < len(in.Body) && !utf8.RuneStart(in.Body[cut]) {
+			cut++
+		}
+		if cut > start {
+			chunks = append(chunks, Chunk{Body: in.Body[start:cut], Units: cut - start})
+		}
+		start = cut
+	}
+	return chunks, nil
+}
+
+// SplitCount reparte el entero positivo del parámetro param: cada chunk recibe param con su parte
+func SplitCount(param string) Splitter {
+	return func(in MapInput, n int) ([]Chunk, error) {
+		value, ok := in.Params[param]
+		if !ok {
+			return nil, &httpmsg.Error{Status: 400, Msg: fmt.Sprintf("Parámetro '%s' requerido", param)}
+		}
+		total, err := strconv.Atoi(value)
+		if err != nil || total <= 0 {
+			return nil, &httpmsg.Error{Status: 400, Msg: fmt.Sprintf("Parámetro '%s' debe ser un número entero positivo", param)}
+		}
+		var chunks []Chunk
+		for _, size := range spread(total, n) {
+			chunks = append(chunks, Chunk{Params: map[string]string{param: strconv.Itoa(size)}, Units: size})
+		}
+		return chunks, nil
+	}
+}
+
+// MapReduceEngine guarda los trabajos registrados y las últimas ejecuciones
+type MapReduceEngine struct {
+	mu   sync.Mutex
+	jobs map[string]*MapReduce // Por método y ruta
+	runs []*mapRun             // Las últimas MapReduceHistory, de la más vieja a la más nueva
+	seq  int
+}
+
+func NewMapReduceEngine(jobs ...*MapReduce) *MapReduceEngine {
+	e := &MapReduceEngine{jobs: make(map[string]*MapReduce)}
+	for _, job := range jobs {
+		e.Register(job)
+	}
+	return e
+}
+
+// Register agrega un trabajo; reemplaza al que tenga el mismo método y ruta
+func (e *MapReduceEngine) Register(job *MapReduce) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.jobs[job.Method+" "+job.Route] = job
+}
+
+// Lookup retorna el trabajo que atiende method y route
+func (e *MapReduceEngine) Lookup(method, route string) (*MapReduce, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	job, ok := e.jobs[method+" "+route]
+	return job, ok
+}
+
+// mapRun es el estado de una ejecución para /mapreduce
+type mapRun struct {
+	mu   sync.Mutex
+	view mapRunView
+}
+
+type mapRunView struct {
+	ID          int         `json:"id"`
+	Job         int         `json:"job,omitempty"` // Trabajo asíncrono que la lanzó
+	Method      string      `json:"method"`
+	Route       string      `json:"route"`
+	Fn          string      `json:"fn"`
+	Status      string      `json:"status"`
+	StartedAt   time.Time   `json:"started_at"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+	ChunksDone  int         `json:"chunks_done"`
+	ChunksTotal int         `json:"chunks_total"`
+	Error       string      `json:"error,omitempty"`
+	Chunks      []chunkView `json:"chunks,omitempty"`
+}
+
+type chunkView struct {
+	Index    int    `json:"index"`
+	Units    int    `json:"units"`
+	Status   string `json:"status"`
+	Worker   string `json:"worker,omitempty"` // Último worker que lo recibió
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// start registra una ejecución nueva y olvida la más vieja si ya hay MapReduceHistory
+func (e *MapReduceEngine) start(ctx context.Context, job *MapReduce, chunks []Chunk) *mapRun {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.seq++
+	run := &mapRun{view: mapRunView{
+		ID:          e.seq,
+		Job:         jobIDFrom(ctx),
+		Method:      job.Method,
+		Route:       job.Route,
+		Fn:          job.Fn,
+		Status:      TaskProcessing.String(),
+		StartedAt:   time.Now(),
+		ChunksTotal: len(chunks),
+		Chunks:      make([]chunkView, len(chunks)),
+	}}
+	for i, chunk := range chunks {
+		run.view.Chunks[i] = chunkView{Index: i, Units: chunk.Units, Status: TaskPending.String()}
+	}
+	e.runs = append(e.runs, run)
+	if len(e.runs) > MapReduceHistory {
+		e.runs = e.runs[len(e.runs)-MapReduceHistory:]
+	}
+	return run
+}
+
+func (e *MapReduceEngine) find(id int) *mapRun {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, run := range e.runs {
+		if run.view.ID == id {
+			return run
+		}
+	}
+	return nil
+}
+
+func (r *mapRun) chunkStarted(i int) {
+	r.mu.Lock()
+	r.view.Chunks[i].Status = TaskProcessing.String()
+	r.mu.Unlock()
+}
+
+func (r *mapRun) chunkFinished(i int, worker *Worker, attempts int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := &r.view.Chunks[i]
+	c.Attempts = attempts
+	if worker != nil {
+		c.Worker = worker.URL
+	}
+	if err != nil {
+		c.Status = TaskFailed.String()
+		c.Error = err.Error()
+		return
+	}
+	c.Status = TaskCompleted.String()
+	r.view.ChunksDone++
+}
+
+func (r *mapRun) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.view.CompletedAt = &now
+	r.view.Status = TaskCompleted.String()
+	if err != nil {
+		r.view.Status = TaskFailed.String()
+		r.view.Error = err.Error()
+	}
+}
+
+// snapshot copia el estado; sin withChunks omite el detalle de cada chunk
+func (r *mapRun) snapshot(withChunks bool) mapRunView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := r.view
+	v.Chunks = nil
+	if withChunks {
+		v.Chunks = append([]chunkView(nil), r.view.Chunks...)
+	}
+	return v
+}
+
+// serveMapReduce lee el cuerpo de la solicitud y ejecuta el trabajo
+func (d *Dispatcher) serveMapReduce(ctx context.Context, conn net.Conn, req *httpmsg.Request, job *MapReduce, params map[string]string) {
+	in := MapInput{Params: params}
+	if req.Method == "POST" {
+		body, err := req.ReadBody()
+		if err != nil {
+			log.Printf("Error leyendo el cuerpo de %s: %v", job.Route, err)
+			d.mapReduceFailed(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+			return
+		}
+		in.Body = body
+	}
+	d.runMapReduce(ctx, conn, job, in)
+}
+
+// runMapReduce divide la entrada en un chunk por worker, envía cada chunk a /map como
+// una tarea (con reintentos en otro worker si falla), combina los resultados y responde
+// lo que retorne Reduce
+func (d *Dispatcher) runMapReduce(ctx context.Context, conn net.Conn, job *MapReduce, in MapInput) {
+	workers := len(d.candidatos(routes.MapRoute, WorkerSuspect))
+	n := workers
+	if n < 1 {
+		n = 1
+	}
+	chunks, err := job.Split(in, n)
+	if err != nil {
+		d.mapReduceFailed(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+
+	total := routes.MapResult{}
+	if len(chunks) > 0 {
+		if workers == 0 {
+			log.Printf("No hay workers disponibles para %s", job.Route)
+			d.mapReduceFailed(conn, "503 Service Unavailable", "No hay workers disponibles para "+job.Route)
+			return
+		}
+		run := d.MapReduce.start(ctx, job, chunks)
+		log.Printf("Map-reduce %d: %s %s en %d chunks", run.view.ID, job.Method, job.Route, len(chunks))
+		err := d.mapChunks(ctx, run, job, chunks, total)
+		run.finish(err)
+		switch {
+		case ctx.Err() != nil:
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				d.mapReduceFailed(conn, "504 Gateway Timeout", "Los workers no respondieron a tiempo")
+			} else {
+				d.mapReduceFailed(conn, "503 Service Unavailable", "Solicitud cancelada")
+			}
+			return
+		case err != nil:
+			d.mapReduceFailed(conn, "500 Internal Server Error", fmt.Sprintf("Errores durante el procesamiento: %v", err))
+			return
+		}
+	}
+
+	result, err := job.Reduce(in, total)
+	if err != nil {
+		d.mapReduceFailed(conn, httpmsg.StatusLine(httpmsg.StatusOf(err)), err.Error())
+		return
+	}
+	utils.SendResponse(conn, "200 OK", result)
+	d.Metrics.mu.Lock()
+	d.Metrics.RequestsHandled++
+	d.Metrics.mu.Unlock()
+}
+
+// mapChunks ejecuta los chunks en paralelo y combina en total los que terminan bien.
+// Retorna los errores de los chunks que fallaron después de sus reintentos.
+func (d *Dispatcher) mapChunks(ctx context.Context, run *mapRun, job *MapReduce, chunks []Chunk, total routes.MapResult) error {
+	parts := make([]routes.MapResult, len(chunks))
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			parts[i], errs[i] = d.runChunk(ctx, run, i, job.Fn, chunks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	combine := job.Combine
+	if combine == nil {
+		combine = SumResults
+	}
+	var failed []string
+	for i, part := range parts {
+		if errs[i] != nil {
+			failed = append(failed, fmt.Sprintf("chunk %d: %v", i, errs[i]))
+			continue
+		}
+		combine(total, part)
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// runChunk envía un chunk a /map con runTask, que lo reintenta en otro worker si el
+// worker no responde o responde 5xx
+func (d *Dispatcher) runChunk(ctx context.Context, run *mapRun, i int, fn string, chunk Chunk) (routes.MapResult, error) {
+	params := map[string]string{"fn": fn}
+	for key, value := range chunk.Params {
+		params[key] = value
+	}
+	header := httpmsg.Header{}
+	header.Set("Content-Type", "text/plain")
+	task := Task{
+		ID: d.Metrics.total(),
+		Request: &Request{
+			Method:  "POST",
+			Path:    routes.MapRoute,
+			Params:  params,
+			Header:  header,
+			Body:    chunk.Body,
+			Done:    make(chan bool, 1),
+			Ctx:     ctx,
+			Timeout: NoTimeout,
+			Retry:   true,
+		},
+		Status:    TaskPending,
+		CreatedAt: time.Now(),
+	}
+
+	run.chunkStarted(i)
+	worker, err := d.runTask(ctx, &task)
+	var result routes.MapResult
+	if worker != nil && err == nil {
+		var body string
+		body, err = workerResult(worker, &httpmsg.Response{StatusCode: task.StatusCode, Reason: httpmsg.StatusText(task.StatusCode), Body: task.Response})
+		if err == nil && json.Unmarshal([]byte(body), &result) != nil {
+			err = fmt.Errorf("worker %s respondió un resultado inválido: %q", worker.URL, body)
+		}
+	}
+	run.chunkFinished(i, worker, task.RetryCount+1, err)
+	if err != nil {
+		log.Printf("Chunk %d de map-reduce %d falló: %v", i, run.view.ID, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (d *Dispatcher) mapReduceFailed(conn net.Conn, status, msg string) {
+	utils.SendResponse(conn, status, msg)
+	d.Metrics.mu.Lock()
+	d.Metrics.RequestsFailed++
+	d.Metrics.mu.Unlock()
+}
+
+// mapReduceHandler atiende /mapreduce con las últimas ejecuciones y /mapreduce/{id}
+// con el estado de cada chunk
+func (d *Dispatcher) mapReduceHandler(conn net.Conn, method, route string) {
+	if method != "GET" {
+		utils.SendResponse(conn, "405 Method Not Allowed", "Use GET")
+		return
+	}
+	if route == "/mapreduce" {
+		d.MapReduce.mu.Lock()
+		runs := append([]*mapRun(nil), d.MapReduce.runs...)
+		d.MapReduce.mu.Unlock()
+
+		list := make([]mapRunView, 0, len(runs))
+		for _, run := range runs {
+			list = append(list, run.snapshot(false))
+		}
+		sort.Slice(list, func(i, k int) bool { return list[i].ID > list[k].ID })
+		sendJobJSON(conn, "200 OK", list)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(route, "/mapreduce/"))
+	if err != nil {
+		utils.SendResponse(conn, "404 Not Found", "Ruta no encontrada")
+		return
+	}
+	run := d.MapReduce.find(id)
+	if run == nil {
+		utils.SendResponse(conn, "404 Not Found", fmt.Sprintf("La ejecución %d no existe o ya se olvidó", id))
+		return
+	}
+	sendJobJSON(conn, "200 OK", run.snapshot(true))
+}
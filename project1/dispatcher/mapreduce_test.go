@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mapWorker es un worker de prueba que atiende /map con words, wordfreq y pi.
+// Con status distinto de 200 responde ese código, y con 0 cierra la conexión.
+type mapWorker struct {
+	*testWorker
+	status int
+	hits   int64
+}
+
+func newMapWorker(t *testing.T, d *Dispatcher, status int) *mapWorker {
+	mw := &mapWorker{status: status}
+	mw.testWorker = newTestWorker(t, mw.handle)
+	registrar(t, d, routes.Capabilities{URL: mw.addr, MaxConcurrency: 4, Routes: []routes.Route{
+		{Path: routes.MapRoute, Methods: []string{"POST"}, Idempotent: true},
+	}})
+	return mw
+}
+
+func (mw *mapWorker) handle(conn net.Conn, req *httpmsg.Request, body []byte) bool {
+	atomic.AddInt64(&mw.hits, 1)
+	if mw.status == 0 {
+		return false
+	}
+	if mw.status != 200 {
+		return reply(conn, req, mw.status, "Error de prueba")
+	}
+	_, query, _ := httpmsg.ParseRoute(req.Target)
+	result := routes.MapResult{}
+	switch query.Get("fn") {
+	case "words":
+		result["words"] = float64(len(strings.Fields(string(body))))
+	case "wordfreq":
+		for _, word := range strings.Fields(string(body)) {
+			result[word]++
+		}
+	case "pi":
+		points, _ := strconv.Atoi(query.Get("iterations"))
+		result["points"] = float64(points)
+		result["inside"] = float64(points) * 0.75
+	}
+	w := httpmsg.NewWriter(conn, req)
+	httpmsg.JSON(w, 200, result)
+	w.Finish()
+	return true
+}
+
+// Prueba que los splitters repartan toda la entrada en a lo sumo n chunks
+func TestSplitters(t *testing.T) {
+	chunks, err := SplitLines(MapInput{Body: []byte("a\nb\nc\nd\ne\n")}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Chunk{{Body: []byte("a\nb\nc"), Units: 3}, {Body: []byte("d\ne"), Units: 2}}, chunks)
+
+	chunks, _ = SplitLines(MapInput{Body: []byte("a\nb")}, 5)
+	assert.Len(t, chunks, 2)
+	chunks, _ = SplitLines(MapInput{}, 3)
+	assert.Empty(t, chunks)
+
+	chunks, _ = SplitRecords(";")(MapInput{Body: []byte("x;y;z")}, 3)
+	assert.Equal(t, "y", string(chunks[1].Body))
+
+	body := []byte("ñandú camión")
+	chunks, _ = SplitBytes(MapInput{Body: body}, 4)
+	var joined []byte
+	for _, chunk := range chunks {
+		assert.True(t, strings.ToValidUTF8(string(chunk.Body), "?") == string(chunk.Body), "chunk %q cortó un carácter", chunk.Body)
+		joined = append(joined, chunk.Body...)
+	}
+	assert.Equal(t, body, joined)
+
+	chunks, err = SplitCount("iterations")(MapInput{Params: map[string]string{"iterations": "10"}}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "4", chunks[0].Params["iterations"])
+	assert.Equal(t, "3", chunks[2].Params["iterations"])
+	_, err = SplitCount("iterations")(MapInput{Params: map[string]string{}}, 3)
+	assert.Equal(t, 400, httpmsg.StatusOf(err))
+	_, err = SplitCount("iterations")(MapInput{Params: map[string]string{"iterations": "0"}}, 3)
+	assert.Equal(t, 400, httpmsg.StatusOf(err))
+}
+
+// Prueba el conteo de palabras, Pi y las palabras más frecuentes repartidos en dos workers
+func TestMapReduce_Trabajos(t *testing.T) {
+	d := newDispatcher()
+	first, second := newMapWorker(t, d, 200), newMapWorker(t, d, 200)
+
+	resp := enviar(t, d, "POST", "/countwords", []byte("uno dos\ntres\ncuatro cinco seis\n"))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "Conteo total de palabras: 6\n", string(resp.Body))
+
+	resp = enviar(t, d, "GET", "/calculatepi?iterations=1000", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "Estimación de Pi: 3.000000\n", string(resp.Body))
+
+	resp = enviar(t, d, "POST", "/topwords?n=2", []byte("b a c\na b\nb"))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "b 3\na 2\n", string(resp.Body))
+
+	resp = enviar(t, d, "POST", "/countwords", nil)
+	assert.Equal(t, "Conteo total de palabras: 0\n", string(resp.Body))
+	assert.Equal(t, int64(6), atomic.LoadInt64(&first.hits)+atomic.LoadInt64(&second.hits))
+}
+
+// Prueba que un chunk que falla se reintente en otro worker y que /mapreduce muestre los intentos
+func TestMapReduce_ReintentaChunk(t *testing.T) {
+	d := newDispatcher()
+	newMapWorker(t, d, 500)
+	newMapWorker(t, d, 0)
+	ok := newMapWorker(t, d, 200)
+
+	resp := enviar(t, d, "POST", "/countwords", []byte("a\nb c\nd e f\n"))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "Conteo total de palabras: 6\n", string(resp.Body))
+	assert.Equal(t, int64(3), atomic.LoadInt64(&ok.hits))
+
+	resp = enviar(t, d, "GET", "/mapreduce/1", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	var run mapRunView
+	assert.NoError(t, json.Unmarshal(resp.Body, &run))
+	assert.Equal(t, "completed", run.Status)
+	assert.Equal(t, 3, run.ChunksDone)
+	attempts := 0
+	for _, chunk := range run.Chunks {
+		assert.Equal(t, "completed", chunk.Status)
+		assert.Equal(t, ok.addr, chunk.Worker)
+		attempts += chunk.Attempts
+	}
+	assert.Greater(t, attempts, 3)
+}
+
+// Prueba que si un chunk falla en todos los workers se responda 500 y la ejecución quede fallida
+func TestMapReduce_Falla(t *testing.T) {
+	d := newDispatcher()
+	newMapWorker(t, d, 500)
+
+	resp := enviar(t, d, "POST", "/countwords", []byte("a b\nc\n"))
+	assert.Equal(t, 500, resp.StatusCode)
+
+	resp = enviar(t, d, "GET", "/mapreduce", nil)
+	var list []mapRunView
+	assert.NoError(t, json.Unmarshal(resp.Body, &list))
+	assert.Len(t, list, 1)
+	assert.Equal(t, "failed", list[0].Status)
+	assert.Equal(t, 0, list[0].ChunksDone)
+
+	resp = enviar(t, d, "GET", "/mapreduce/9", nil)
+	assert.Equal(t, 404, resp.StatusCode)
+}
@@ -0,0 +1,189 @@
+// registry.go (en módulo dispatcher)
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"http-servidor/utils"
+	"http-shared/routes"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// newEpoch genera el identificador de esta instancia del dispatcher. Los workers lo
+// reciben en /suscribir y /heartbeat; si cambia se vuelven a registrar.
+func newEpoch() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// sendRegistration responde a /suscribir o /heartbeat con el estado y el epoch.
+// id 0 omite el ID del worker.
+func (d *Dispatcher) sendRegistration(conn net.Conn, id int, status string) {
+	reg := routes.Registration{Status: status, Epoch: d.Epoch}
+	if id > 0 {
+		reg.ID = strconv.Itoa(id)
+	}
+	body, _ := json.Marshal(reg)
+	utils.SendJSON(conn, "200 OK", body)
+}
+
+// registryFile es lo que se guarda de los workers al apagar el dispatcher
+type registryFile struct {
+	SavedAt      time.Time       `json:"saved_at"`
+	NextWorkerID int             `json:"next_worker_id"`
+	Workers      []registryEntry `json:"workers"`
+}
+
+// registryEntry guarda un worker con las capacidades con que se registró
+type registryEntry struct {
+	ID                int                 `json:"id"`
+	Legacy            bool                `json:"legacy,omitempty"` // Se registró sin capacidades
+	HeartbeatInterval time.Duration       `json:"heartbeat_interval"`
+	Capabilities      routes.Capabilities `json:"capabilities"`
+}
+
+// registryPathFromEnv lee REGISTRY_PATH. Vacía no guarda los workers al apagar.
+func registryPathFromEnv() string {
+	return os.Getenv("REGISTRY_PATH")
+}
+
+// SaveRegistry escribe en path los workers registrados, salvo los que se están drenando.
+// El archivo se escribe aparte y reemplaza al anterior con rename.
+func (d *Dispatcher) SaveRegistry(path string) error {
+	d.Mu.RLock()
+	file := registryFile{SavedAt: time.Now(), NextWorkerID: d.nextWorkerID}
+	for _, w := range d.Workers {
+		w.mu.RLock()
+		if !w.Draining {
+			entry := registryEntry{
+				ID:                w.ID,
+				Legacy:            w.legacy,
+				HeartbeatInterval: w.heartbeatInterval,
+				Capabilities: routes.Capabilities{
+					URL:            w.URL,
+					Name:           w.Name,
+					Version:        w.Version,
+					Labels:         w.Labels,
+					MaxConcurrency: w.maxCapacity,
+				},
+			}
+			if w.routes != nil {
+				entry.Capabilities.Routes = w.routes.Routes()
+			}
+			file.Workers = append(file.Workers, entry)
+		}
+		w.mu.RUnlock()
+	}
+	d.Mu.RUnlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	log.Printf("Registro de %d workers guardado en %s", len(file.Workers), path)
+	return nil
+}
+
+// LoadRegistry vuelve a registrar los workers que se guardaron al apagar. Quedan como
+// sospechosos hasta que verifyWorker confirme que responden, así no hace falta que cada
+// worker se vuelva a suscribir. Un archivo que no existe no es un error.
+func (d *Dispatcher) LoadRegistry(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var file registryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("registro inválido %s: %v", path, err)
+	}
+
+	var restored []*Worker
+	d.Mu.Lock()
+	known := make(map[string]bool, len(d.Workers))
+	for _, w := range d.Workers {
+		known[w.URL] = true
+	}
+	if file.NextWorkerID > d.nextWorkerID {
+		d.nextWorkerID = file.NextWorkerID
+	}
+	for _, entry := range file.Workers {
+		caps := entry.Capabilities
+		caps.URL = cleanURL(caps.URL)
+		if caps.URL == "" || caps.MaxConcurrency <= 0 || known[caps.URL] {
+			continue
+		}
+		w := NewWorker(entry.ID, caps.URL, caps.MaxConcurrency)
+		w.legacy = entry.Legacy
+		if !entry.Legacy || len(caps.Routes) > 0 {
+			w.applyCapabilities(caps)
+		}
+		if entry.HeartbeatInterval > 0 {
+			w.heartbeatInterval = entry.HeartbeatInterval
+		}
+		w.lastChecked = time.Now()
+		w.setState(WorkerSuspect)
+		known[caps.URL] = true
+		d.Workers = append(d.Workers, w)
+		restored = append(restored, w)
+	}
+	d.rebuildRoutes()
+	d.Mu.Unlock()
+
+	for _, w := range restored {
+		w.start(d)
+		go d.verifyWorker(w)
+	}
+	if len(restored) > 0 {
+		log.Printf("Registro: %d workers restaurados de %s", len(restored), path)
+	}
+	return len(restored), nil
+}
+
+// verifyWorker consulta /routes de un worker restaurado. Si responde pasa a vivo con sus
+// rutas actuales; si no, sigue sospechoso y los heartbeats que no lleguen lo darán por muerto.
+func (d *Dispatcher) verifyWorker(w *Worker) {
+	if err := d.fetchRoutes(w); err != nil {
+		log.Printf("Worker %d (%s) restaurado no respondió: %v", w.ID, w.URL, err)
+		return
+	}
+	d.Mu.Lock()
+	d.rebuildRoutes()
+	d.Mu.Unlock()
+
+	w.mu.Lock()
+	w.lastChecked = time.Now()
+	w.lastHeartbeat = w.lastChecked
+	w.setState(WorkerAlive)
+	w.mu.Unlock()
+}
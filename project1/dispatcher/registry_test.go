@@ -0,0 +1,92 @@
+package main
+
+import (
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRoutesWorker levanta un worker de prueba que publica list en /routes, responde 200
+// al resto y cierra cada conexión después de responder
+func newRoutesWorker(t *testing.T, list []routes.Route) string {
+	return newTestWorker(t, func(conn net.Conn, req *httpmsg.Request, body []byte) bool {
+		w := httpmsg.NewWriter(conn, req)
+		w.Header().Set("Connection", "close")
+		if req.Target == "/routes" {
+			httpmsg.JSON(w, 200, list)
+		} else {
+			httpmsg.Text(w, 200, "ok")
+		}
+		w.Finish()
+		return false
+	}).addr
+}
+
+// Prueba que el registro guardado al apagar se cargue en otro dispatcher, que los
+// workers queden sospechosos hasta verificarse y que se conserven los IDs
+func TestRegistroSeRestaura(t *testing.T) {
+	get := []string{"GET"}
+	list := []routes.Route{{Path: "/fibonacci", Methods: get, PoolSize: 3}}
+	addr := newRoutesWorker(t, list)
+	path := filepath.Join(t.TempDir(), "registro.json")
+
+	d := newDispatcher()
+	registrar(t, d, routes.Capabilities{URL: addr, Name: "vivo", Version: "1.2.0", MaxConcurrency: 3, Routes: list})
+	registrar(t, d, routes.Capabilities{URL: "127.0.0.1:1", Name: "caido", MaxConcurrency: 2, Routes: list})
+	registrar(t, d, routes.Capabilities{URL: "drenando:8080", MaxConcurrency: 2, Routes: list})
+	enviar(t, d, "GET", "/desuscribir?url=drenando:8080", nil)
+	assert.NoError(t, d.SaveRegistry(path))
+
+	restored := newDispatcher()
+	n, err := restored.LoadRegistry(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n, "El worker en drenaje no se guarda")
+
+	vivo, caido := restored.findWorker(addr), restored.findWorker("127.0.0.1:1")
+	if !assert.NotNil(t, vivo) || !assert.NotNil(t, caido) {
+		return
+	}
+	assert.Equal(t, 1, vivo.ID)
+	assert.Equal(t, "vivo", vivo.Name)
+	assert.Equal(t, 3, cap(vivo.taskQueue))
+	esperarHasta(t, "El worker que responde no pasó a vivo", func() bool {
+		vivo.mu.RLock()
+		defer vivo.mu.RUnlock()
+		return vivo.State == WorkerAlive
+	})
+	caido.mu.RLock()
+	assert.Equal(t, WorkerSuspect, caido.State)
+	caido.mu.RUnlock()
+
+	// Las rutas están disponibles sin que el worker se vuelva a suscribir
+	assert.Equal(t, 200, enviar(t, restored, "GET", "/fibonacci?num=3", nil).StatusCode)
+
+	// Los IDs siguen después del último asignado antes del apagado
+	registrar(t, restored, routes.Capabilities{URL: "nuevo:8080", MaxConcurrency: 1, Routes: list})
+	assert.Equal(t, 4, restored.findWorker("nuevo:8080").ID)
+
+	// Cargar de nuevo no duplica workers
+	n, err = restored.LoadRegistry(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+// Prueba que sin archivo no haya error y que un archivo dañado sí lo sea
+func TestRegistroInexistenteOInvalido(t *testing.T) {
+	d := newDispatcher()
+	dir := t.TempDir()
+	n, err := d.LoadRegistry(filepath.Join(dir, "no-existe.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	path := filepath.Join(dir, "roto.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{roto"), 0644))
+	_, err = d.LoadRegistry(path)
+	assert.Error(t, err)
+	assert.Empty(t, d.Workers)
+}
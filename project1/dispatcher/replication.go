@@ -0,0 +1,460 @@
+// replication.go (en módulo dispatcher)
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"http-servidor/utils"
+	"http-shared/httpmsg"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultReplicationFactor = 3               // Copias de cada archivo
+	RepairDelay              = 3 * time.Second // Espera antes de reparar, el worker se registra antes de escuchar
+	ReplicaTimeout           = 5 * time.Second
+)
+
+// Replication guarda cada archivo en Factor workers. Las réplicas de un archivo son
+// los primeros Factor workers distintos a partir de su nombre en el anillo de hash,
+// así la primera réplica es el mismo worker que elige la estrategia hash:name.
+type Replication struct {
+	Factor int // Copias de cada archivo; con 1 no se replica
+	Quorum int // Réplicas que deben confirmar cada escritura
+
+	ring      *consistentHash
+	repairMu  sync.Mutex // Una reparación a la vez
+	scheduled int32      // Hay una reparación programada
+
+	deletedMu sync.Mutex
+	deleted   map[string]time.Time // Archivos borrados con /deletefile; la reparación borra las copias que queden
+}
+
+// NewReplication crea la configuración. Con quorum 0 se usa la mayoría de factor.
+func NewReplication(factor, quorum int) *Replication {
+	if factor < 1 {
+		factor = 1
+	}
+	if quorum <= 0 || quorum > factor {
+		quorum = factor/2 + 1
+	}
+	return &Replication{Factor: factor, Quorum: quorum, ring: newConsistentHash(HashReplicas),
+		deleted: make(map[string]time.Time)}
+}
+
+// recordWrite anota el último cambio confirmado por alguna réplica: un borrado o
+// una escritura que lo deja sin efecto
+func (r *Replication) recordWrite(name string, deleted bool) {
+	r.deletedMu.Lock()
+	defer r.deletedMu.Unlock()
+	if deleted {
+		r.deleted[name] = time.Now()
+	} else {
+		delete(r.deleted, name)
+	}
+}
+
+// isDeleted indica si el último cambio confirmado del archivo fue borrarlo
+func (r *Replication) isDeleted(name string) bool {
+	r.deletedMu.Lock()
+	defer r.deletedMu.Unlock()
+	_, ok := r.deleted[name]
+	return ok
+}
+
+// replicationFromEnv lee REPLICAS y WRITE_QUORUM
+func replicationFromEnv() *Replication {
+	factor, quorum := DefaultReplicationFactor, 0
+	if v, err := strconv.Atoi(os.Getenv("REPLICAS")); err == nil {
+		factor = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("WRITE_QUORUM")); err == nil {
+		quorum = v
+	}
+	return NewReplication(factor, quorum)
+}
+
+// Enabled indica si los archivos se replican
+func (r *Replication) Enabled() bool {
+	return r != nil && r.Factor > 1
+}
+
+// isFileRoute indica si la ruta trabaja sobre files/ del worker
+func isFileRoute(route string) bool {
+	for _, r := range FileRoutes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// replicas retorna los workers que guardan el archivo, en orden de preferencia.
+// Los sospechosos siguen siendo réplicas; los muertos y los que se drenan no.
+func (d *Dispatcher) replicas(name string) []*Worker {
+	members := d.candidatos(FileRoutes[0], WorkerSuspect)
+	return d.Replication.ring.owners(members, name, d.Replication.Factor)
+}
+
+// callReplica envía una solicitud a una réplica a través de su cola como cualquier
+// otra tarea. Un error de comunicación deja a la réplica sospechosa.
+func (d *Dispatcher) callReplica(w *Worker, method, target string, header httpmsg.Header, body []byte) (*httpmsg.Response, error) {
+	resp, err := d.call(w, &Request{Method: method, Path: target, Header: header, Body: body, Timeout: ReplicaTimeout})
+	if err != nil {
+		if !errors.Is(err, errWorkerBusy) {
+			d.markSuspect(w)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replicaResult es la respuesta de una réplica a una escritura
+type replicaResult struct {
+	worker *Worker
+	resp   *httpmsg.Response
+	err    error
+}
+
+// broadcast envía la misma solicitud a todas las réplicas en paralelo
+func (d *Dispatcher) broadcast(replicas []*Worker, method, target string, body []byte) []replicaResult {
+	results := make([]replicaResult, len(replicas))
+	var wg sync.WaitGroup
+	for i, w := range replicas {
+		wg.Add(1)
+		go func(i int, w *Worker) {
+			defer wg.Done()
+			resp, err := d.callReplica(w, method, target, nil, body)
+			results[i] = replicaResult{worker: w, resp: resp, err: err}
+		}(i, w)
+	}
+	wg.Wait()
+	return results
+}
+
+// Lo que informa cada escritura replicada al cliente
+var replicatedWrites = map[string]string{
+	"/createfile": "creado",
+	"/deletefile": "eliminado",
+	"/appendfile": "actualizado",
+	"/uploadfile": "guardado",
+}
+
+// handleReplicated atiende las rutas de archivos cuando hay replicación. Las
+// escrituras van a todas las réplicas; /readfile y /stat, a la primera que responda.
+func (d *Dispatcher) handleReplicated(conn net.Conn, req *Request) {
+	replicas := d.replicas(req.Params[FileKeyParam])
+	if len(replicas) == 0 {
+		utils.SendResponse(conn, "503 Service Unavailable", "No hay workers disponibles")
+		d.Metrics.mu.Lock()
+		d.Metrics.RequestsFailed++
+		d.Metrics.mu.Unlock()
+		return
+	}
+
+	target := req.Path + "?" + httpmsg.FromMap(req.Params).Encode()
+	action, write := replicatedWrites[req.Path]
+	if !write {
+		d.readReplica(conn, replicas, req.Method, target, req.Header)
+		return
+	}
+	results := d.broadcast(replicas, req.Method, target, req.Body)
+
+	acks := 0
+	var failed *httpmsg.Response
+	for _, res := range results {
+		switch {
+		case res.err != nil:
+			log.Printf("Réplica %s no respondió a %s: %v", res.worker.URL, req.Path, res.err)
+		case res.resp.StatusCode == 200:
+			acks++
+		default:
+			failed = res.resp
+		}
+	}
+
+	if acks > 0 {
+		d.Replication.recordWrite(req.Params[FileKeyParam], req.Path == "/deletefile")
+	}
+	if acks < len(replicas) {
+		// Las réplicas que quedaron atrás se corrigen en la próxima reparación
+		d.scheduleRepair()
+	}
+	if acks >= d.Replication.Quorum {
+		utils.SendResponse(conn, "200 OK", fmt.Sprintf("Archivo %s en %d de %d réplicas\n", action, acks, len(replicas)))
+		return
+	}
+
+	d.Metrics.mu.Lock()
+	d.Metrics.RequestsFailed++
+	d.Metrics.mu.Unlock()
+	if acks == 0 && failed != nil {
+		// Todas las réplicas que respondieron rechazaron la solicitud: se reenvía su respuesta
+		utils.SendWorkerResponse(conn, failed.StatusCode, failed.Header, failed.Body)
+		return
+	}
+	utils.SendResponse(conn, "503 Service Unavailable",
+		fmt.Sprintf("Solo %d de %d réplicas confirmaron, se requieren %d\n", acks, len(replicas), d.Replication.Quorum))
+}
+
+// readReplica reenvía la lectura a la primera réplica sana que tenga el archivo. Se
+// prueban primero las réplicas vivas y después las sospechosas.
+func (d *Dispatcher) readReplica(conn net.Conn, replicas []*Worker, method, target string, header httpmsg.Header) {
+	ordered := make([]*Worker, 0, len(replicas))
+	for _, state := range []WorkerState{WorkerAlive, WorkerSuspect} {
+		for _, w := range replicas {
+			w.mu.RLock()
+			match := w.State == state
+			w.mu.RUnlock()
+			if match {
+				ordered = append(ordered, w)
+			}
+		}
+	}
+
+	notFound := false
+	for _, w := range ordered {
+		resp, err := d.callReplica(w, method, target, header, nil)
+		if err != nil {
+			log.Printf("Réplica %s no respondió a %s: %v", w.URL, target, err)
+			continue
+		}
+		if resp.StatusCode == 404 {
+			notFound = true
+			continue
+		}
+		utils.SendWorkerResponse(conn, resp.StatusCode, resp.Header, resp.Body)
+		return
+	}
+
+	if notFound {
+		utils.SendResponse(conn, "404 Not Found", "El archivo no existe\n")
+		return
+	}
+	utils.SendResponse(conn, "503 Service Unavailable", "Ninguna réplica respondió\n")
+}
+
+// RepairReport resume una pasada de reparación
+type RepairReport struct {
+	Workers int      `json:"workers"` // Workers cuyos digests se compararon
+	Files   int      `json:"files"`
+	Copied  int      `json:"copied"`
+	Deleted int      `json:"deleted"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// scheduleRepair programa una reparación. Las llamadas que llegan mientras hay una
+// programada se juntan en una sola.
+func (d *Dispatcher) scheduleRepair() {
+	r := d.Replication
+	if !r.Enabled() || !atomic.CompareAndSwapInt32(&r.scheduled, 0, 1) {
+		return
+	}
+	time.AfterFunc(RepairDelay, func() {
+		atomic.StoreInt32(&r.scheduled, 0)
+		report := d.repairFiles()
+		log.Printf("Reparación de réplicas: %d archivos en %d workers, %d copiados, %d eliminados, %d errores",
+			report.Files, report.Workers, report.Copied, report.Deleted, len(report.Errors))
+	})
+}
+
+// repairFiles compara los SHA-256 de cada archivo entre todos los workers que responden
+// y completa las réplicas que no lo tienen o tienen otro contenido. Gana el contenido que
+// tiene la mayoría de las réplicas actuales que lo guardan y, si ninguna lo guarda, el de
+// la mayoría de las demás copias, por ejemplo las de réplicas anteriores a un cambio en el
+// anillo. Que falten copias nunca borra un archivo: solo se borran las copias de los
+// archivos eliminados con /deletefile, en cualquier worker. Las copias en workers que ya
+// no son réplicas se dejan como están.
+func (d *Dispatcher) repairFiles() RepairReport {
+	r := d.Replication
+	r.repairMu.Lock()
+	defer r.repairMu.Unlock()
+
+	var report RepairReport
+	members := d.candidatos(FileRoutes[0], WorkerSuspect)
+	digests := make(map[*Worker]map[string]string, len(members))
+	names := make(map[string]bool)
+	for _, w := range members {
+		list, err := d.fetchDigests(w)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		digests[w] = list
+		for name := range list {
+			names[name] = true
+		}
+	}
+	report.Workers = len(digests)
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		if r.isDeleted(name) {
+			for _, w := range members {
+				if digests[w][name] == "" || !r.isDeleted(name) {
+					continue
+				}
+				if err := d.deleteReplica(w, name); err != nil {
+					report.Errors = append(report.Errors, err.Error())
+				} else {
+					report.Deleted++
+				}
+			}
+			continue
+		}
+		report.Files++
+
+		var known []*Worker // Réplicas que respondieron, en orden del anillo
+		owner := make(map[*Worker]bool)
+		for _, w := range r.ring.owners(members, name, r.Factor) {
+			owner[w] = true
+			if _, ok := digests[w]; ok {
+				known = append(known, w)
+			}
+		}
+		var others []*Worker
+		for _, w := range members {
+			if _, ok := digests[w]; ok && !owner[w] {
+				others = append(others, w)
+			}
+		}
+		winner := majorityDigest(known, digests, name)
+		if winner == "" {
+			winner = majorityDigest(others, digests, name)
+		}
+
+		var source *Worker
+		for _, w := range append(known, others...) {
+			if digests[w][name] == winner {
+				source = w
+				break
+			}
+		}
+		for _, w := range known {
+			if digests[w][name] == winner {
+				continue
+			}
+			if err := d.copyReplica(source, w, name, winner); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+			} else {
+				report.Copied++
+			}
+		}
+	}
+	return report
+}
+
+// majorityDigest retorna el digest que tiene la mayoría de las copias del archivo en
+// replicas, sin contar las que no lo tienen ("" si ninguna lo tiene). En un empate gana
+// la réplica que va primero.
+func majorityDigest(replicas []*Worker, digests map[*Worker]map[string]string, name string) string {
+	counts := make(map[string]int)
+	var order []string
+	for _, w := range replicas {
+		sum := digests[w][name]
+		if sum == "" {
+			continue
+		}
+		if counts[sum] == 0 {
+			order = append(order, sum)
+		}
+		counts[sum]++
+	}
+	winner, best := "", 0
+	for _, sum := range order {
+		if c := counts[sum]; c > best {
+			winner, best = sum, c
+		}
+	}
+	return winner
+}
+
+// fetchDigests consulta /filedigests en el worker
+func (d *Dispatcher) fetchDigests(w *Worker) (map[string]string, error) {
+	resp, err := d.callReplica(w, "GET", "/filedigests", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("digests de %s: %v", w.URL, err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("digests de %s: código %d", w.URL, resp.StatusCode)
+	}
+	var list map[string]string
+	if err := json.Unmarshal(resp.Body, &list); err != nil {
+		return nil, fmt.Errorf("digests de %s: %v", w.URL, err)
+	}
+	return list, nil
+}
+
+// copyReplica lee el archivo de source y lo sube a target. El contenido se verifica
+// contra el digest esperado por si cambió mientras tanto.
+func (d *Dispatcher) copyReplica(source, target *Worker, name, digest string) error {
+	query := httpmsg.FromMap(map[string]string{FileKeyParam: name}).Encode()
+	resp, err := d.callReplica(source, "GET", "/readfile?"+query, nil, nil)
+	if err != nil {
+		return fmt.Errorf("leer %s de %s: %v", name, source.URL, err)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("leer %s de %s: código %d", name, source.URL, resp.StatusCode)
+	}
+	sum := sha256.Sum256(resp.Body)
+	if hex.EncodeToString(sum[:]) != digest {
+		return fmt.Errorf("%s cambió en %s durante la reparación", name, source.URL)
+	}
+
+	resp, err = d.callReplica(target, "POST", "/uploadfile?"+query, nil, resp.Body)
+	if err != nil {
+		return fmt.Errorf("copiar %s a %s: %v", name, target.URL, err)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("copiar %s a %s: código %d", name, target.URL, resp.StatusCode)
+	}
+	log.Printf("Reparación: %s copiado de %s a %s", name, source.URL, target.URL)
+	return nil
+}
+
+// deleteReplica borra la copia de un archivo eliminado con /deletefile
+func (d *Dispatcher) deleteReplica(w *Worker, name string) error {
+	query := httpmsg.FromMap(map[string]string{FileKeyParam: name}).Encode()
+	resp, err := d.callReplica(w, "GET", "/deletefile?"+query, nil, nil)
+	if err != nil {
+		return fmt.Errorf("eliminar %s de %s: %v", name, w.URL, err)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("eliminar %s de %s: código %d", name, w.URL, resp.StatusCode)
+	}
+	log.Printf("Reparación: %s eliminado de %s", name, w.URL)
+	return nil
+}
+
+// repairHandler atiende POST /admin/repair: ejecuta la reparación y retorna el resumen
+func (d *Dispatcher) repairHandler(conn net.Conn, method string) {
+	if method != "POST" {
+		utils.SendResponse(conn, "405 Method Not Allowed", "Use POST")
+		return
+	}
+	if !d.Replication.Enabled() {
+		utils.SendResponse(conn, "409 Conflict", "La replicación está desactivada (REPLICAS=1)")
+		return
+	}
+	jsonData, err := json.MarshalIndent(d.repairFiles(), "", "  ")
+	if err != nil {
+		utils.SendResponse(conn, "500 Internal Server Error", "Error generando JSON")
+		return
+	}
+	utils.SendJSON(conn, "200 OK", jsonData)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fileWorker es un worker de prueba que guarda los archivos en memoria
+type fileWorker struct {
+	*testWorker
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func newFileWorker(t *testing.T) *fileWorker {
+	fw := &fileWorker{files: make(map[string]string)}
+	fw.testWorker = newTestWorker(t, fw.handle)
+	return fw
+}
+
+func (fw *fileWorker) handle(conn net.Conn, req *httpmsg.Request, body []byte) bool {
+	path, query, _ := httpmsg.ParseRoute(req.Target)
+	name := query.Get("name")
+	w := httpmsg.NewWriter(conn, req)
+
+	fw.mu.Lock()
+	content, exists := fw.files[name]
+	switch path {
+	case "/createfile":
+		fw.files[name] = query.Get("content")
+		httpmsg.Text(w, 200, "Archivo creado exitosamente\n")
+	case "/uploadfile":
+		fw.files[name] = string(body)
+		httpmsg.Text(w, 200, "Archivo guardado exitosamente\n")
+	case "/deletefile":
+		if !exists {
+			httpmsg.Text(w, 500, "Error al eliminar el archivo (puede que no exista)\n")
+			break
+		}
+		delete(fw.files, name)
+		httpmsg.Text(w, 200, "Archivo eliminado exitosamente\n")
+	case "/appendfile":
+		fw.files[name] = content + string(body)
+		httpmsg.Text(w, 200, "Contenido agregado exitosamente\n")
+	case "/readfile":
+		if !exists {
+			httpmsg.Text(w, 404, "El archivo no existe\n")
+			break
+		}
+		if r, ok, _ := httpmsg.ParseRange(req.Header.Get("Range"), int64(len(content))); ok {
+			w.Header().Set("Content-Range", r.ContentRange(int64(len(content))))
+			httpmsg.Text(w, 206, content[r.Start:r.End+1])
+			break
+		}
+		httpmsg.Text(w, 200, content)
+	case "/stat":
+		if !exists {
+			httpmsg.Text(w, 404, "El archivo no existe\n")
+			break
+		}
+		httpmsg.JSON(w, 200, routes.FileInfo{Name: name, Size: int64(len(content)), SHA256: digestOf(content)})
+	case "/listfiles":
+		list := []routes.FileInfo{}
+		for file, data := range fw.files {
+			list = append(list, routes.FileInfo{Name: file, Size: int64(len(data))})
+		}
+		httpmsg.JSON(w, 200, list)
+	case "/filedigests":
+		digests := make(map[string]string)
+		for file, data := range fw.files {
+			digests[file] = digestOf(data)
+		}
+		httpmsg.JSON(w, 200, digests)
+	}
+	fw.mu.Unlock()
+	w.Finish()
+	return true
+}
+
+func (fw *fileWorker) file(name string) (string, bool) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	content, ok := fw.files[name]
+	return content, ok
+}
+
+func (fw *fileWorker) put(name, content string) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.files[name] = content
+}
+
+func digestOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// dispatcherConArchivos registra n workers de archivos en un dispatcher con replicación factor
+func dispatcherConArchivos(t *testing.T, n, factor int) (*Dispatcher, map[string]*fileWorker) {
+	d := newDispatcher()
+	d.Replication = NewReplication(factor, 0)
+	get := []string{"GET"}
+	post := []string{"POST"}
+	fileRoutes := []routes.Route{
+		{Path: "/createfile", Methods: get}, {Path: "/deletefile", Methods: get}, {Path: "/readfile", Methods: get},
+		{Path: "/stat", Methods: get}, {Path: "/listfiles", Methods: get}, {Path: "/filedigests", Methods: get},
+		{Path: "/uploadfile", Methods: post}, {Path: "/appendfile", Methods: post},
+	}
+	workers := make(map[string]*fileWorker)
+	for i := 0; i < n; i++ {
+		fw := newFileWorker(t)
+		workers[fw.addr] = fw
+		registrar(t, d, routes.Capabilities{URL: fw.addr, MaxConcurrency: 4, Routes: fileRoutes})
+	}
+	return d, workers
+}
+
+// Prueba que /createfile llegue a las réplicas, /readfile lea de cualquiera y /deletefile borre en todas
+func TestReplicacionArchivos(t *testing.T) {
+	d, workers := dispatcherConArchivos(t, 4, 3)
+
+	resp := enviar(t, d, "GET", "/createfile?name=a.txt&content=hola&repeat=1", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(resp.Body), "3 de 3")
+
+	replicas := d.replicas("a.txt")
+	assert.Len(t, replicas, 3)
+	holders := 0
+	for _, fw := range workers {
+		if _, ok := fw.file("a.txt"); ok {
+			holders++
+		}
+	}
+	assert.Equal(t, 3, holders)
+	for _, w := range replicas {
+		content, ok := workers[w.URL].file("a.txt")
+		assert.True(t, ok, "la réplica %s no tiene el archivo", w.URL)
+		assert.Equal(t, "hola", content)
+	}
+
+	// Con la primera réplica caída se lee de la siguiente
+	workers[replicas[0].URL].stop()
+	resp = enviar(t, d, "GET", "/readfile?name=a.txt", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "hola", string(resp.Body))
+	assert.Equal(t, WorkerSuspect, replicas[0].State)
+	assert.Equal(t, 404, enviar(t, d, "GET", "/readfile?name=b.txt", nil).StatusCode)
+
+	// El sospechoso sigue siendo réplica; las otras dos alcanzan el quorum
+	resp = enviar(t, d, "GET", "/deletefile?name=a.txt", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(resp.Body), "2 de 3")
+	for _, w := range replicas[1:] {
+		_, ok := workers[w.URL].file("a.txt")
+		assert.False(t, ok)
+	}
+}
+
+// Prueba que sin quorum la escritura falle
+func TestReplicacionSinQuorum(t *testing.T) {
+	d, workers := dispatcherConArchivos(t, 3, 3)
+	replicas := d.replicas("a.txt")
+	workers[replicas[0].URL].stop()
+	workers[replicas[1].URL].stop()
+
+	resp := enviar(t, d, "GET", "/createfile?name=a.txt&content=hola&repeat=1", nil)
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Contains(t, string(resp.Body), "Solo 1 de 3")
+
+	// Un rechazo de todas las réplicas se reenvía tal cual
+	resp = enviar(t, d, "GET", "/deletefile?name=z.txt", nil)
+	assert.Equal(t, 500, resp.StatusCode)
+}
+
+// Prueba la reparación por digests: completa copias faltantes, corrige las viejas y
+// no revive archivos borrados mientras una réplica estaba caída
+func TestRepararReplicas(t *testing.T) {
+	d, workers := dispatcherConArchivos(t, 3, 3)
+	var list []*fileWorker
+	for _, w := range d.Workers {
+		list = append(list, workers[w.URL])
+	}
+	rejoined := list[2]
+
+	list[0].put("faltante.txt", "nuevo")
+	list[1].put("faltante.txt", "nuevo")
+	// borrado.txt se eliminó mientras rejoined estaba caído
+	rejoined.put("borrado.txt", "viejo")
+	d.Replication.recordWrite("borrado.txt", true)
+	// solo.txt quedó en una réplica: no se borra aunque las otras no lo tengan
+	rejoined.put("solo.txt", "único")
+	for _, fw := range list {
+		fw.put("editado.txt", "v2")
+	}
+	rejoined.put("editado.txt", "v1")
+
+	resp := enviar(t, d, "POST", "/admin/repair", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	var report RepairReport
+	assert.NoError(t, json.Unmarshal(resp.Body, &report))
+	assert.Equal(t, RepairReport{Workers: 3, Files: 3, Copied: 4, Deleted: 1}, report)
+
+	content, ok := rejoined.file("faltante.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "nuevo", content)
+	content, _ = rejoined.file("editado.txt")
+	assert.Equal(t, "v2", content)
+	_, ok = rejoined.file("borrado.txt")
+	assert.False(t, ok)
+	for _, fw := range list {
+		content, _ = fw.file("solo.txt")
+		assert.Equal(t, "único", content)
+	}
+
+	// Una segunda pasada no encuentra diferencias
+	assert.Equal(t, RepairReport{Workers: 3, Files: 3}, d.repairFiles())
+
+	// Crear de nuevo un archivo borrado quita la anotación
+	assert.Equal(t, 200, enviar(t, d, "GET", "/createfile?name=borrado.txt&content=otra&repeat=1", nil).StatusCode)
+	assert.Equal(t, RepairReport{Workers: 3, Files: 4}, d.repairFiles())
+}
+
+// Prueba que cuando cambian las réplicas de un archivo la reparación lo copie a las
+// nuevas desde las que quedan o desde las anteriores, sin borrarlo
+func TestRepararCambioDeReplicas(t *testing.T) {
+	d, workers := dispatcherConArchivos(t, 5, 3)
+	owner := make(map[string]bool)
+	for _, w := range d.replicas("a.txt") {
+		owner[w.URL] = true
+	}
+	// a.txt quedó en una réplica actual y en dos workers que ya no son réplicas;
+	// b.txt solo en workers que no son sus réplicas
+	var former []*fileWorker
+	for url, fw := range workers {
+		if !owner[url] {
+			former = append(former, fw)
+		}
+	}
+	workers[d.replicas("a.txt")[2].URL].put("a.txt", "v1")
+	for _, fw := range former {
+		fw.put("a.txt", "v0")
+	}
+	var formerB []*fileWorker
+	ownerB := make(map[string]bool)
+	for _, w := range d.replicas("b.txt") {
+		ownerB[w.URL] = true
+	}
+	for url, fw := range workers {
+		if !ownerB[url] {
+			formerB = append(formerB, fw)
+		}
+	}
+	formerB[0].put("b.txt", "hola")
+
+	report := d.repairFiles()
+	assert.Equal(t, 0, report.Deleted)
+	assert.Empty(t, report.Errors)
+	for _, w := range d.replicas("a.txt") {
+		content, ok := workers[w.URL].file("a.txt")
+		assert.True(t, ok)
+		assert.Equal(t, "v1", content, "gana la copia de la réplica actual")
+	}
+	for _, fw := range former {
+		content, _ := fw.file("a.txt")
+		assert.Equal(t, "v0", content, "las copias fuera de las réplicas no se tocan")
+	}
+	for _, w := range d.replicas("b.txt") {
+		content, _ := workers[w.URL].file("b.txt")
+		assert.Equal(t, "hola", content)
+	}
+	_, ok := formerB[0].file("b.txt")
+	assert.True(t, ok)
+}
+
+// Prueba el desempate del voto por mayoría
+func TestMajorityDigest(t *testing.T) {
+	ws := workersDePrueba(1, 1, 1, 1)
+	digests := map[*Worker]map[string]string{
+		ws[0]: {"a": "x"}, ws[1]: {}, ws[2]: {"a": "y"}, ws[3]: {"a": "y"},
+	}
+	assert.Equal(t, "y", majorityDigest(ws, digests, "a"))
+	assert.Equal(t, "x", majorityDigest(ws[:2], digests, "a"))
+	assert.Equal(t, "x", majorityDigest(ws[:3], digests, "a"))
+	assert.Equal(t, "", majorityDigest(ws[1:2], digests, "a"))
+}
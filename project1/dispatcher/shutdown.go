@@ -0,0 +1,140 @@
+// shutdown.go (en módulo dispatcher)
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ShutdownTimeout      = 60 * time.Second // Plazo de gracia por defecto para las solicitudes en curso
+	shutdownCancelWait   = time.Second      // Espera a los trabajos asíncronos después de cancelarlos
+	shutdownPollInterval = 50 * time.Millisecond
+)
+
+// Serve acepta conexiones hasta que Shutdown cierre el listener
+func (d *Dispatcher) Serve(ln net.Listener) {
+	d.Mu.Lock()
+	d.Listener = ln
+	d.Mu.Unlock()
+	if d.isShuttingDown() { // Shutdown empezó antes de que hubiera listener que cerrar
+		ln.Close()
+		return
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if d.isShuttingDown() {
+				return
+			}
+			log.Printf("Error aceptando conexión: %v", err)
+			continue
+		}
+		go d.ServeConn(conn)
+	}
+}
+
+// runHealthChecks revisa a los workers cada HealthCheckInterval hasta que se cierre DoneChan
+func (d *Dispatcher) runHealthChecks() {
+	ticker := time.NewTicker(HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.HealthCheck()
+		case <-d.DoneChan:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) isShuttingDown() bool {
+	return atomic.LoadInt32(&d.shuttingDown) == 1
+}
+
+// Shutdown apaga el dispatcher: deja de aceptar conexiones, detiene los health checks,
+// espera hasta grace las solicitudes en curso (incluidos los trabajos map-reduce) y los
+// trabajos asíncronos, guarda el registro de workers en RegistryPath y cierra el WAL.
+// Si vence el plazo cancela los trabajos asíncronos que quedan y retorna un error, igual
+// que si no se pudo guardar el registro. Los trabajos cancelados siguen pendientes en el
+// WAL y se reanudan al volver.
+func (d *Dispatcher) Shutdown(grace time.Duration) error {
+	if !atomic.CompareAndSwapInt32(&d.shuttingDown, 0, 1) {
+		return nil
+	}
+	log.Printf("Dispatcher: Iniciando apagado")
+	d.Mu.RLock()
+	ln := d.Listener
+	d.Mu.RUnlock()
+	if ln != nil {
+		ln.Close()
+	}
+	close(d.DoneChan)
+
+	var result error
+	idle := func() bool { return atomic.LoadInt64(&d.inFlight) == 0 && atomic.LoadInt64(&d.asyncJobs) == 0 }
+	if !waitUntil(time.Now().Add(grace), idle) {
+		pending, jobs := atomic.LoadInt64(&d.inFlight), atomic.LoadInt64(&d.asyncJobs)
+		log.Printf("Dispatcher: Se agotó el plazo de %v con %d solicitudes y %d trabajos en curso", grace, pending, jobs)
+		result = fmt.Errorf("%d solicitudes y %d trabajos sin terminar después de %v", pending, jobs, grace)
+		d.cancelJobs()
+		if !waitUntil(time.Now().Add(shutdownCancelWait), func() bool { return atomic.LoadInt64(&d.asyncJobs) == 0 }) {
+			log.Printf("Dispatcher: %d trabajos no terminaron después de cancelarlos", atomic.LoadInt64(&d.asyncJobs))
+		}
+	}
+
+	if d.RegistryPath != "" {
+		if err := d.SaveRegistry(d.RegistryPath); err != nil {
+			log.Printf("Dispatcher: No se pudo guardar el registro de workers: %v", err)
+			if result == nil {
+				result = err
+			}
+		}
+	}
+	if d.WAL.Enabled() {
+		if err := d.WAL.Close(); err != nil {
+			log.Printf("Dispatcher: Error cerrando el WAL: %v", err)
+		}
+	}
+	log.Printf("Dispatcher: Apagado completo")
+	return result
+}
+
+// waitUntil espera hasta que done sea verdadero o llegue deadline
+func waitUntil(deadline time.Time, done func() bool) bool {
+	for !done() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(shutdownPollInterval)
+	}
+	return true
+}
+
+// cancelJobs cancela el contexto de los trabajos asíncronos en curso
+func (d *Dispatcher) cancelJobs() {
+	d.Tasks.Range(func(_, value interface{}) bool {
+		if job, ok := value.(*Job); ok {
+			job.cancel()
+		}
+		return true
+	})
+}
+
+// shutdownGraceFromEnv lee SHUTDOWN_GRACE, por ejemplo "20s". Por defecto ShutdownTimeout.
+func shutdownGraceFromEnv() time.Duration {
+	v := os.Getenv("SHUTDOWN_GRACE")
+	if v == "" {
+		return ShutdownTimeout
+	}
+	grace, err := time.ParseDuration(v)
+	if err != nil || grace <= 0 {
+		log.Printf("SHUTDOWN_GRACE inválido %q, se usa %v", v, ShutdownTimeout)
+		return ShutdownTimeout
+	}
+	return grace
+}
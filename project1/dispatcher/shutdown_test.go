@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// servirDispatcher registra un gateWorker con /lento y atiende el dispatcher en un puerto local
+func servirDispatcher(t *testing.T) (*Dispatcher, *gateWorker, string) {
+	d := newDispatcher()
+	d.RegistryPath = filepath.Join(t.TempDir(), "registro.json")
+	gw := newGateWorker(t)
+	registrar(t, d, routes.Capabilities{URL: gw.addr, MaxConcurrency: 2,
+		Routes: []routes.Route{{Path: "/lento", Methods: []string{"GET"}}}})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo escuchar: %v", err)
+	}
+	go d.Serve(ln)
+	return d, gw, ln.Addr().String()
+}
+
+// Prueba que el apagado deje de aceptar conexiones, espere la solicitud en curso y
+// guarde el registro de workers
+func TestApagadoOrdenado(t *testing.T) {
+	d, gw, addr := servirDispatcher(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("No se pudo conectar: %v", err)
+	}
+	defer conn.Close()
+	httpmsg.WriteRequest(conn, "GET", "/lento", nil, nil)
+	esperarHasta(t, "La solicitud no llegó al worker", func() bool { return gw.inFlight() == 1 })
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- d.Shutdown(2 * time.Second) }()
+	esperarHasta(t, "El dispatcher siguió aceptando conexiones", func() bool {
+		c, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err == nil {
+			c.Close()
+		}
+		return err != nil
+	})
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("Shutdown no esperó la solicitud en curso: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	gw.open()
+	resp, err := httpmsg.ReadResponse(bufio.NewReader(conn), httpmsg.DefaultLimits)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, <-stopped)
+
+	var file registryFile
+	data, err := os.ReadFile(d.RegistryPath)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &file))
+	if assert.Len(t, file.Workers, 1) {
+		assert.Equal(t, gw.addr, file.Workers[0].Capabilities.URL)
+		assert.Equal(t, 2, file.Workers[0].Capabilities.MaxConcurrency)
+	}
+}
+
+// Prueba que Shutdown informe las solicitudes que no terminaron en el plazo
+func TestApagadoPlazo(t *testing.T) {
+	d, gw, addr := servirDispatcher(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("No se pudo conectar: %v", err)
+	}
+	defer conn.Close()
+	httpmsg.WriteRequest(conn, "GET", "/lento", nil, nil)
+	esperarHasta(t, "La solicitud no llegó al worker", func() bool { return gw.inFlight() == 1 })
+
+	start := time.Now()
+	assert.Error(t, d.Shutdown(100*time.Millisecond))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	_, err = os.Stat(d.RegistryPath)
+	assert.NoError(t, err, "El registro se guarda aunque venza el plazo")
+}
+
+// Prueba que el apagado espere a los trabajos asíncronos antes de cerrar el WAL y que,
+// si vence el plazo, los cancele dejándolos pendientes en el log
+func TestApagadoTrabajosAsincronos(t *testing.T) {
+	d, gw, _ := servirDispatcher(t)
+	path := filepath.Join(t.TempDir(), "dispatcher.wal")
+	d.WAL = abrirWAL(t, path)
+
+	job := leerTrabajo(t, enviar(t, d, "GET", "/lento?async=true", nil))
+	esperarHasta(t, "El trabajo no llegó al worker", func() bool { return gw.inFlight() == 1 })
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- d.Shutdown(2 * time.Second) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("Shutdown no esperó al trabajo asíncrono: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	gw.open()
+	assert.NoError(t, <-stopped)
+	assert.ErrorIs(t, d.WAL.append(walEvent{Type: WalMetrics}), errWALClosed)
+
+	state, err := abrirWAL(t, path).load()
+	assert.NoError(t, err)
+	assert.Empty(t, state.pending, "El fin del trabajo %d quedó en el log", job.ID)
+
+	// Con un plazo corto el trabajo se cancela y sigue pendiente
+	d, gw, _ = servirDispatcher(t)
+	path = filepath.Join(t.TempDir(), "dispatcher.wal")
+	d.WAL = abrirWAL(t, path)
+	job = leerTrabajo(t, enviar(t, d, "GET", "/lento?async=true", nil))
+	esperarHasta(t, "El trabajo no llegó al worker", func() bool { return gw.inFlight() == 1 })
+
+	assert.Error(t, d.Shutdown(100*time.Millisecond))
+	state, err = abrirWAL(t, path).load()
+	assert.NoError(t, err)
+	assert.Len(t, state.pending[job.ID], 2, "accepted y assigned, sin failed")
+}
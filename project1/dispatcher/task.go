@@ -2,6 +2,8 @@
 package main
 
 import (
+	"context"
+	"http-shared/httpmsg"
 	"net"
 	"time"
 )
@@ -15,22 +17,74 @@ const (
 	TaskFailed
 )
 
+var taskStatusNames = []string{"pending", "processing", "completed", "failed"}
+
+func (s TaskStatus) String() string {
+	if int(s) < len(taskStatusNames) {
+		return taskStatusNames[s]
+	}
+	return "unknown"
+}
+
 type Task struct {
 	ID          int      // UUID sería mejor para distribución
 	Conn        net.Conn // Conexión cliente original
 	Request     *Request // Datos de la solicitud
 	Response    []byte   // Respuesta del worker
+	StatusCode  int      // Código HTTP con el que respondió el worker
 	Status      TaskStatus
-	AssignedTo  *Worker // Worker asignado
+	Header      httpmsg.Header // Encabezados de la respuesta del worker
+	AssignedTo  *Worker        // Worker asignado
 	CreatedAt   time.Time
+	StartedAt   time.Time
 	CompletedAt time.Time
+	Error       string // Motivo de TaskFailed
 	RetryCount  int // Para reintentos
 	Content 	string
+	err         error // Error de la llamada al worker, se lee después de Request.Done
+	queuedAt    time.Time // Cuándo entró a la cola del worker actual
+	admitted    bool      // Cuenta en el límite de cola de su ruta, ver admission.go
 }
 
 type Request struct {
-	Method string
-	Path   string
-	Params map[string]string
-	Done   chan bool
+	Method  string
+	Path    string
+	Params  map[string]string
+	Header  httpmsg.Header // Encabezados que se reenvían al worker, por ejemplo Range
+	Body    []byte
+	Done    chan bool       // El loop del worker avisa aquí al terminar; debe tener buffer
+	Ctx     context.Context // Se cancela con DELETE /jobs/{id}; nil equivale a context.Background()
+	Timeout time.Duration   // Límite de la llamada al worker; 0 usa WorkerRequestTimeout y NoTimeout no limita
+	Retry   bool            // Reintentar en otro worker aunque la ruta no se haya declarado idempotente
+}
+
+func (r *Request) context() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+	return r.Ctx
+}
+
+// done avisa al cliente que espera la tarea sin bloquear al loop del worker
+func (t *Task) done() {
+	if t.Request.Done == nil {
+		return
+	}
+	select {
+	case t.Request.Done <- true:
+	default:
+	}
+}
+
+// wait espera a que un loop de un worker termine la tarea o a que se cancele su
+// contexto. Si el contexto se cancela, la tarea no se debe leer: el loop puede
+// seguir escribiendo en ella.
+func (t *Task) wait() error {
+	ctx := t.Request.context()
+	select {
+	case <-t.Request.Done:
+		return t.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
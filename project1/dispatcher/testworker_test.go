@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"http-shared/httpmsg"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testHandler atiende una solicitud de un worker de prueba con el cuerpo ya leído.
+// Retorna false para cerrar la conexión sin atender más solicitudes.
+type testHandler func(conn net.Conn, req *httpmsg.Request, body []byte) bool
+
+// testWorker es un worker de prueba: acepta conexiones con keep-alive y pasa cada
+// solicitud a su handler, que define el comportamiento de cada prueba
+type testWorker struct {
+	addr     string
+	ln       net.Listener
+	accepted int32 // Conexiones aceptadas, se usa con atomic
+
+	connsMu sync.Mutex
+	conns   []net.Conn
+}
+
+// newTestWorker levanta un worker de prueba en un puerto local que se detiene al terminar la prueba
+func newTestWorker(t *testing.T, handle testHandler) *testWorker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo iniciar el worker de prueba: %v", err)
+	}
+	tw := &testWorker{addr: ln.Addr().String(), ln: ln}
+	t.Cleanup(tw.stop)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&tw.accepted, 1)
+			tw.connsMu.Lock()
+			tw.conns = append(tw.conns, conn)
+			tw.connsMu.Unlock()
+			go serveTest(conn, handle)
+		}
+	}()
+	return tw
+}
+
+func serveTest(conn net.Conn, handle testHandler) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := httpmsg.ReadRequest(reader, httpmsg.DefaultLimits)
+		if err != nil {
+			return
+		}
+		body, _ := req.ReadBody()
+		if !handle(conn, req, body) {
+			return
+		}
+	}
+}
+
+// stop simula la caída del worker: cierra el socket y las conexiones abiertas
+func (tw *testWorker) stop() {
+	tw.ln.Close()
+	tw.connsMu.Lock()
+	defer tw.connsMu.Unlock()
+	for _, conn := range tw.conns {
+		conn.Close()
+	}
+}
+
+// reply responde text con status y deja la conexión abierta
+func reply(conn net.Conn, req *httpmsg.Request, status int, text string) bool {
+	w := httpmsg.NewWriter(conn, req)
+	httpmsg.Text(w, status, text)
+	w.Finish()
+	return true
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"http-shared/httpmsg"
 	"net"
 	"strings"
 	"sync"
@@ -10,37 +11,6 @@ import (
 // mutex para los archivos
 var FilesMutex = &sync.Mutex{}
 
-
-func ParseRequestLine(request string) (method, path string) {
-	lines := strings.Split(request, "\r\n")
-	if len(lines) > 0 {
-		parts := strings.Split(lines[0], " ")
-		if len(parts) >= 2 {
-			return parts[0], parts[1]
-		}
-	}
-	return "", ""
-}
-
-func ParseRoute(path string) (string, map[string]string) {
-	parts := strings.Split(path, "?")
-	route := parts[0]
-	params := make(map[string]string)
-
-	if len(parts) > 1 {
-		pairs := strings.Split(parts[1], "&")
-		for _, pair := range pairs {
-			kv := strings.Split(pair, "=")
-			if len(kv) == 2 {
-				params[kv[0]] = kv[1]
-			}
-		}
-	}
-	fmt.Println("Route:", route)
-	fmt.Println("Params:", params)
-	return route, params
-}
-
 func SendResponse(conn net.Conn, status, body string) {
 	response := fmt.Sprintf("HTTP/1.0 %s\r\nContent-Type: text/plain\r\nContent-Length: %d\r\n\r\n%s", status, len(body), body)
 	print(response)
@@ -53,3 +23,23 @@ func SendJSON(conn net.Conn, status string, body []byte) {
     conn.Write(body)
 }
 
+// Encabezados de la respuesta del worker que se reenvían al cliente
+var forwardedHeaders = []string{"Content-Type", "Content-Range", "Accept-Ranges", "Allow", "Retry-After"}
+
+// SendWorkerResponse reenvía al cliente la respuesta de un worker con su código,
+// su Content-Type y los encabezados de rangos. Sin Content-Type se usa text/plain.
+func SendWorkerResponse(conn net.Conn, status int, header httpmsg.Header, body []byte) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP/1.0 %s\r\n", httpmsg.StatusLine(status))
+	if header.Get("Content-Type") == "" {
+		b.WriteString("Content-Type: text/plain\r\n")
+	}
+	for _, key := range forwardedHeaders {
+		for _, value := range header.Values(key) {
+			fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+		}
+	}
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(body))
+	conn.Write([]byte(b.String()))
+	conn.Write(body)
+}
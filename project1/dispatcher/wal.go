@@ -0,0 +1,580 @@
+// wal.go (en módulo dispatcher)
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Eventos del log. Un trabajo sin completed ni failed se vuelve a encolar al reiniciar,
+// salvo que ya se hubiera asignado a un worker y su ruta no sea idempotente.
+const (
+	WalAccepted  = "accepted"
+	WalAssigned  = "assigned"
+	WalCompleted = "completed"
+	WalFailed    = "failed"
+	WalMetrics   = "metrics" // Solo guarda las métricas
+)
+
+// Política de fsync del log
+const (
+	FsyncAlways   = "always"   // Después de cada evento
+	FsyncInterval = "interval" // Cada WAL_FSYNC_INTERVAL
+	FsyncNever    = "never"    // Lo decide el sistema operativo
+)
+
+const (
+	DefaultWalFsyncInterval   = 1 * time.Second
+	DefaultWalCompactInterval = 1 * time.Minute
+	WalMetricsInterval        = 5 * time.Second // Cada cuánto se guardan las métricas si cambiaron
+)
+
+// walEvent es una línea JSON del log
+type walEvent struct {
+	Type       string            `json:"type"`
+	JobID      int               `json:"job_id,omitempty"`
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method,omitempty"`
+	Route      string            `json:"route,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	Header     httpmsg.Header    `json:"header,omitempty"`
+	Body       []byte            `json:"body,omitempty"`
+	Idempotent bool              `json:"idempotent,omitempty"` // Se puede repetir aunque un worker ya lo haya recibido
+	CreatedAt  time.Time         `json:"created_at,omitempty"`
+	Worker     string            `json:"worker,omitempty"`
+	StatusCode int               `json:"status_code,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Metrics    *metricsSnapshot  `json:"metrics,omitempty"`
+}
+
+// metricsSnapshot son los contadores de DispatcherMetrics que se restauran al reiniciar
+type metricsSnapshot struct {
+	RequestsHandled   int `json:"requests_handled"`
+	RequestsFailed    int `json:"requests_failed"`
+	TotalRequests     int `json:"total_requests"`
+	WorkersRegistered int `json:"workers_registered"`
+}
+
+func (m *DispatcherMetrics) snapshot() metricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return metricsSnapshot{m.RequestsHandled, m.RequestsFailed, m.TotalRequests, m.WorkersRegistered}
+}
+
+func (m *DispatcherMetrics) restore(s metricsSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.RequestsHandled = s.RequestsHandled
+	m.RequestsFailed = s.RequestsFailed
+	m.TotalRequests = s.TotalRequests
+	m.WorkersRegistered = s.WorkersRegistered
+}
+
+// WAL es el log de escritura anticipada de los trabajos asíncronos. Cada evento se
+// agrega al final del archivo; la compactación lo reescribe solo con los trabajos
+// sin terminar y las últimas métricas.
+type WAL struct {
+	path            string
+	fsync           string
+	fsyncInterval   time.Duration
+	compactInterval time.Duration
+
+	mu      sync.Mutex
+	file    *os.File
+	dirty   bool               // Hay escrituras sin fsync
+	pending map[int][]walEvent // Eventos de los trabajos sin terminar
+	metrics *metricsSnapshot   // Últimas métricas escritas
+	records int                // Líneas del archivo
+	closed  bool               // Después de Close no se escribe nada
+}
+
+var errWALClosed = errors.New("el WAL está cerrado")
+
+// OpenWAL abre el log en path, creándolo si no existe
+func OpenWAL(path, fsync string, fsyncInterval, compactInterval time.Duration) (*WAL, error) {
+	switch fsync {
+	case "":
+		fsync = FsyncInterval
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("política de fsync inválida %q, use %s, %s o %s", fsync, FsyncAlways, FsyncInterval, FsyncNever)
+	}
+	if fsyncInterval <= 0 {
+		fsyncInterval = DefaultWalFsyncInterval
+	}
+	if compactInterval <= 0 {
+		compactInterval = DefaultWalCompactInterval
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &WAL{
+		path:            path,
+		fsync:           fsync,
+		fsyncInterval:   fsyncInterval,
+		compactInterval: compactInterval,
+		file:            file,
+		pending:         make(map[int][]walEvent),
+	}, nil
+}
+
+// walFromEnv lee WAL_PATH, WAL_FSYNC, WAL_FSYNC_INTERVAL y WAL_COMPACT_INTERVAL.
+// Sin WAL_PATH los trabajos solo viven en memoria.
+func walFromEnv() *WAL {
+	path := os.Getenv("WAL_PATH")
+	if path == "" {
+		return nil
+	}
+	fsyncInterval, _ := time.ParseDuration(os.Getenv("WAL_FSYNC_INTERVAL"))
+	compactInterval, _ := time.ParseDuration(os.Getenv("WAL_COMPACT_INTERVAL"))
+	wal, err := OpenWAL(path, os.Getenv("WAL_FSYNC"), fsyncInterval, compactInterval)
+	if err != nil {
+		log.Fatalf("No se pudo abrir el WAL %s: %v", path, err)
+	}
+	return wal
+}
+
+// Enabled indica si los trabajos se guardan en disco
+func (w *WAL) Enabled() bool {
+	return w != nil
+}
+
+// walState es lo que queda al leer el log
+type walState struct {
+	pending map[int][]walEvent
+	metrics *metricsSnapshot
+	lastID  int
+	records int
+}
+
+// load lee el log completo. Una última línea incompleta, como la que deja una caída a
+// mitad de escritura, se descarta y se corta del archivo.
+func (w *WAL) load() (walState, error) {
+	state := walState{pending: make(map[int][]walEvent)}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return state, err
+	}
+	reader := bufio.NewReader(w.file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		var ev walEvent
+		if err != nil || json.Unmarshal(line, &ev) != nil {
+			log.Printf("WAL: se descarta un registro incompleto en el byte %d", offset)
+			if err := w.file.Truncate(offset); err != nil {
+				return state, err
+			}
+			break
+		}
+		offset += int64(len(line))
+		state.records++
+		state.apply(ev)
+	}
+	return state, nil
+}
+
+func (s *walState) apply(ev walEvent) {
+	if ev.Metrics != nil {
+		s.metrics = ev.Metrics
+	}
+	if ev.JobID > s.lastID {
+		s.lastID = ev.JobID
+	}
+	switch ev.Type {
+	case WalAccepted:
+		s.pending[ev.JobID] = []walEvent{ev}
+	case WalAssigned:
+		if events, ok := s.pending[ev.JobID]; ok {
+			s.pending[ev.JobID] = append(events, ev)
+		}
+	case WalCompleted, WalFailed:
+		delete(s.pending, ev.JobID)
+	}
+}
+
+// append escribe un evento. Con FsyncAlways retorna después del fsync.
+func (w *WAL) append(ev walEvent) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errWALClosed
+	}
+	if _, err := w.file.Write(line); err != nil {
+		return err
+	}
+	w.records++
+	state := walState{pending: w.pending, metrics: w.metrics}
+	state.apply(ev)
+	w.metrics = state.metrics
+
+	if w.fsync == FsyncAlways {
+		return w.file.Sync()
+	}
+	w.dirty = w.fsync == FsyncInterval
+	return nil
+}
+
+// Sync fuerza a disco lo escrito desde el último fsync
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errWALClosed
+	}
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// Compact reescribe el log con las métricas y los eventos de los trabajos sin terminar.
+// El archivo nuevo se escribe aparte y reemplaza al actual con rename.
+func (w *WAL) Compact() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errWALClosed
+	}
+
+	ids := make([]int, 0, len(w.pending))
+	for id := range w.pending {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	records := 0
+	if w.metrics != nil {
+		err = encoder.Encode(walEvent{Type: WalMetrics, Time: time.Now(), Metrics: w.metrics})
+		records++
+	}
+	for _, id := range ids {
+		for _, ev := range w.pending[id] {
+			if err == nil {
+				err = encoder.Encode(ev)
+				records++
+			}
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), w.path)
+	}
+	if err != nil {
+		// El WAL actual queda intacto y se sigue escribiendo en él
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// Después del rename tmp es el WAL: se sigue escribiendo por ese mismo descriptor, así
+	// no hay que volver a abrir el archivo y el anterior, ya desvinculado, se cierra
+	w.file.Close()
+	w.file = tmp
+	w.records = records
+	w.dirty = false
+	return nil
+}
+
+// needsCompaction indica si el archivo tiene registros que ya no hacen falta
+func (w *WAL) needsCompaction() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	live := 0
+	if w.metrics != nil {
+		live++
+	}
+	for _, events := range w.pending {
+		live += len(events)
+	}
+	return w.records > live
+}
+
+// Close fuerza a disco lo pendiente y cierra el archivo
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.fsync != FsyncNever {
+		w.file.Sync()
+	}
+	return w.file.Close()
+}
+
+// runWAL hace los fsync periódicos, guarda las métricas si cambiaron y compacta el log
+// hasta que se cancele ctx o se apague el dispatcher
+func (d *Dispatcher) runWAL(ctx context.Context) {
+	w := d.WAL
+	fsync := time.NewTicker(w.fsyncInterval)
+	metrics := time.NewTicker(WalMetricsInterval)
+	compact := time.NewTicker(w.compactInterval)
+	defer fsync.Stop()
+	defer metrics.Stop()
+	defer compact.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.DoneChan:
+			return
+		case <-fsync.C:
+			if err := w.Sync(); err != nil {
+				log.Printf("WAL: error en fsync: %v", err)
+			}
+		case <-metrics.C:
+			d.walMetrics()
+		case <-compact.C:
+			if !w.needsCompaction() {
+				continue
+			}
+			if err := w.Compact(); err != nil {
+				log.Printf("WAL: error compactando %s, se sigue escribiendo en el actual: %v", w.path, err)
+			}
+		}
+	}
+}
+
+// walMetrics guarda las métricas si cambiaron desde el último evento
+func (d *Dispatcher) walMetrics() {
+	snapshot := d.Metrics.snapshot()
+	d.WAL.mu.Lock()
+	changed := d.WAL.metrics == nil || *d.WAL.metrics != snapshot
+	d.WAL.mu.Unlock()
+	if changed {
+		d.walAppend(walEvent{Type: WalMetrics})
+	}
+}
+
+// walAppend agrega el evento con las métricas actuales. Un error se registra pero no
+// detiene el trabajo: el dispatcher sigue atendiendo aunque el disco falle.
+func (d *Dispatcher) walAppend(ev walEvent) {
+	if !d.WAL.Enabled() {
+		return
+	}
+	snapshot := d.Metrics.snapshot()
+	ev.Metrics = &snapshot
+	if err := d.WAL.append(ev); err != nil {
+		log.Printf("WAL: no se pudo guardar el evento %s del trabajo %d: %v", ev.Type, ev.JobID, err)
+	}
+}
+
+// walSecretHeaders son credenciales del cliente que no se escriben en el log
+var walSecretHeaders = []string{"X-API-Key", "Authorization", "Proxy-Authorization", "Cookie"}
+
+// walAccepted guarda la solicitud completa, sin credenciales, para poder repetirla
+func (d *Dispatcher) walAccepted(job *Job) {
+	if !d.WAL.Enabled() {
+		return
+	}
+	job.mu.Lock()
+	t := job.task
+	job.mu.Unlock()
+
+	header := httpmsg.Header{}
+	for key, values := range t.Request.Header {
+		header[key] = values
+	}
+	for _, key := range walSecretHeaders {
+		header.Del(key)
+	}
+	d.walAppend(walEvent{
+		Type:       WalAccepted,
+		JobID:      t.ID,
+		Method:     t.Request.Method,
+		Route:      t.Request.Path,
+		Params:     t.Request.Params,
+		Header:     header,
+		Body:       t.Request.Body,
+		Idempotent: d.coordinated(t.Request.Method, t.Request.Path) || d.retryable(t.Request.Path),
+		CreatedAt:  t.CreatedAt,
+	})
+}
+
+// walAssigned registra el worker elegido si ctx pertenece a un trabajo
+func (d *Dispatcher) walAssigned(ctx context.Context, worker *Worker) {
+	if id := jobIDFrom(ctx); id != 0 {
+		d.walAppend(walEvent{Type: WalAssigned, JobID: id, Worker: worker.URL})
+	}
+}
+
+// walFinished registra el estado final del trabajo
+func (d *Dispatcher) walFinished(job *Job) {
+	if !d.WAL.Enabled() {
+		return
+	}
+	job.mu.Lock()
+	t := job.task
+	job.mu.Unlock()
+	ev := walEvent{Type: WalCompleted, JobID: t.ID, StatusCode: t.StatusCode}
+	if t.Status == TaskFailed {
+		ev.Type = WalFailed
+		ev.Error = t.Error
+	}
+	d.walAppend(ev)
+}
+
+// Recover lee el log, restaura las métricas y vuelve a encolar los trabajos sin
+// terminar con su ID original. Los que ya se habían asignado a un worker en una ruta no
+// idempotente quedan fallidos: el worker pudo haberlos aplicado antes de la caída.
+// Retorna cuántos trabajos se reanudaron.
+func (d *Dispatcher) Recover() (int, error) {
+	if !d.WAL.Enabled() {
+		return 0, nil
+	}
+	state, err := d.WAL.load()
+	if err != nil {
+		return 0, err
+	}
+	d.WAL.mu.Lock()
+	d.WAL.pending = state.pending
+	d.WAL.metrics = state.metrics
+	d.WAL.records = state.records
+	d.WAL.mu.Unlock()
+
+	if state.metrics != nil {
+		d.Metrics.restore(*state.metrics)
+	}
+	if int64(state.lastID) > d.jobSeq {
+		d.jobSeq = int64(state.lastID)
+	}
+
+	ids := make([]int, 0, len(state.pending))
+	for id := range state.pending {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	resumed := 0
+	for _, id := range ids {
+		events := state.pending[id]
+		if interrupted(events) {
+			d.failInterrupted(events[0])
+			continue
+		}
+		d.resumeJob(events[0])
+		resumed++
+	}
+	if len(ids) > 0 {
+		log.Printf("WAL: %d trabajos sin terminar se vuelven a encolar, %d quedan fallidos", resumed, len(ids)-resumed)
+	}
+	return resumed, nil
+}
+
+// interrupted indica si el trabajo llegó a un worker y no se puede repetir sin riesgo
+func interrupted(events []walEvent) bool {
+	if events[0].Idempotent {
+		return false
+	}
+	for _, ev := range events {
+		if ev.Type == WalAssigned {
+			return true
+		}
+	}
+	return false
+}
+
+// failInterrupted deja fallido un trabajo que no se puede repetir, para que el cliente
+// lo vea en /jobs/{id} en lugar de que se aplique dos veces
+func (d *Dispatcher) failInterrupted(ev walEvent) {
+	job := d.newJob(ev.JobID, ev.CreatedAt, ev.Method, ev.Route, httpmsg.FromMap(ev.Params), ev.Header, ev.Body)
+	if job.abort("Interrumpido por reinicio del dispatcher después de enviarse al worker") {
+		d.walFinished(job)
+	}
+	d.expireJob(job)
+}
+
+// resumeJob vuelve a crear un trabajo aceptado antes del reinicio. Conserva el plazo
+// original y espera a que se registre un worker que atienda la ruta.
+func (d *Dispatcher) resumeJob(ev walEvent) {
+	query := httpmsg.FromMap(ev.Params)
+	header := ev.Header
+	if header == nil {
+		header = httpmsg.Header{}
+	}
+	job := d.newJob(ev.JobID, ev.CreatedAt, ev.Method, ev.Route, query, header, ev.Body)
+	route := ev.Route
+	if d.coordinated(ev.Method, route) { // Los workers lo atienden por chunks en /map
+		route = routes.MapRoute
+	}
+	atomic.AddInt64(&d.asyncJobs, 1)
+	go func() {
+		defer atomic.AddInt64(&d.asyncJobs, -1)
+		if err := d.waitForWorkers(job.task.Request.context(), route); err != nil {
+			if d.stoppedByShutdown(job) {
+				return
+			}
+			if job.abort("No hubo workers disponibles antes del plazo del trabajo") {
+				d.walFinished(job)
+			}
+			d.expireJob(job)
+			return
+		}
+		d.runJob(job, query)
+	}()
+}
+
+// waitForWorkers espera hasta que algún worker pueda atender la ruta
+func (d *Dispatcher) waitForWorkers(ctx context.Context, route string) error {
+	ticker := time.NewTicker(HealthCheckInterval)
+	defer ticker.Stop()
+	for len(d.candidatos(route, WorkerSuspect)) == 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// jobKey guarda en el contexto el ID del trabajo para registrar su asignación
+type jobKey struct{}
+
+func withJobID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, jobKey{}, id)
+}
+
+func jobIDFrom(ctx context.Context) int {
+	id, _ := ctx.Value(jobKey{}).(int)
+	return id
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"http-shared/httpmsg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func abrirWAL(t *testing.T, path string) *WAL {
+	wal, err := OpenWAL(path, FsyncAlways, 0, 0)
+	if err != nil {
+		t.Fatalf("No se pudo abrir el WAL: %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	return wal
+}
+
+// Prueba que un dispatcher nuevo reanude con el mismo ID los trabajos que el anterior
+// dejó sin terminar y recupere las métricas
+func TestWALRecuperaTrabajos(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dispatcher.wal")
+	d, sw := dispatcherConTrabajos(t)
+	d.WAL = abrirWAL(t, path)
+
+	terminado := leerTrabajo(t, enviar(t, d, "GET", "/simulate?seconds=1&async=true", nil))
+	assert.Equal(t, "completed", esperarTrabajo(t, d, terminado.ID).Status)
+	<-sw.targets
+	pendiente := leerTrabajo(t, enviar(t, d, "GET", "/bloquear?async=true", nil))
+	assert.Equal(t, "/bloquear", <-sw.targets)
+	metrics := d.Metrics.snapshot()
+
+	// Caída: el log queda como está y el trabajo en curso se abandona
+	d.WAL.Close()
+	value, _ := d.Tasks.Load(pendiente.ID)
+	value.(*Job).Cancel()
+
+	d2, sw2 := dispatcherConTrabajos(t)
+	d2.WAL = abrirWAL(t, path)
+	resumed, err := d2.Recover()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resumed)
+	assert.Equal(t, metrics, d2.Metrics.snapshot())
+
+	// El trabajo pendiente se vuelve a enviar y el completado no
+	_, ok := d2.Tasks.Load(terminado.ID)
+	assert.False(t, ok)
+	select {
+	case target := <-sw2.targets:
+		assert.Equal(t, "/bloquear", target)
+	case <-time.After(2 * time.Second):
+		t.Fatal("El trabajo pendiente no se reanudó")
+	}
+	view := leerTrabajo(t, enviar(t, d2, "GET", fmt.Sprintf("/jobs/%d", pendiente.ID), nil))
+	assert.Equal(t, "processing", view.Status)
+	assert.Equal(t, pendiente.CreatedAt.Unix(), view.CreatedAt.Unix())
+
+	// Los IDs nuevos siguen después de los del log
+	nuevo := leerTrabajo(t, enviar(t, d2, "GET", "/simulate?seconds=1&async=true", nil))
+	assert.Equal(t, pendiente.ID+1, nuevo.ID)
+	assert.Equal(t, 200, enviar(t, d2, "DELETE", fmt.Sprintf("/jobs/%d", pendiente.ID), nil).StatusCode)
+}
+
+// Prueba que al reiniciar un trabajo no idempotente que ya llegó al worker quede fallido
+// en lugar de repetirse, y que el log no guarde las credenciales del cliente
+func TestWALNoRepiteTrabajoInterrumpido(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dispatcher.wal")
+	d, _ := dispatcherConTrabajos(t)
+	d.WAL = abrirWAL(t, path)
+
+	header := httpmsg.Header{}
+	header.Set("X-API-Key", "secreta")
+	header.Set("X-Priority", "high")
+	job := d.startJob("POST", "/agregar", httpmsg.Values{}, header, []byte("linea"))
+	esperarTrabajo(t, d, job.task.ID)
+	d.WAL.Close()
+
+	data, _ := os.ReadFile(path)
+	assert.NotContains(t, string(data), "secreta")
+	assert.Contains(t, string(data), "x-priority")
+
+	// Caída con el trabajo ya asignado: se reescribe el log sin el evento final
+	wal := abrirWAL(t, filepath.Join(t.TempDir(), "caida.wal"))
+	for _, ev := range []walEvent{
+		{Type: WalAccepted, JobID: 1, Method: "POST", Route: "/agregar", Body: []byte("linea"), CreatedAt: time.Now()},
+		{Type: WalAssigned, JobID: 1, Worker: "worker1:8080"},
+		{Type: WalAccepted, JobID: 2, Method: "POST", Route: "/agregar", Body: []byte("otra"), CreatedAt: time.Now()},
+	} {
+		assert.NoError(t, wal.append(ev))
+	}
+
+	d2, sw2 := dispatcherConTrabajos(t)
+	d2.WAL = wal
+	resumed, err := d2.Recover()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resumed, "solo el que no llegó a un worker")
+
+	view := leerTrabajo(t, enviar(t, d2, "GET", "/jobs/1", nil))
+	assert.Equal(t, "failed", view.Status)
+	assert.Contains(t, view.Error, "Interrumpido por reinicio")
+	select {
+	case target := <-sw2.targets:
+		assert.Equal(t, "/agregar", target)
+	case <-time.After(2 * time.Second):
+		t.Fatal("El trabajo sin asignar no se reanudó")
+	}
+	assert.Equal(t, "completed", esperarTrabajo(t, d2, 2).Status)
+	state, err := abrirWAL(t, wal.path).load()
+	assert.NoError(t, err)
+	assert.Empty(t, state.pending)
+}
+
+// Prueba que la compactación deje solo los trabajos sin terminar y las métricas
+func TestWALCompactacion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dispatcher.wal")
+	wal := abrirWAL(t, path)
+	metrics := &metricsSnapshot{TotalRequests: 3}
+
+	for _, ev := range []walEvent{
+		{Type: WalAccepted, JobID: 1, Method: "GET", Route: "/simulate"},
+		{Type: WalAccepted, JobID: 2, Method: "POST", Route: "/createfile", Body: []byte("hola")},
+		{Type: WalAssigned, JobID: 1, Worker: "worker1:8080"},
+		{Type: WalCompleted, JobID: 1, StatusCode: 200},
+		{Type: WalAssigned, JobID: 2, Worker: "worker2:8080", Metrics: metrics},
+	} {
+		assert.NoError(t, wal.append(ev))
+	}
+	assert.True(t, wal.needsCompaction())
+	assert.NoError(t, wal.Compact())
+	assert.False(t, wal.needsCompaction())
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, 3, bytes.Count(data, []byte("\n")), "métricas, accepted y assigned del trabajo 2")
+
+	// Se sigue escribiendo en el archivo compactado
+	assert.NoError(t, wal.append(walEvent{Type: WalAccepted, JobID: 3, Route: "/simulate"}))
+	state, err := abrirWAL(t, path).load()
+	assert.NoError(t, err)
+	assert.Len(t, state.pending, 2)
+	assert.Equal(t, "hola", string(state.pending[2][0].Body))
+	assert.Len(t, state.pending[2], 2)
+	assert.Equal(t, 3, state.lastID)
+	assert.Equal(t, metrics, state.metrics)
+}
+
+// Prueba que si la compactación falla se siga escribiendo en el WAL actual sin perder eventos
+func TestWALCompactacionFallida(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dispatcher.wal")
+	wal := abrirWAL(t, path)
+	assert.NoError(t, wal.append(walEvent{Type: WalAccepted, JobID: 1, Route: "/simulate"}))
+
+	// El rename falla porque en la ruta del WAL ahora hay un directorio con contenido
+	moved := filepath.Join(dir, "movido.wal")
+	assert.NoError(t, os.Rename(path, moved))
+	assert.NoError(t, os.MkdirAll(filepath.Join(path, "ocupado"), 0755))
+	assert.Error(t, wal.Compact())
+
+	assert.NoError(t, wal.append(walEvent{Type: WalCompleted, JobID: 1}))
+	assert.NoError(t, wal.append(walEvent{Type: WalAccepted, JobID: 2, Route: "/simulate"}))
+	state, err := abrirWAL(t, moved).load()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, state.records, "los eventos siguen llegando al archivo abierto")
+	assert.Len(t, state.pending, 1)
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 2, "no quedan archivos temporales")
+}
+
+// Prueba que un registro cortado a mitad de escritura se descarte sin perder los anteriores
+func TestWALRegistroIncompleto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dispatcher.wal")
+	wal := abrirWAL(t, path)
+	assert.NoError(t, wal.append(walEvent{Type: WalAccepted, JobID: 1, Route: "/simulate"}))
+	wal.Close()
+	valid, _ := os.ReadFile(path)
+	os.WriteFile(path, append(valid, []byte(`{"type":"completed","job_i`)...), 0644)
+
+	wal = abrirWAL(t, path)
+	state, err := wal.load()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, state.records)
+	assert.Len(t, state.pending, 1)
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, valid, data, "el registro incompleto se corta del archivo")
+
+	assert.NoError(t, wal.append(walEvent{Type: WalCompleted, JobID: 1}))
+	state, err = abrirWAL(t, path).load()
+	assert.NoError(t, err)
+	assert.Empty(t, state.pending)
+}
+
+func TestWALFsyncInvalido(t *testing.T) {
+	_, err := OpenWAL(filepath.Join(t.TempDir(), "dispatcher.wal"), "a veces", 0, 0)
+	assert.Error(t, err)
+}
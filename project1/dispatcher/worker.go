@@ -3,25 +3,41 @@ package main
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 	"encoding/json"
 	"log"
 	"net"
 	"http-servidor/utils"
+	"http-shared/routes"
 
 )
 
 type Worker struct {
-	ID            int
-	URL           string // Ej: "http://worker1:8080"
-	Status        bool
-	mu            sync.RWMutex // Usamos RWMutex para permitir lecturas concurrentes
-	lastChecked   time.Time
-	activeTasks   int
-	maxCapacity   int        // Máximo de tareas concurrentes
-	taskQueue     chan *Task // Canal interno para manejar carga
-	healthChecker *time.Ticker
-	CompletedTasks      int // Contador de tareas cargadas
+	ID                int
+	URL               string // Ej: "http://worker1:8080"
+	Status            bool
+	mu                sync.RWMutex // Usamos RWMutex para permitir lecturas concurrentes
+	lastChecked       time.Time
+	activeTasks       int
+	maxCapacity       int        // Máximo de tareas concurrentes
+	taskQueue         chan *Task // Tareas que esperan uno de los maxCapacity loops del worker
+	healthChecker     *time.Ticker
+	CompletedTasks    int // Contador de tareas cargadas
+	Name              string
+	Version           string
+	Labels            map[string]string
+	routes            *routes.Table    // Rutas que declaró el worker, nil si aún no se conocen
+	Draining          bool             // Avisó que se está apagando: no recibe tareas nuevas
+	State             WorkerState      // Vivo, sospechoso o muerto según sus heartbeats
+	Load              routes.Heartbeat // Último heartbeat recibido
+	lastHeartbeat     time.Time
+	heartbeatInterval time.Duration
+	legacy            bool // Se registró sin capacidades: no envía heartbeats y se revisa con /ping
+	rejectedTasks     int64 // Tareas rechazadas con la cola llena, se usa con atomic
+	waitTotal         time.Duration // Tiempo total que esperaron en cola las tareas atendidas
+	waitCount         int
+	maxWait           time.Duration
 }
 
 func NewWorker(id int, url string, capacity int) *Worker {
@@ -31,12 +47,145 @@ func NewWorker(id int, url string, capacity int) *Worker {
 		Status:      true,
 		maxCapacity: capacity,
 		taskQueue:   make(chan *Task, capacity),
+		State:       WorkerAlive,
+		// El primer heartbeat se espera a partir del registro
+		lastHeartbeat:     time.Now(),
+		heartbeatInterval: routes.DefaultHeartbeatInterval,
 	}
 
 	//w.startHealthCheck()
 	return w
 }
 
+// newWorkerFromCapabilities crea el worker con las capacidades que envió al registrarse
+func newWorkerFromCapabilities(id int, caps routes.Capabilities) *Worker {
+	w := NewWorker(id, caps.URL, caps.MaxConcurrency)
+	w.lastChecked = time.Now()
+	w.applyCapabilities(caps)
+	return w
+}
+
+// applyCapabilities actualiza los datos del worker, por ejemplo si se vuelve a registrar tras reiniciar.
+// Si cambió la concurrencia se crea una cola nueva y retorna true: los loops de la cola
+// anterior la terminan y salen, y hay que llamar a start para atender la nueva.
+func (w *Worker) applyCapabilities(caps routes.Capabilities) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.Name = caps.Name
+	w.Version = caps.Version
+	w.Labels = caps.Labels
+	w.routes = routes.NewTable(caps.Routes)
+	if caps.MaxConcurrency == w.maxCapacity {
+		return false
+	}
+	w.maxCapacity = caps.MaxConcurrency
+	if w.taskQueue != nil {
+		close(w.taskQueue)
+	}
+	w.taskQueue = make(chan *Task, caps.MaxConcurrency)
+	return true
+}
+
+// supports indica si el worker declaró la ruta. Los workers que se registraron
+// sin capacidades y aún no publican /routes se asume que atienden todo.
+func (w *Worker) supports(route string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.routes == nil {
+		return true
+	}
+	_, ok := w.routes.Lookup(route)
+	return ok
+}
+
+// availableIn indica si el worker puede recibir la ruta estando a lo sumo en maxState
+func (w *Worker) availableIn(route string, maxState WorkerState) bool {
+	w.mu.RLock()
+	ready := w.Status && !w.Draining && w.State <= maxState
+	w.mu.RUnlock()
+	return ready && w.supports(route)
+}
+
+// enqueue agrega la tarea a la cola del worker sin bloquear. Retorna false si la
+// cola está llena o si el worker ya se eliminó.
+func (w *Worker) enqueue(task *Task) bool {
+	// Se envía con el lock tomado para no enviar a una cola que stop o
+	// applyCapabilities estén cerrando
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	task.queuedAt = time.Now()
+	select {
+	case w.taskQueue <- task:
+		return true
+	default:
+		atomic.AddInt64(&w.rejectedTasks, 1)
+		return false
+	}
+}
+
+// start lanza maxCapacity loops que atienden la cola del worker. Así el worker nunca
+// tiene más de maxCapacity solicitudes en curso y el resto espera en taskQueue.
+func (w *Worker) start(d *Dispatcher) {
+	w.mu.RLock()
+	queue, n := w.taskQueue, w.maxCapacity
+	w.mu.RUnlock()
+	for i := 0; i < n; i++ {
+		go w.run(d, queue)
+	}
+}
+
+// run atiende las tareas de queue hasta que se cierre
+func (w *Worker) run(d *Dispatcher, queue chan *Task) {
+	for task := range queue {
+		d.process(w, task)
+	}
+}
+
+// stop cierra la cola del worker: sus loops terminan lo que quede en ella y salen
+func (w *Worker) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.taskQueue != nil {
+		close(w.taskQueue)
+		w.taskQueue = nil
+	}
+}
+
+// finishTask libera el lugar de una tarea terminada
+func (w *Worker) finishTask() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.activeTasks > 0 {
+		w.activeTasks--
+	}
+}
+
+// recordWait suma el tiempo que una tarea esperó en la cola antes de atenderse
+func (w *Worker) recordWait(wait time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.waitTotal += wait
+	w.waitCount++
+	if wait > w.maxWait {
+		w.maxWait = wait
+	}
+}
+
+// avgWait retorna la espera promedio en cola; se llama con el lock tomado
+func (w *Worker) avgWait() time.Duration {
+	if w.waitCount == 0 {
+		return 0
+	}
+	return w.waitTotal / time.Duration(w.waitCount)
+}
+
+// queued retorna cuántas tareas esperan un loop libre
+func (w *Worker) queued() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.taskQueue)
+}
+
 
 //devuelve el estado del worker
 func workerStatus(conn net.Conn, d *Dispatcher) {
@@ -47,14 +196,16 @@ func workerStatus(conn net.Conn, d *Dispatcher) {
 
 	workerActivo := 0
 
-	// Armamos una estructura por worker
+	// Armamos una estructura por worker; la lista y los contadores se copian con los locks
+	// tomados y la respuesta se escribe después de soltarlos
 	workersStatus := make([]map[string]interface{}, 0)
+	d.Mu.RLock()
 	for _, worker := range d.Workers {
+		worker.mu.RLock()
 		if worker.Status {
 			workerActivo ++
 			log.Printf("Worker %d (%s) activo", worker.ID, worker.URL)
 		} 
-		worker.mu.RLock()
 		status := map[string]interface{}{
 			"pid":            worker.ID,
 			"url":           worker.URL,
@@ -62,10 +213,24 @@ func workerStatus(conn net.Conn, d *Dispatcher) {
 			"CompletedTasks":      worker.CompletedTasks,
 			"last_checked":  worker.lastChecked.Format(time.RFC3339),
 			"max_capacity":  worker.maxCapacity,
+			"name":          worker.Name,
+			"version":       worker.Version,
+			"labels":        worker.Labels,
+			"routes":        worker.routes.Len(),
+			"draining":      worker.Draining,
+			"state":         worker.State.String(),
+			"last_heartbeat": worker.lastHeartbeat.Format(time.RFC3339),
+			"load":          worker.Load,
+			"active_tasks":  worker.activeTasks,
+			"queued_tasks":  len(worker.taskQueue),
+			"rejected_tasks": atomic.LoadInt64(&worker.rejectedTasks),
+			"queue_wait_avg_ms": worker.avgWait().Milliseconds(),
+			"queue_wait_max_ms": worker.maxWait.Milliseconds(),
 		}
 		worker.mu.RUnlock()
 		workersStatus = append(workersStatus, status)
 	}
+	d.Mu.RUnlock()
 
 	response := map[string]interface{}{
 		"main_pid":        d.ID, // PID del proceso principal
@@ -73,6 +238,7 @@ func workerStatus(conn net.Conn, d *Dispatcher) {
 		"total_requests":  totalRequests,
 		"workers_status":  workersStatus,
 		"total_workers":   workerActivo,
+		"admission":       d.Admission.Snapshot(),
 	}
 	jsonData, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
@@ -80,4 +246,9 @@ func workerStatus(conn net.Conn, d *Dispatcher) {
 			return
 		}
 	utils.SendJSON(conn, "200 OK", jsonData)
-}
\ No newline at end of file
+}
+// releaseWorker libera el lugar de una tarea terminada y elimina al worker si estaba drenándose
+func (d *Dispatcher) releaseWorker(w *Worker) {
+	w.finishTask()
+	d.removeIfDrained(w)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gateWorker es un worker de prueba que retiene las solicitudes hasta que se cierre
+// release, cuenta cuántas tuvo en curso a la vez y guarda la última que recibió
+type gateWorker struct {
+	*testWorker
+	release chan struct{}
+
+	mu      sync.Mutex
+	current int
+	max     int
+	last    *httpmsg.Request
+}
+
+func newGateWorker(t *testing.T) *gateWorker {
+	gw := &gateWorker{release: make(chan struct{})}
+	gw.testWorker = newTestWorker(t, gw.handle)
+	t.Cleanup(gw.open)
+	return gw
+}
+
+func (gw *gateWorker) open() {
+	select {
+	case <-gw.release:
+	default:
+		close(gw.release)
+	}
+}
+
+func (gw *gateWorker) inFlight() int {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.current
+}
+
+func (gw *gateWorker) lastRequest() *httpmsg.Request {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.last
+}
+
+func (gw *gateWorker) handle(conn net.Conn, req *httpmsg.Request, body []byte) bool {
+	gw.mu.Lock()
+	gw.last = req
+	gw.current++
+	if gw.current > gw.max {
+		gw.max = gw.current
+	}
+	gw.mu.Unlock()
+
+	<-gw.release
+
+	gw.mu.Lock()
+	gw.current--
+	gw.mu.Unlock()
+	return reply(conn, req, 200, "listo")
+}
+
+// esperarHasta reintenta cond hasta que se cumpla
+func esperarHasta(t *testing.T, msg string, cond func() bool) {
+	for i := 0; i < 200; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(msg)
+}
+
+// Prueba que el worker no tenga más de maxCapacity solicitudes en curso, que el resto
+// espere en su cola y que con la cola llena se responda 503
+func TestLimiteDeConcurrencia(t *testing.T) {
+	d := newDispatcher()
+	gw := newGateWorker(t)
+	registrar(t, d, routes.Capabilities{URL: gw.addr, MaxConcurrency: 2,
+		Routes: []routes.Route{{Path: "/lento", Methods: []string{"GET"}}}})
+	worker := d.Workers[0]
+
+	codes := make(chan int, 4)
+	for i := 0; i < 4; i++ {
+		go func() { codes <- enviar(t, d, "GET", "/lento", nil).StatusCode }()
+	}
+	esperarHasta(t, "El worker no llegó a 2 solicitudes en curso con 2 en cola", func() bool {
+		return gw.inFlight() == 2 && worker.queued() == 2
+	})
+	resp := enviar(t, d, "GET", "/lento", nil)
+	assert.Equal(t, 503, resp.StatusCode, "cola llena")
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+	var status struct {
+		Workers []map[string]interface{} `json:"workers_status"`
+	}
+	assert.NoError(t, json.Unmarshal(enviar(t, d, "GET", "/workers", nil).Body, &status))
+	assert.EqualValues(t, 2, status.Workers[0]["active_tasks"])
+	assert.EqualValues(t, 2, status.Workers[0]["queued_tasks"])
+	assert.EqualValues(t, 1, status.Workers[0]["rejected_tasks"])
+
+	gw.open()
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, 200, <-codes)
+	}
+	assert.Equal(t, 2, gw.max)
+	esperarHasta(t, "El worker no liberó sus tareas", func() bool { return activeOf(worker) == 0 })
+}
+
+// Prueba que /workers se pueda consultar mientras se registran y se eliminan workers
+// (con -race detecta lecturas sin lock)
+func TestWorkersConcurrente(t *testing.T) {
+	d := newDispatcher()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			url := fmt.Sprintf("worker%d:8080", i)
+			registrar(t, d, routes.Capabilities{URL: url, MaxConcurrency: 1,
+				Routes: []routes.Route{{Path: "/ping", Methods: []string{"GET"}}}})
+			enviar(t, d, "POST", "/desuscribir?url="+url, nil)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, 200, enviar(t, d, "GET", "/workers", nil).StatusCode)
+	}
+	wg.Wait()
+}
+
+// Prueba que las tareas en la cola de un worker muerto pasen a otro worker
+func TestRedistribuirCola(t *testing.T) {
+	d := newDispatcher()
+	gw := newGateWorker(t)
+	fw := newFixedWorker(t, 200)
+	get := []string{"GET"}
+	registrar(t, d, routes.Capabilities{URL: gw.addr, MaxConcurrency: 1, Routes: []routes.Route{{Path: "/lento", Methods: get}}})
+	registrar(t, d, routes.Capabilities{URL: fw.addr, MaxConcurrency: 1, Routes: []routes.Route{{Path: "/lento", Methods: get}}})
+	lento := d.Workers[0]
+
+	// La primera tarea ocupa el único loop del worker y la segunda queda en su cola
+	results := make(chan *httpmsg.Response, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			resp, err := d.call(lento, &Request{Method: "GET", Path: "/lento", Timeout: NoTimeout})
+			assert.NoError(t, err)
+			results <- resp
+		}()
+	}
+	esperarHasta(t, "La segunda tarea no quedó en cola", func() bool { return lento.queued() == 1 })
+
+	lento.mu.Lock()
+	lento.setState(WorkerDead)
+	lento.mu.Unlock()
+	d.redistributeTasks(lento)
+
+	resp := <-results
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(resp.Body), fw.addr, "la tarea en cola la atendió el otro worker")
+	gw.open()
+	assert.Equal(t, "listo", string((<-results).Body))
+}
@@ -0,0 +1,307 @@
+// Package filestore guarda los archivos del worker dentro de una carpeta raíz.
+// Valida los nombres para que no se salgan de la raíz, usa un candado de
+// lectura/escritura por archivo, escribe con archivo temporal y rename para que
+// nunca se lea un archivo a medias, y aplica una cuota de bytes y de archivos.
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultRoot es la carpeta que se usa si FILES_ROOT no está definida
+const DefaultRoot = "files"
+
+// Prefijo de los archivos temporales; no se listan ni se aceptan como nombre
+const tmpPrefix = ".tmp-"
+
+const maxNameLength = 255
+
+var (
+	ErrInvalidName   = &httpmsg.Error{Status: 400, Msg: "Nombre de archivo inválido"}
+	ErrQuotaExceeded = &httpmsg.Error{Status: 507, Msg: "Cuota de almacenamiento excedida"}
+)
+
+// Quota limita el espacio total de la raíz. Un valor de cero no limita.
+type Quota struct {
+	MaxBytes int64 `json:"max_bytes"`
+	MaxFiles int   `json:"max_files"`
+}
+
+func (q Quota) enabled() bool {
+	return q.MaxBytes > 0 || q.MaxFiles > 0
+}
+
+// Usage es el espacio ocupado por los archivos de la raíz
+type Usage struct {
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
+	Quota Quota `json:"quota"`
+}
+
+// Store es la carpeta de archivos del worker
+type Store struct {
+	root  string
+	quota Quota
+
+	mu    sync.Mutex // Protege locks
+	locks map[string]*fileLock
+
+	// Con cuota, las escrituras se serializan entre revisar el espacio y el
+	// rename para que dos escrituras no pasen la cuota a la vez
+	quotaMu sync.Mutex
+}
+
+type fileLock struct {
+	sync.RWMutex
+	refs int
+}
+
+// New crea un Store sobre root. La carpeta se crea con la primera escritura.
+func New(root string, quota Quota) *Store {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &Store{root: root, quota: quota, locks: make(map[string]*fileLock)}
+}
+
+// Root retorna la ruta absoluta de la carpeta raíz
+func (s *Store) Root() string {
+	return s.root
+}
+
+// ValidName indica si name se puede usar como archivo: un solo componente,
+// sin separadores, sin "." ni ".." y sin bytes nulos.
+func ValidName(name string) bool {
+	if name == "" || name == "." || name == ".." || len(name) > maxNameLength {
+		return false
+	}
+	if strings.HasPrefix(name, tmpPrefix) {
+		return false
+	}
+	return !strings.ContainsAny(name, "/\\\x00")
+}
+
+// resolve arma la ruta de name dentro de la raíz. Si ya existe como enlace
+// simbólico, el destino también debe quedar dentro de la raíz.
+func (s *Store) resolve(name string) (string, error) {
+	if !ValidName(name) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	path := filepath.Join(s.root, name)
+
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return path, nil
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: enlace roto %q", ErrInvalidName, name)
+	}
+	root, err := filepath.EvalSymlinks(s.root)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q apunta fuera de la carpeta", ErrInvalidName, name)
+	}
+	return path, nil
+}
+
+// lock toma el candado de name y retorna la función que lo libera
+func (s *Store) lock(name string, write bool) func() {
+	s.mu.Lock()
+	l, ok := s.locks[name]
+	if !ok {
+		l = &fileLock{}
+		s.locks[name] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	if write {
+		l.Lock()
+	} else {
+		l.RLock()
+	}
+	return func() {
+		if write {
+			l.Unlock()
+		} else {
+			l.RUnlock()
+		}
+		s.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, name)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// ReadFile retorna el contenido de name y sus datos
+func (s *Store) ReadFile(name string) ([]byte, fs.FileInfo, error) {
+	path, err := s.resolve(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock := s.lock(name, false)
+	defer unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, fmt.Errorf("%q no es un archivo regular", name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, info, nil
+}
+
+// WriteFile reemplaza el contenido de name
+func (s *Store) WriteFile(name string, data []byte) error {
+	path, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+	unlock := s.lock(name, true)
+	defer unlock()
+	return s.replace(name, path, data)
+}
+
+// AppendFile agrega data al final de name, creándolo si no existe. Se reescribe
+// el archivo completo para que el cambio también sea atómico.
+func (s *Store) AppendFile(name string, data []byte) error {
+	path, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+	unlock := s.lock(name, true)
+	defer unlock()
+
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return s.replace(name, path, append(current, data...))
+}
+
+// Remove borra name
+func (s *Store) Remove(name string) error {
+	path, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+	unlock := s.lock(name, true)
+	defer unlock()
+	return os.Remove(path)
+}
+
+// replace escribe data en un temporal de la raíz y lo renombra sobre path.
+// Se llama con el candado de escritura de name tomado.
+func (s *Store) replace(name, path string, data []byte) error {
+	if err := os.MkdirAll(s.root, 0755); err != nil {
+		return err
+	}
+	if s.quota.enabled() {
+		s.quotaMu.Lock()
+		defer s.quotaMu.Unlock()
+		if err := s.checkQuota(name, int64(len(data))); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(s.root, tmpPrefix+"*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// checkQuota revisa que reemplazar name por size bytes no pase la cuota
+func (s *Store) checkQuota(name string, size int64) error {
+	usage, err := s.usage(name)
+	if err != nil {
+		return err
+	}
+	if s.quota.MaxBytes > 0 && usage.Bytes+size > s.quota.MaxBytes {
+		return fmt.Errorf("%w: %d de %d bytes", ErrQuotaExceeded, usage.Bytes+size, s.quota.MaxBytes)
+	}
+	if s.quota.MaxFiles > 0 && usage.Files+1 > s.quota.MaxFiles {
+		return fmt.Errorf("%w: %d de %d archivos", ErrQuotaExceeded, usage.Files+1, s.quota.MaxFiles)
+	}
+	return nil
+}
+
+// Usage retorna el espacio ocupado y la cuota configurada
+func (s *Store) Usage() (Usage, error) {
+	return s.usage("")
+}
+
+// usage suma los archivos de la raíz sin contar a except
+func (s *Store) usage(except string) (Usage, error) {
+	usage := Usage{Quota: s.quota}
+	list, err := s.List()
+	if err != nil {
+		return usage, err
+	}
+	for _, info := range list {
+		if info.Name == except {
+			continue
+		}
+		usage.Bytes += info.Size
+		usage.Files++
+	}
+	return usage, nil
+}
+
+// List retorna los archivos regulares de la raíz ordenados por nombre.
+// Sin carpeta raíz retorna una lista vacía.
+func (s *Store) List() ([]routes.FileInfo, error) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// ReadDir ya retorna las entradas ordenadas por nombre
+	list := make([]routes.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !ValidName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Se borró mientras se listaba
+		}
+		list = append(list, routes.FileInfo{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime().UTC()})
+	}
+	return list, nil
+}
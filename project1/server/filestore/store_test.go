@@ -0,0 +1,144 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidName(t *testing.T) {
+	valid := []string{"a.txt", "archivo con espacios", "..oculto", "reporte.2024.csv"}
+	invalid := []string{"", ".", "..", "../a.txt", "a/b", `a\b`, "/etc/passwd", "a\x00b", ".tmp-123", strings.Repeat("x", 256)}
+
+	for _, name := range valid {
+		if !ValidName(name) {
+			t.Errorf("%q debería ser válido", name)
+		}
+	}
+	for _, name := range invalid {
+		if ValidName(name) {
+			t.Errorf("%q debería ser inválido", name)
+		}
+	}
+}
+
+// TestEscritura prueba escribir, agregar, leer y borrar sin dejar temporales
+func TestEscritura(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "files"), Quota{})
+
+	if err := s.WriteFile("a.txt", []byte("hola")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := s.AppendFile("a.txt", []byte(" mundo")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	data, info, err := s.ReadFile("a.txt")
+	if err != nil || string(data) != "hola mundo" || info.Size() != 10 {
+		t.Fatalf("Lectura inesperada: '%s' %v", data, err)
+	}
+
+	entries, _ := os.ReadDir(s.Root())
+	if len(entries) != 1 {
+		t.Errorf("Quedaron archivos temporales: %v", entries)
+	}
+
+	if err := s.Remove("a.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, _, err := s.ReadFile("a.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Esperado ErrNotExist, obtenido %v", err)
+	}
+	if _, _, err := s.ReadFile("../a.txt"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Esperado ErrInvalidName, obtenido %v", err)
+	}
+}
+
+// TestEnlaces prueba que un enlace simbólico solo se siga si queda dentro de la raíz
+func TestEnlaces(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "files")
+	s := New(root, Quota{})
+	if err := s.WriteFile("dentro.txt", []byte("ok")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "secreto.txt"), []byte("no"), 0644)
+	if err := os.Symlink(filepath.Join(dir, "secreto.txt"), filepath.Join(root, "fuera")); err != nil {
+		t.Skipf("No se pueden crear enlaces simbólicos: %v", err)
+	}
+	os.Symlink("dentro.txt", filepath.Join(root, "alias"))
+
+	if _, _, err := s.ReadFile("fuera"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Se siguió un enlace fuera de la raíz: %v", err)
+	}
+	if err := s.AppendFile("fuera", []byte("x")); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Se escribió a través de un enlace fuera de la raíz: %v", err)
+	}
+	if data, _, err := s.ReadFile("alias"); err != nil || string(data) != "ok" {
+		t.Errorf("El enlace interno debería leerse: '%s' %v", data, err)
+	}
+
+	list, _ := s.List()
+	if len(list) != 1 || list[0].Name != "dentro.txt" {
+		t.Errorf("Listado inesperado: %+v", list)
+	}
+}
+
+// TestCuota prueba los límites de bytes y de cantidad de archivos
+func TestCuota(t *testing.T) {
+	s := New(t.TempDir(), Quota{MaxBytes: 10, MaxFiles: 2})
+
+	if err := s.WriteFile("a.txt", []byte("12345")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	// Reemplazar un archivo solo cuenta la diferencia
+	if err := s.WriteFile("a.txt", []byte("1234567")); err != nil {
+		t.Fatalf("Reemplazo dentro de la cuota: %v", err)
+	}
+	if err := s.AppendFile("a.txt", []byte("1234")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Esperado ErrQuotaExceeded por bytes, obtenido %v", err)
+	}
+	if err := s.WriteFile("b.txt", []byte("1")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := s.WriteFile("c.txt", []byte("1")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Esperado ErrQuotaExceeded por archivos, obtenido %v", err)
+	}
+
+	usage, err := s.Usage()
+	if err != nil || usage.Bytes != 8 || usage.Files != 2 {
+		t.Errorf("Uso inesperado: %+v %v", usage, err)
+	}
+}
+
+// TestConcurrencia prueba que los candados por archivo no pierdan escrituras
+func TestConcurrencia(t *testing.T) {
+	s := New(t.TempDir(), Quota{})
+
+	var wg sync.WaitGroup
+	for f := 0; f < 4; f++ {
+		for i := 0; i < 25; i++ {
+			wg.Add(1)
+			go func(f int) {
+				defer wg.Done()
+				if err := s.AppendFile(fmt.Sprintf("f%d.txt", f), []byte("x")); err != nil {
+					t.Errorf("AppendFile: %v", err)
+				}
+			}(f)
+		}
+	}
+	wg.Wait()
+
+	for f := 0; f < 4; f++ {
+		data, _, err := s.ReadFile(fmt.Sprintf("f%d.txt", f))
+		if err != nil || len(data) != 25 {
+			t.Errorf("f%d.txt: esperado 25 bytes, obtenido %d (%v)", f, len(data), err)
+		}
+	}
+	if len(s.locks) != 0 {
+		t.Errorf("Quedaron %d candados sin liberar", len(s.locks))
+	}
+}
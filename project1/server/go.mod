@@ -1,3 +1,7 @@
 module http-servidor
 
 go 1.21
+
+require http-shared v0.0.0
+
+replace http-shared => ../shared
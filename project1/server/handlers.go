@@ -2,53 +2,103 @@ package main
 
 import (
 	"http-servidor/handlers"
-	"http-servidor/utils"
+	"http-shared/httpmsg"
+	"http-shared/routes"
 )
 
-func HandleRequest(req Request) {
-	switch req.Ruta {
-
-	case "/help":
-		handlers.Help(req.Conn)
-
-	case "/timestamp":
-		handlers.Timestamp(req.Conn, utils.SendResponse)
-
-	case "/fibonacci":
-		print("Fibonacci request received\n")
-		handlers.Fibonacci(req.Conn, req.Parametros, utils.SendResponse)
-
-	case "/createfile":
-		handlers.CreateFile(req.Conn, req.Parametros, utils.SendResponse)
-
-	case "/deletefile":
-		handlers.DeleteFile(req.Conn, req.Parametros, utils.SendResponse)
-
-	case "/reverse":
-		handlers.Reverse(req.Conn, req.Parametros, utils.SendResponse)
-
-	case "/toupper":
-		handlers.ToUpper(req.Conn, req.Parametros, utils.SendResponse)
-
-	case "/random":
-		handlers.Random(req.Conn, req.Parametros["min"], req.Parametros["max"], req.Parametros["count"], utils.SendResponse)
+// Handler atiende una solicitud que ya pasó la validación de la tabla de rutas
+type Handler func(req Request)
 
-	case "/hash":
-		handlers.Hash(req.Conn, req.Parametros["text"], utils.SendResponse)
-
-	case "/simulate":
-		handlers.Simulate(req.Conn, req.Parametros["seconds"], req.Parametros["task"], utils.SendResponse)
-
-	case "/sleep":
-		handlers.Sleep(req.Conn, req.Parametros["seconds"], utils.SendResponse)
-
-	case "/loadtest":
-		handlers.Loadtest(req.Conn, req.Parametros["tasks"], req.Parametros["sleep"], utils.SendResponse)
+// Route une la descripción compartida de una ruta con su handler en el worker
+type Route struct {
+	routes.Route
+	Handler Handler
+}
 
-	case "/ping":
-		handlers.HandlePing(req.Conn)
+// workerRoutes es la única lista de rutas del worker. De aquí salen los pools,
+// /help, /routes y las respuestas 404, 405 y 400 por parámetros inválidos.
+func workerRoutes(s *Server) []Route {
+	get := []string{"GET"}
+	post := []string{"POST"}
+
+	return []Route{
+		{routes.Route{Path: "/help", Methods: get, PoolSize: 2, Idempotent: true, Description: "Lista las rutas disponibles"},
+			func(req Request) { handlers.Help(req.Writer, s.Routes.Routes()) }},
+		{routes.Route{Path: "/routes", Methods: get, Idempotent: true, Description: "Tabla de rutas en JSON, la consulta el dispatcher"},
+			func(req Request) { httpmsg.JSON(req.Writer, 200, s.Routes.Routes()) }},
+		{routes.Route{Path: "/status", Methods: get, Idempotent: true, Description: "Estado del servidor y de sus pools"},
+			func(req Request) { serverStatus(req.Writer, s) }},
+		{routes.Route{Path: "/admin/pools", Methods: []string{"GET", "POST"}, Description: "Carga de los pools; POST con route cambia el tamaño (size) y los límites (min, max) de su pool",
+			Params: []routes.Param{routes.String("route", false), routes.IntMin("size", false, 1), routes.IntMin("min", false, 1), routes.IntMin("max", false, 1)}},
+			func(req Request) { adminPools(req.Writer, s, req.Method, req.Parametros) }},
+		{routes.Route{Path: "/ping", Methods: get, PoolSize: 2, Idempotent: true, Description: "Responde pong"},
+			func(req Request) { handlers.HandlePing(req.Writer) }},
+		{routes.Route{Path: "/timestamp", Methods: get, PoolSize: 2, Idempotent: true, Description: "Hora actual en formato RFC3339"},
+			func(req Request) { handlers.Timestamp(req.Writer) }},
+		{routes.Route{Path: "/fibonacci", Methods: get, PoolSize: 3, Idempotent: true, Description: "Calcula el N-ésimo número de Fibonacci",
+			Params: []routes.Param{routes.IntMin("num", true, 0)}},
+			func(req Request) { handlers.Fibonacci(req.Ctx, req.Writer, req.Parametros) }},
+		{routes.Route{Path: "/createfile", Methods: get, PoolSize: 3, Idempotent: true, Description: "Crea un archivo con el contenido repetido",
+			Params: []routes.Param{routes.String("name", true), routes.String("content", true), routes.IntMin("repeat", true, 1)}},
+			func(req Request) { handlers.CreateFile(req.Writer, s.Files, req.Parametros) }},
+		{routes.Route{Path: "/deletefile", Methods: get, PoolSize: 3, Description: "Elimina un archivo",
+			Params: []routes.Param{routes.String("name", true)}},
+			func(req Request) { handlers.DeleteFile(req.Writer, s.Files, req.Parametros) }},
+		{routes.Route{Path: "/readfile", Methods: get, PoolSize: 3, Idempotent: true, Description: "Retorna el contenido de un archivo, acepta el encabezado Range",
+			Params: []routes.Param{routes.String("name", true)}},
+			func(req Request) { handlers.ReadFile(req.Writer, s.Files, req.Parametros, req.Header.Get("Range")) }},
+		{routes.Route{Path: "/stat", Methods: get, PoolSize: 2, Idempotent: true, Description: "Tamaño, fecha de modificación y SHA-256 de un archivo",
+			Params: []routes.Param{routes.String("name", true)}},
+			func(req Request) { handlers.Stat(req.Writer, s.Files, req.Parametros) }},
+		{routes.Route{Path: "/listfiles", Methods: get, PoolSize: 2, Idempotent: true, Description: "Lista los archivos con tamaño y fecha de modificación"},
+			func(req Request) { handlers.ListFiles(req.Writer, s.Files) }},
+		{routes.Route{Path: "/appendfile", Methods: post, PoolSize: 3, Description: "Agrega el cuerpo al final del archivo, lo crea si no existe",
+			Params: []routes.Param{routes.String("name", true)}},
+			func(req Request) { handlers.AppendFile(req.Writer, s.Files, req.Parametros, []byte(req.Body)) }},
+		{routes.Route{Path: "/uploadfile", Methods: post, PoolSize: 3, Idempotent: true, Description: "Guarda el cuerpo como archivo, reemplazando el anterior",
+			Params: []routes.Param{routes.String("name", true)}},
+			func(req Request) { handlers.UploadFile(req.Writer, s.Files, req.Parametros, []byte(req.Body)) }},
+		{routes.Route{Path: "/filedigests", Methods: get, PoolSize: 2, Idempotent: true, Description: "SHA-256 de cada archivo (usada por el dispatcher para reparar réplicas)"},
+			func(req Request) { handlers.FileDigests(req.Writer, s.Files) }},
+		{routes.Route{Path: "/reverse", Methods: get, PoolSize: 2, Idempotent: true, Description: "Invierte el texto",
+			Params: []routes.Param{routes.String("text", true)}},
+			func(req Request) { handlers.Reverse(req.Writer, req.Parametros) }},
+		{routes.Route{Path: "/toupper", Methods: get, PoolSize: 2, Idempotent: true, Description: "Convierte el texto a mayúsculas",
+			Params: []routes.Param{routes.String("text", true)}},
+			func(req Request) { handlers.ToUpper(req.Writer, req.Parametros) }},
+		{routes.Route{Path: "/random", Methods: get, PoolSize: 2, Idempotent: true, Description: "Genera count números aleatorios entre min y max",
+			Params: []routes.Param{routes.IntMin("count", true, 1), routes.Int("min", true), routes.Int("max", true)}},
+			func(req Request) {
+				handlers.Random(req.Writer, req.Parametros["min"], req.Parametros["max"], req.Parametros["count"])
+			}},
+		{routes.Route{Path: "/hash", Methods: get, PoolSize: 2, Idempotent: true, Description: "Hash SHA-256 del texto",
+			Params: []routes.Param{routes.String("text", true)}},
+			func(req Request) { handlers.Hash(req.Writer, req.Parametros["text"]) }},
+		{routes.Route{Path: "/simulate", Methods: get, PoolSize: 3, Idempotent: true, Description: "Simula una tarea que tarda seconds segundos",
+			Params: []routes.Param{routes.IntMin("seconds", true, 1), routes.String("task", false)}},
+			func(req Request) { handlers.Simulate(req.Ctx, req.Writer, req.Parametros["seconds"], req.Parametros["task"]) }},
+		{routes.Route{Path: "/sleep", Methods: get, PoolSize: 3, Idempotent: true, Description: "Espera seconds segundos",
+			Params: []routes.Param{routes.IntMin("seconds", true, 1)}},
+			func(req Request) { handlers.Sleep(req.Ctx, req.Writer, req.Parametros["seconds"]) }},
+		{routes.Route{Path: "/loadtest", Methods: get, PoolSize: 3, Idempotent: true, Description: "Ejecuta tasks tareas concurrentes de sleep segundos",
+			Params: []routes.Param{routes.IntMin("tasks", true, 1), routes.IntMin("sleep", true, 0)}},
+			func(req Request) { handlers.Loadtest(req.Ctx, req.Writer, req.Parametros["tasks"], req.Parametros["sleep"]) }},
+		{routes.Route{Path: routes.MapRoute, Methods: post, PoolSize: 3, Idempotent: true, Description: "Aplica al cuerpo la función fn de map-reduce (words, wordfreq, pi) y responde valores por llave en JSON (usada por el dispatcher)",
+			Params: []routes.Param{routes.String("fn", true)}},
+			func(req Request) { handlers.Map(req.Ctx, req.Writer, req.Parametros["fn"], req.Parametros, req.Body) }},
+	}
+}
 
-	default:
-		utils.SendResponse(req.Conn, "404 Not Found", "Ruta no encontrada")
+// HandleRequest ejecuta el handler que la tabla de rutas asignó a la solicitud
+func HandleRequest(req Request) {
+	if req.Handler == nil {
+		httpmsg.Text(req.Writer, 404, "Ruta no encontrada")
+		return
+	}
+	// Una solicitud que venció o cuyo cliente se fue mientras esperaba en el pool no se atiende
+	if err := req.Ctx.Err(); err != nil {
+		handlers.Aborted(req.Writer, err)
+		return
 	}
+	req.Handler(req)
 }
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+    "http-shared/httpmsg"
+    "http-servidor/filestore"
+)
+
+// POST /appendfile?name=filename con el texto a agregar en el cuerpo.
+// Si el archivo no existe se crea.
+
+func AppendFile(w httpmsg.ResponseWriter, store *filestore.Store, params map[string]string, body []byte) {
+    name, ok := params["name"]
+    if !ok {
+        httpmsg.Text(w, 400, "Falta el parámetro 'name'\n")
+        return
+    }
+
+    if err := store.AppendFile(name, body); err != nil {
+        fileError(w, err, "No se pudo escribir el archivo\n")
+        return
+    }
+
+    httpmsg.Text(w, 200, "Contenido agregado exitosamente\n")
+}
@@ -0,0 +1,43 @@
+// appendfile_test.go
+package handlers
+
+import (
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
+	"os"
+	"testing"
+)
+
+// TestAppendFile prueba que el cuerpo se agregue al final y que el archivo se cree si no existe
+func TestAppendFile(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	defer os.RemoveAll("files") // Limpiar después de la prueba
+
+	for _, part := range []string{"primera\n", "segunda\n"} {
+		rec := httpmsg.NewRecorder()
+		AppendFile(rec, store, map[string]string{"name": "log.txt"}, []byte(part))
+		if rec.Code != 200 {
+			t.Fatalf("Esperado status 200, obtenido %d", rec.Code)
+		}
+	}
+
+	content, err := os.ReadFile("files/log.txt")
+	if err != nil {
+		t.Fatalf("Error al leer el archivo: %v", err)
+	}
+	if string(content) != "primera\nsegunda\n" {
+		t.Errorf("Contenido inesperado: '%s'", content)
+	}
+}
+
+// TestAppendFile_MissingNameParam prueba el caso de falta del parámetro 'name'
+func TestAppendFile_MissingNameParam(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	AppendFile(rec, store, map[string]string{}, []byte("x"))
+
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
+	}
+}
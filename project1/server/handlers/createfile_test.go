@@ -2,6 +2,8 @@
 package handlers
 
 import (
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
 	"os"
 	"testing"
 )
@@ -9,9 +11,8 @@ import (
 
 // TestCreateFile_Success prueba la creación exitosa de un archivo
 func TestCreateFile_Success(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
 
 	// Crear un directorio 'files' temporal para las pruebas
 	err := os.MkdirAll("files", 0755)
@@ -26,13 +27,13 @@ func TestCreateFile_Success(t *testing.T) {
 		"repeat":  "2",
 	}
 
-	CreateFile(mockConn, params, mockSendResponse)
+	CreateFile(rec, store, params)
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
-	if testBody != "Archivo creado exitosamente\n" {
-		t.Errorf("Esperado body 'Archivo creado exitosamente\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Archivo creado exitosamente\n" {
+		t.Errorf("Esperado body 'Archivo creado exitosamente\\n', obtenido '%s'", rec.Body.String())
 	}
 
 	// Verificar que el archivo fue creado y tiene el contenido correcto
@@ -50,10 +51,7 @@ func TestCreateFile_Success(t *testing.T) {
 
 // TestCreateFile_MissingParams prueba el caso de parámetros faltantes
 func TestCreateFile_MissingParams(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
-
+	store := filestore.New("files", filestore.Quota{})
 	tests := []struct {
 		name     string
 		params   map[string]string
@@ -78,13 +76,14 @@ func TestCreateFile_MissingParams(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			CreateFile(mockConn, tt.params, mockSendResponse)
+			rec := httpmsg.NewRecorder()
+			CreateFile(rec, store, tt.params)
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, testBody)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, rec.Body.String())
 			}
 		})
 	}
@@ -92,10 +91,7 @@ func TestCreateFile_MissingParams(t *testing.T) {
 
 // TestCreateFile_InvalidRepeat prueba el caso de 'repeat' no numérico o negativo/cero
 func TestCreateFile_InvalidRepeat(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
-
+	store := filestore.New("files", filestore.Quota{})
 	tests := []struct {
 		name     string
 		repeat   string
@@ -125,44 +121,77 @@ func TestCreateFile_InvalidRepeat(t *testing.T) {
 				"content": "some content",
 				"repeat":  tt.repeat,
 			}
-			CreateFile(mockConn, params, mockSendResponse)
+			rec := httpmsg.NewRecorder()
+			CreateFile(rec, store, params)
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, testBody)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, rec.Body.String())
 			}
 		})
 	}
 }
 
-// TestCreateFile_WriteFileError prueba un error al escribir el archivo
-// Esto es más complejo de simular directamente con os.WriteFile,
-// normalmente requeriría inyectar una interfaz para la operación de escritura de archivos.
-// Para simplificar, podemos simular una ruta de archivo inválida que cause un error de escritura.
+// TestCreateFile_WriteFileError prueba un error al escribir el archivo.
+// Para forzarlo, la carpeta raíz del store es un archivo regular y no se puede crear.
 func TestCreateFile_WriteFileError(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
+
+	err := os.WriteFile("no_es_carpeta", []byte("x"), 0644)
+	if err != nil {
+		t.Fatalf("No se pudo crear el archivo para la prueba: %v", err)
+	}
+	defer os.Remove("no_es_carpeta")
+	store := filestore.New("no_es_carpeta", filestore.Quota{})
 
-	// Intentamos escribir en una ruta inválida para forzar un error
-	// Por ejemplo, un directorio que no existe y no puede ser creado por os.WriteFile
-	// (si no tiene permisos, etc.). Aquí usamos un carácter inválido en el nombre para forzar error.
 	params := map[string]string{
-		"name":    "invalid/file?.txt", // Carácter inválido para un nombre de archivo
+		"name":    "file.txt",
 		"content": "content",
 		"repeat":  "1",
 	}
 
-	CreateFile(mockConn, params, mockSendResponse)
+	CreateFile(rec, store, params)
+
+	if rec.Code != 500 {
+		t.Errorf("Esperado status 500, obtenido %d", rec.Code)
+	}
+	if rec.Body.String() != "No se pudo crear el archivo\n" {
+		t.Errorf("Esperado body 'No se pudo crear el archivo\\n', obtenido '%s'", rec.Body.String())
+	}
+}
+
+// TestCreateFile_InvalidName prueba que los nombres que salen de la carpeta se rechacen con 400
+func TestCreateFile_InvalidName(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	defer os.RemoveAll("files") // Limpiar después de la prueba
+
+	for _, name := range []string{"../main.go", "invalid/file?.txt", "..", "/etc/passwd"} {
+		rec := httpmsg.NewRecorder()
+		CreateFile(rec, store, map[string]string{"name": name, "content": "x", "repeat": "1"})
+
+		if rec.Code != 400 {
+			t.Errorf("%s: esperado status 400, obtenido %d", name, rec.Code)
+		}
+	}
+	if _, err := os.Stat("../main.go"); err != nil {
+		t.Errorf("main.go fue modificado o borrado: %v", err)
+	}
+}
+
+// TestCreateFile_Quota prueba que al pasar la cuota se responda 507 sin escribir el archivo
+func TestCreateFile_Quota(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{MaxBytes: 10})
+	defer os.RemoveAll("files") // Limpiar después de la prueba
 
-	if testStatus != "500 Internal Server Error" {
-		t.Errorf("Esperado status '500 Internal Server Error', obtenido '%s'", testStatus)
+	rec := httpmsg.NewRecorder()
+	CreateFile(rec, store, map[string]string{"name": "grande.txt", "content": "1234567890", "repeat": "1"})
+
+	if rec.Code != 507 {
+		t.Errorf("Esperado status 507, obtenido %d", rec.Code)
 	}
-	if testBody != "No se pudo crear el archivo\n" {
-		t.Errorf("Esperado body 'No se pudo crear el archivo\\n', obtenido '%s'", testBody)
+	if _, err := os.Stat("files/grande.txt"); !os.IsNotExist(err) {
+		t.Errorf("El archivo no debería existir: %v", err)
 	}
-	// Limpiar cualquier intento de creación de directorio o archivo si lo hubiera
-	os.RemoveAll("invalid")
-}
\ No newline at end of file
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"http-shared/httpmsg"
+	"time"
+)
+
+// esperar duerme d o hasta que se cancele ctx, en ese caso retorna ctx.Err()
+func esperar(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Aborted responde 504 a una solicitud que se dejó de atender porque venció su
+// plazo o porque el cliente se fue
+func Aborted(w httpmsg.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		httpmsg.Text(w, 504, "Se agotó el plazo de la solicitud\n")
+		return
+	}
+	httpmsg.Text(w, 504, "Solicitud cancelada por el cliente\n")
+}
@@ -1,28 +1,24 @@
 package handlers
 
 import (
-    "net"
-    "os"
-    "http-servidor/utils"
+    "http-shared/httpmsg"
+    "http-servidor/filestore"
 )
 
 // /deletefile?name=filename
 
-func DeleteFile(conn net.Conn, params map[string]string, sendResponse SendResponseFunc) {
+func DeleteFile(w httpmsg.ResponseWriter, store *filestore.Store, params map[string]string) {
     name, ok := params["name"]
     if !ok {
-        sendResponse(conn, "400 Bad Request", "Falta el parámetro 'name'\n")
+        httpmsg.Text(w, 400, "Falta el parámetro 'name'\n")
         return
     }
 
-    utils.FilesMutex.Lock() // uso del mutex
-    defer utils.FilesMutex.Unlock()
-
-    err := os.Remove("files/"+name)
+    err := store.Remove(name)
     if err != nil {
-        sendResponse(conn, "500 Internal Server Error", "Error al eliminar el archivo (puede que no exista)\n")
+        fileError(w, err, "Error al eliminar el archivo (puede que no exista)\n")
         return
     }
 
-    sendResponse(conn, "200 OK", "Archivo eliminado exitosamente\n")
+    httpmsg.Text(w, 200, "Archivo eliminado exitosamente\n")
 }
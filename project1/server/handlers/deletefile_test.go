@@ -2,6 +2,8 @@
 package handlers
 
 import (
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
 	"os"
 	"testing"
 )
@@ -9,9 +11,8 @@ import (
 
 // TestDeleteFile_Success prueba la eliminación exitosa de un archivo
 func TestDeleteFile_Success(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
 
 	// Creamos un directorio 'files' y un archivo temporal para la prueba
 	err := os.MkdirAll("files", 0755)
@@ -32,14 +33,14 @@ func TestDeleteFile_Success(t *testing.T) {
 		"name": fileName,
 	}
 
-	// Llama a DeleteFile pasando nuestro mockSendResponse
-	DeleteFile(mockConn, params, mockSendResponse)
+	// Llama a DeleteFile con el Recorder
+	DeleteFile(rec, store, params)
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
-	if testBody != "Archivo eliminado exitosamente\n" {
-		t.Errorf("Esperado body 'Archivo eliminado exitosamente\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Archivo eliminado exitosamente\n" {
+		t.Errorf("Esperado body 'Archivo eliminado exitosamente\\n', obtenido '%s'", rec.Body.String())
 	}
 
 	// Verificar que el archivo realmente fue eliminado
@@ -51,28 +52,26 @@ func TestDeleteFile_Success(t *testing.T) {
 
 // TestDeleteFile_MissingNameParam prueba el caso de falta del parámetro 'name'
 func TestDeleteFile_MissingNameParam(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
 
 	params := map[string]string{} // Parámetros sin 'name'
 
-	// Llama a DeleteFile pasando nuestro mockSendResponse
-	DeleteFile(mockConn, params, mockSendResponse)
+	// Llama a DeleteFile con el Recorder
+	DeleteFile(rec, store, params)
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "Falta el parámetro 'name'\n" {
-		t.Errorf("Esperado body 'Falta el parámetro 'name'\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Falta el parámetro 'name'\n" {
+		t.Errorf("Esperado body 'Falta el parámetro 'name'\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
 // TestDeleteFile_FileNotFound prueba el caso en que el archivo no existe
 func TestDeleteFile_FileNotFound(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
 
 	// Creamos un directorio 'files' para que os.Remove pueda intentar buscar en él
 	err := os.MkdirAll("files", 0755)
@@ -85,13 +84,13 @@ func TestDeleteFile_FileNotFound(t *testing.T) {
 		"name": "non_existent_file.txt",
 	}
 
-	// Llama a DeleteFile pasando nuestro mockSendResponse
-	DeleteFile(mockConn, params, mockSendResponse)
+	// Llama a DeleteFile con el Recorder
+	DeleteFile(rec, store, params)
 
-	if testStatus != "500 Internal Server Error" {
-		t.Errorf("Esperado status '500 Internal Server Error', obtenido '%s'", testStatus)
+	if rec.Code != 500 {
+		t.Errorf("Esperado status 500, obtenido %d", rec.Code)
 	}
-	if testBody != "Error al eliminar el archivo (puede que no exista)\n" {
-		t.Errorf("Esperado body 'Error al eliminar el archivo (puede que no exista)\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Error al eliminar el archivo (puede que no exista)\n" {
+		t.Errorf("Esperado body 'Error al eliminar el archivo (puede que no exista)\\n', obtenido '%s'", rec.Body.String())
 	}
 }
\ No newline at end of file
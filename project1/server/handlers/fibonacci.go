@@ -1,33 +1,66 @@
 package handlers
 
 import (
-	"net"
+	"context"
+	"http-shared/httpmsg"
 	"strconv"
 )
 
 //  /fibonacci?num=N
 
-func Fibonacci(conn net.Conn, params map[string]string, sendResponse SendResponseFunc) {
+func Fibonacci(ctx context.Context, w httpmsg.ResponseWriter, params map[string]string) {
 	print("Fibonacci request received endpoint\n")
 	nStr, ok := params["num"]
 	if !ok {
-		sendResponse(conn, "400 Bad Request", "Falta el parámetro 'num'\n")
+		httpmsg.Text(w, 400, "Falta el parámetro 'num'\n")
 		return
 	}
 
 	n, err := strconv.Atoi(nStr)
 	if err != nil || n < 0 {
-		sendResponse(conn, "400 Bad Request", "El parámetro 'num' debe ser un entero positivo\n")
+		httpmsg.Text(w, 400, "El parámetro 'num' debe ser un entero positivo\n")
 		return
 	}
 
-	result := fibonacci(n)
-	sendResponse(conn, "200 OK", strconv.Itoa(result)+"\n")
+	result, err := fibonacciCtx(ctx, n)
+	if err != nil {
+		Aborted(w, err)
+		return
+	}
+	httpmsg.Text(w, 200, strconv.Itoa(result)+"\n")
 }
 
 func fibonacci(n int) int {
-	if n <= 1 {
-		return n
+	result, _ := fibonacciCtx(context.Background(), n)
+	return result
+}
+
+// Cada cuántas llamadas recursivas se revisa si se canceló el cálculo
+const fibonacciCheckEvery = 1 << 16
+
+// fibonacciCtx calcula fibonacci(n) y se detiene si se cancela ctx
+func fibonacciCtx(ctx context.Context, n int) (int, error) {
+	calls := 0
+	var err error
+	var fib func(n int) int
+	fib = func(n int) int {
+		if err != nil {
+			return 0
+		}
+		calls++
+		if calls%fibonacciCheckEvery == 0 {
+			if err = ctx.Err(); err != nil {
+				return 0
+			}
+		}
+		if n <= 1 {
+			return n
+		}
+		return fib(n-1) + fib(n-2)
+	}
+	result := fib(n)
+	if err != nil {
+		return 0, err
 	}
-	return fibonacci(n-1) + fibonacci(n-2)
+	return result, nil
 }
@@ -2,6 +2,8 @@
 package handlers 
 
 import (
+	"context"
+	"http-shared/httpmsg"
 	"testing"
 )
 
@@ -24,18 +26,16 @@ func TestFibonacci_ValidInput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
 			params := map[string]string{"num": tt.inputNum}
-			Fibonacci(mockConn, params, mockSendResponse)
+			Fibonacci(context.Background(), rec, params)
 
-			if testStatus != "200 OK" {
-				t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+			if rec.Code != 200 {
+				t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, testBody)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, rec.Body.String())
 			}
 		})
 	}
@@ -43,18 +43,16 @@ func TestFibonacci_ValidInput(t *testing.T) {
 
 // TestFibonacci_MissingParam prueba el caso de falta del parámetro 'num'
 func TestFibonacci_MissingParam(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	params := map[string]string{} // Parámetros sin 'num'
-	Fibonacci(mockConn, params, mockSendResponse)
+	Fibonacci(context.Background(), rec, params)
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "Falta el parámetro 'num'\n" {
-		t.Errorf("Esperado body 'Falta el parámetro 'num'\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Falta el parámetro 'num'\n" {
+		t.Errorf("Esperado body 'Falta el parámetro 'num'\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
@@ -71,18 +69,16 @@ func TestFibonacci_InvalidNum(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
 			params := map[string]string{"num": tt.inputNum}
-			Fibonacci(mockConn, params, mockSendResponse)
+			Fibonacci(context.Background(), rec, params)
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, testBody)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, rec.Body.String())
 			}
 		})
 	}
@@ -93,19 +89,17 @@ func TestFibonacci_InvalidNum(t *testing.T) {
 // asegura que el valor devuelto sea el correcto para un N moderadamente grande.
 // Ten en cuenta que para valores de N muy grandes (ej. > 40-45), la recursión pura será muy lenta.
 func TestFibonacci_LargeInput(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	params := map[string]string{"num": "20"}
-	Fibonacci(mockConn, params, mockSendResponse)
+	Fibonacci(context.Background(), rec, params)
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 	// Fibonacci(20) = 6765
-	if testBody != "6765\n" {
-		t.Errorf("Esperado body '6765\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "6765\n" {
+		t.Errorf("Esperado body '6765\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
@@ -134,4 +128,16 @@ func Test_fibonacci_HelperFunction(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestFibonacci_Cancelado verifica que un cálculo largo se detenga al cancelar el contexto
+func TestFibonacci_Cancelado(t *testing.T) {
+	rec := httpmsg.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	Fibonacci(ctx, rec, map[string]string{"num": "60"})
+	if rec.Code != 504 {
+		t.Errorf("Esperado status 504, obtenido %d", rec.Code)
+	}
+}
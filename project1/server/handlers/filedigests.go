@@ -0,0 +1,37 @@
+package handlers
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "http-shared/httpmsg"
+    "io/fs"
+    "http-servidor/filestore"
+)
+
+// /filedigests
+// Retorna el SHA-256 de cada archivo del worker para que el dispatcher compare réplicas.
+
+func FileDigests(w httpmsg.ResponseWriter, store *filestore.Store) {
+    list, err := store.List()
+    if err != nil {
+        httpmsg.Text(w, 500, "No se pudo leer la carpeta de archivos\n")
+        return
+    }
+
+    digests := make(map[string]string)
+    for _, info := range list {
+        data, _, err := store.ReadFile(info.Name)
+        if errors.Is(err, fs.ErrNotExist) {
+            continue // Se borró mientras se calculaban los digests
+        }
+        if err != nil {
+            httpmsg.Text(w, 500, "No se pudo leer el archivo "+info.Name+"\n")
+            return
+        }
+        sum := sha256.Sum256(data)
+        digests[info.Name] = hex.EncodeToString(sum[:])
+    }
+
+    httpmsg.JSON(w, 200, digests)
+}
@@ -0,0 +1,60 @@
+// filedigests_test.go
+package handlers
+
+import (
+	"encoding/json"
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
+	"os"
+	"testing"
+)
+
+// TestFileDigests prueba que se retorne el SHA-256 de cada archivo
+func TestFileDigests(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	err := os.MkdirAll("files", 0755)
+	if err != nil {
+		t.Fatalf("No se pudo crear el directorio 'files' para la prueba: %v", err)
+	}
+	defer os.RemoveAll("files") // Limpiar después de la prueba
+
+	os.WriteFile("files/a.txt", []byte("hola"), 0644)
+	os.WriteFile("files/b.txt", []byte(""), 0644)
+
+	FileDigests(rec, store)
+
+	if rec.Code != 200 {
+		t.Fatalf("Esperado status 200, obtenido %d", rec.Code)
+	}
+	var digests map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &digests); err != nil {
+		t.Fatalf("JSON inválido: %v", err)
+	}
+	expected := map[string]string{
+		"a.txt": "b221d9dbb083a7f33428d7c2a3c3198ae925614d70210e28716ccaa7cd4ddb79",
+		"b.txt": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+	if len(digests) != len(expected) {
+		t.Errorf("Esperados %d archivos, obtenido %v", len(expected), digests)
+	}
+	for name, sum := range expected {
+		if digests[name] != sum {
+			t.Errorf("Digest de %s: esperado %s, obtenido %s", name, sum, digests[name])
+		}
+	}
+}
+
+// TestFileDigests_SinCarpeta prueba que sin files/ se retorne un mapa vacío
+func TestFileDigests_SinCarpeta(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+	os.RemoveAll("files")
+
+	FileDigests(rec, store)
+
+	if rec.Code != 200 || rec.Body.String() != "{}" {
+		t.Errorf("Esperado 200 y {}, obtenido %d y '%s'", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+    "errors"
+    "http-servidor/filestore"
+    "http-shared/httpmsg"
+)
+
+// fileError responde los errores de filestore con su propio código (nombre
+// inválido 400, cuota 507); cualquier otro error es un 500 con msg.
+func fileError(w httpmsg.ResponseWriter, err error, msg string) {
+    if errors.Is(err, filestore.ErrInvalidName) || errors.Is(err, filestore.ErrQuotaExceeded) {
+        httpmsg.Text(w, httpmsg.StatusOf(err), err.Error()+"\n")
+        return
+    }
+    httpmsg.Text(w, 500, msg)
+}
@@ -3,14 +3,14 @@ package handlers
 import (
 	"crypto/sha256"
 	"fmt"
-	"net"
+	"http-shared/httpmsg"
 	"strings"
 )
 
-func Hash(conn net.Conn, text string, sendResponse SendResponseFunc) {
+func Hash(w httpmsg.ResponseWriter, text string) {
 
 	if strings.TrimSpace(text) == "" {
-        sendResponse(conn, "400 Bad Request", "Texto no puede ser vacio\n")
+        httpmsg.Text(w, 400, "Texto no puede ser vacio\n")
         return
     }
 
@@ -23,6 +23,6 @@ func Hash(conn net.Conn, text string, sendResponse SendResponseFunc) {
 	body := "El hash SHA-256 del texto es:\n\n"
 	body += hashedHex + "\n"
 
-	sendResponse(conn, "200 OK", body)
+	httpmsg.Text(w, 200, body)
 
 }
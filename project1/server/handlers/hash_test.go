@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"http-shared/httpmsg"
 	"crypto/sha256"
 	"fmt"
 	"testing"
@@ -9,9 +10,7 @@ import (
 
 // TestHash_ValidInput prueba un caso exitoso con una entrada de texto válida
 func TestHash_ValidInput(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	inputText := "hello world"
 	// Calcular el hash SHA-256 esperado de "hello world"
@@ -19,57 +18,51 @@ func TestHash_ValidInput(t *testing.T) {
 	hasher.Write([]byte(inputText))
 	expectedHash := fmt.Sprintf("%x", hasher.Sum(nil))
 
-	Hash(mockConn, inputText, mockSendResponse)
+	Hash(rec, inputText)
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	expectedBody := "El hash SHA-256 del texto es:\n\n" + expectedHash + "\n"
-	if testBody != expectedBody {
-		t.Errorf("Cuerpo de la respuesta inesperado.\nEsperado:\n%sObtenido:\n%s", expectedBody, testBody)
+	if rec.Body.String() != expectedBody {
+		t.Errorf("Cuerpo de la respuesta inesperado.\nEsperado:\n%sObtenido:\n%s", expectedBody, rec.Body.String())
 	}
 }
 
 // TestHash_EmptyInput prueba el caso de texto vacío
 func TestHash_EmptyInput(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	inputText := ""
-	Hash(mockConn, inputText, mockSendResponse)
+	Hash(rec, inputText)
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "Texto no puede ser vacio\n" {
-		t.Errorf("Esperado body 'Texto no puede ser vacio\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Texto no puede ser vacio\n" {
+		t.Errorf("Esperado body 'Texto no puede ser vacio\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
 // TestHash_WhitespaceInput prueba el caso de texto con solo espacios en blanco
 func TestHash_WhitespaceInput(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	inputText := "   \t\n " // Espacios, tabulaciones, nueva línea
-	Hash(mockConn, inputText, mockSendResponse)
+	Hash(rec, inputText)
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "Texto no puede ser vacio\n" {
-		t.Errorf("Esperado body 'Texto no puede ser vacio\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Texto no puede ser vacio\n" {
+		t.Errorf("Esperado body 'Texto no puede ser vacio\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
 // TestHash_DifferentInput prueba con un texto diferente para asegurar que el hash es correcto
 func TestHash_DifferentInput(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	inputText := "GoLang rocks!"
 	// Calcular el hash SHA-256 esperado de "GoLang rocks!"
@@ -77,14 +70,14 @@ func TestHash_DifferentInput(t *testing.T) {
 	hasher.Write([]byte(inputText))
 	expectedHash := fmt.Sprintf("%x", hasher.Sum(nil))
 
-	Hash(mockConn, inputText, mockSendResponse)
+	Hash(rec, inputText)
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	expectedBody := "El hash SHA-256 del texto es:\n\n" + expectedHash + "\n"
-	if testBody != expectedBody {
-		t.Errorf("Cuerpo de la respuesta inesperado.\nEsperado:\n%sObtenido:\n%s", expectedBody, testBody)
+	if rec.Body.String() != expectedBody {
+		t.Errorf("Cuerpo de la respuesta inesperado.\nEsperado:\n%sObtenido:\n%s", expectedBody, rec.Body.String())
 	}
 }
\ No newline at end of file
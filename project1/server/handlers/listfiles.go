@@ -0,0 +1,19 @@
+package handlers
+
+import (
+    "http-shared/httpmsg"
+    "http-servidor/filestore"
+)
+
+// /listfiles
+// Retorna nombre, tamaño y fecha de modificación de cada archivo en JSON, ordenados por nombre.
+
+func ListFiles(w httpmsg.ResponseWriter, store *filestore.Store) {
+    list, err := store.List()
+    if err != nil {
+        httpmsg.Text(w, 500, "No se pudo leer la carpeta de archivos\n")
+        return
+    }
+
+    httpmsg.JSON(w, 200, list)
+}
@@ -0,0 +1,54 @@
+// listfiles_test.go
+package handlers
+
+import (
+	"encoding/json"
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"os"
+	"testing"
+)
+
+// TestListFiles prueba que se listen los archivos ordenados por nombre
+func TestListFiles(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	err := os.MkdirAll("files/subcarpeta", 0755)
+	if err != nil {
+		t.Fatalf("No se pudo crear el directorio 'files' para la prueba: %v", err)
+	}
+	defer os.RemoveAll("files") // Limpiar después de la prueba
+	os.WriteFile("files/b.txt", []byte("12345"), 0644)
+	os.WriteFile("files/a.txt", []byte("1"), 0644)
+
+	ListFiles(rec, store)
+
+	if rec.Code != 200 {
+		t.Fatalf("Esperado status 200, obtenido %d", rec.Code)
+	}
+	var list []routes.FileInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("JSON inválido: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "a.txt" || list[1].Name != "b.txt" {
+		t.Fatalf("Listado inesperado: %+v", list)
+	}
+	if list[0].Size != 1 || list[1].Size != 5 || list[1].ModTime.IsZero() {
+		t.Errorf("Tamaños o fechas inesperados: %+v", list)
+	}
+}
+
+// TestListFiles_SinCarpeta prueba que sin files/ se retorne una lista vacía
+func TestListFiles_SinCarpeta(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+	os.RemoveAll("files")
+
+	ListFiles(rec, store)
+
+	if rec.Code != 200 || rec.Body.String() != "[]" {
+		t.Errorf("Esperado 200 y [], obtenido %d y '%s'", rec.Code, rec.Body.String())
+	}
+}
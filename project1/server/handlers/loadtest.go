@@ -1,23 +1,24 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
-	"net"
+	"http-shared/httpmsg"
 	"strconv"
 	"sync"
 	"time"
 )
 
-func Loadtest(conn net.Conn, tasks string, sleep string, sendResponse SendResponseFunc) {
+func Loadtest(ctx context.Context, w httpmsg.ResponseWriter, tasks string, sleep string) {
 	tasksI, err := strconv.Atoi(tasks)
 	if err != nil || tasksI < 1 {
-		sendResponse(conn, "400 Bad Request", "El parametro 'tasks' debe ser un número valido mayor que 0\n")
+		httpmsg.Text(w, 400, "El parametro 'tasks' debe ser un número valido mayor que 0\n")
 		return
 	}
 
 	sleepI, err := strconv.Atoi(sleep)
 	if err != nil || sleepI < 0 {
-		sendResponse(conn, "400 Bad Request", "El parametro 'sleep' debe ser un numero valido\n")
+		httpmsg.Text(w, 400, "El parametro 'sleep' debe ser un numero valido\n")
 		return
 	}
 
@@ -29,13 +30,20 @@ func Loadtest(conn net.Conn, tasks string, sleep string, sendResponse SendRespon
 		go func(id int) {
 			defer wg.Done()
 			fmt.Printf("Comenzando tarea %d \n", id+1)
-			time.Sleep(time.Duration(sleepI) * time.Second)
+			if esperar(ctx, time.Duration(sleepI)*time.Second) != nil {
+				fmt.Printf("Tarea %d cancelada\n", id+1)
+				return
+			}
 			fmt.Printf("Tarea %d finalizada\n", id+1)
 		}(i)
 	}
 
 	// Espera a que terminen todas las tareas
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		Aborted(w, err)
+		return
+	}
 
 	horaFin := time.Now()
 	duration := horaFin.Sub(horaInicio)
@@ -49,5 +57,5 @@ func Loadtest(conn net.Conn, tasks string, sleep string, sendResponse SendRespon
 		duration.Seconds(),
 	)
 
-	sendResponse(conn, "200 OK", body)
+	httpmsg.Text(w, 200, body)
 }
@@ -2,6 +2,8 @@
 package handlers
 
 import (
+	"context"
+	"http-shared/httpmsg"
 	"strconv"
 	"strings"
 	"testing"
@@ -10,34 +12,32 @@ import (
 
 // TestLoadtest_ValidInput_NoSleep prueba un caso exitoso con sleep=0
 func TestLoadtest_ValidInput_NoSleep(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	tasks := "5"
 	sleep := "0" // No sleep for quicker testing
 
-	Loadtest(mockConn, tasks, sleep, mockSendResponse)
+	Loadtest(context.Background(), rec, tasks, sleep)
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	// Verificar que el cuerpo contiene la información correcta
 	expectedTasks := 5
 	expectedSleep := 0
-	if !strings.Contains(testBody, "Se ejecutaron "+strconv.Itoa(expectedTasks)+" tareas concurrentes") {
-		t.Errorf("El cuerpo de la respuesta no menciona el número de tareas esperado.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Se ejecutaron "+strconv.Itoa(expectedTasks)+" tareas concurrentes") {
+		t.Errorf("El cuerpo de la respuesta no menciona el número de tareas esperado.\nObtenido:\n%s", rec.Body.String())
 	}
-	if !strings.Contains(testBody, "con "+strconv.Itoa(expectedSleep)+" segundos de espera cada una.") {
-		t.Errorf("El cuerpo de la respuesta no menciona el tiempo de espera esperado.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "con "+strconv.Itoa(expectedSleep)+" segundos de espera cada una.") {
+		t.Errorf("El cuerpo de la respuesta no menciona el tiempo de espera esperado.\nObtenido:\n%s", rec.Body.String())
 	}
 
 	// La duración debe ser muy cercana a cero si sleep es 0 y las tareas son mínimas.
 	// Extraer la duración y verificar que sea pequeña.
-	lines := strings.Split(testBody, "\n")
+	lines := strings.Split(rec.Body.String(), "\n")
 	if len(lines) < 4 {
-		t.Fatalf("El cuerpo de la respuesta tiene formato inesperado: %s", testBody)
+		t.Fatalf("El cuerpo de la respuesta tiene formato inesperado: %s", rec.Body.String())
 	}
 	durationLine := lines[len(lines)-1] // La última línea es la duración
 	if !strings.HasPrefix(durationLine, "Duracion total:") {
@@ -59,19 +59,17 @@ func TestLoadtest_ValidInput_NoSleep(t *testing.T) {
 
 // TestLoadtest_ValidInput_WithSleep prueba un caso exitoso con sleep
 func TestLoadtest_ValidInput_WithSleep(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	tasks := "3"
 	sleep := "1" // 1 segundo de espera por tarea
 
 	startTime := time.Now()
-	Loadtest(mockConn, tasks, sleep, mockSendResponse)
+	Loadtest(context.Background(), rec, tasks, sleep)
 	endTime := time.Now()
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	// La duración total debería ser aproximadamente el valor de 'sleep'
@@ -86,8 +84,8 @@ func TestLoadtest_ValidInput_WithSleep(t *testing.T) {
 	}
 
 	// Verificar el contenido del body de la respuesta
-	if !strings.Contains(testBody, "Se ejecutaron 3 tareas concurrentes con 1 segundos de espera cada una.") {
-		t.Errorf("El cuerpo de la respuesta no contiene el resumen esperado.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Se ejecutaron 3 tareas concurrentes con 1 segundos de espera cada una.") {
+		t.Errorf("El cuerpo de la respuesta no contiene el resumen esperado.\nObtenido:\n%s", rec.Body.String())
 	}
 }
 
@@ -106,17 +104,15 @@ func TestLoadtest_InvalidTasks(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
-			Loadtest(mockConn, tt.tasks, "1", mockSendResponse) // sleep can be valid
+			Loadtest(context.Background(), rec, tt.tasks, "1") // sleep can be valid
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, testBody)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, rec.Body.String())
 			}
 		})
 	}
@@ -136,18 +132,32 @@ func TestLoadtest_InvalidSleep(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
-			Loadtest(mockConn, "1", tt.sleep, mockSendResponse) // tasks can be valid
+			Loadtest(context.Background(), rec, "1", tt.sleep) // tasks can be valid
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, testBody)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, rec.Body.String())
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestLoadtest_Plazo verifica que las tareas se interrumpan cuando vence el contexto
+func TestLoadtest_Plazo(t *testing.T) {
+	rec := httpmsg.NewRecorder()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	Loadtest(ctx, rec, "3", "5")
+	if duration := time.Since(start); duration > time.Second {
+		t.Errorf("Loadtest no se interrumpió con el plazo, tardó %s", duration)
+	}
+	if rec.Code != 504 {
+		t.Errorf("Esperado status 504, obtenido %d", rec.Code)
+	}
+}
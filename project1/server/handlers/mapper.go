@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//  POST /map?fn=F con el chunk en el cuerpo
+
+// MapFunc procesa un chunk de un trabajo map-reduce del dispatcher. params trae los
+// parámetros del chunk, por ejemplo iterations para pi.
+type MapFunc func(ctx context.Context, params map[string]string, chunk string) (routes.MapResult, error)
+
+// Mappers son las funciones que atiende /map según el parámetro fn
+var Mappers = map[string]MapFunc{
+	"words":    mapWords,
+	"wordfreq": mapWordFreq,
+	"pi":       mapPi,
+}
+
+// Cada cuántas iteraciones de pi se revisa si se canceló el cálculo
+const mapCheckEvery = 1 << 16
+
+// Map aplica la función fn al chunk y responde el resultado en JSON
+func Map(ctx context.Context, w httpmsg.ResponseWriter, fn string, params map[string]string, chunk string) {
+	mapper, ok := Mappers[fn]
+	if !ok {
+		names := make([]string, 0, len(Mappers))
+		for name := range Mappers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		httpmsg.Text(w, 400, fmt.Sprintf("Función '%s' desconocida, use una de: %s\n", fn, strings.Join(names, ", ")))
+		return
+	}
+	result, err := mapper(ctx, params, chunk)
+	if err != nil {
+		if ctx.Err() != nil {
+			Aborted(w, ctx.Err())
+			return
+		}
+		httpmsg.Text(w, httpmsg.StatusOf(err), err.Error()+"\n")
+		return
+	}
+	httpmsg.JSON(w, 200, result)
+}
+
+// mapWords cuenta las palabras del chunk
+func mapWords(ctx context.Context, params map[string]string, chunk string) (routes.MapResult, error) {
+	return routes.MapResult{"words": float64(len(strings.Fields(chunk)))}, nil
+}
+
+// mapWordFreq cuenta cuántas veces aparece cada palabra, en minúsculas y sin puntuación
+func mapWordFreq(ctx context.Context, params map[string]string, chunk string) (routes.MapResult, error) {
+	result := routes.MapResult{}
+	for _, word := range strings.Fields(chunk) {
+		word = strings.ToLower(strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }))
+		if word != "" {
+			result[word]++
+		}
+	}
+	return result, nil
+}
+
+// mapPi genera iterations puntos al azar en el cuadrado unitario y cuenta los que caen
+// dentro del cuarto de círculo
+func mapPi(ctx context.Context, params map[string]string, chunk string) (routes.MapResult, error) {
+	iterations, err := strconv.Atoi(params["iterations"])
+	if err != nil || iterations <= 0 {
+		return nil, &httpmsg.Error{Status: 400, Msg: "El parámetro 'iterations' debe ser un entero positivo"}
+	}
+	inside := 0
+	for i := 0; i < iterations; i++ {
+		if i%mapCheckEvery == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		x, y := rand.Float64(), rand.Float64()
+		if x*x+y*y <= 1.0 {
+			inside++
+		}
+	}
+	return routes.MapResult{"inside": float64(inside), "points": float64(iterations)}, nil
+}
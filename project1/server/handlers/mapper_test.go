@@ -0,0 +1,88 @@
+// mapper_test.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"strings"
+	"testing"
+)
+
+// TestMap_Funciones prueba las funciones de map con un chunk de ejemplo
+func TestMap_Funciones(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       string
+		params   map[string]string
+		chunk    string
+		expected routes.MapResult
+	}{
+		{"Words", "words", nil, "hola mundo\n  hola\totra vez ", routes.MapResult{"words": 5}},
+		{"WordsVacio", "words", nil, "   ", routes.MapResult{"words": 0}},
+		{"WordFreq", "wordfreq", nil, "Hola, mundo. ¡hola! 42", routes.MapResult{"hola": 2, "mundo": 1, "42": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httpmsg.NewRecorder()
+			Map(context.Background(), rec, tt.fn, tt.params, tt.chunk)
+			if rec.Code != 200 {
+				t.Fatalf("Esperado status 200, obtenido %d: %s", rec.Code, rec.Body.String())
+			}
+			var result routes.MapResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("JSON inválido: %v", err)
+			}
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Esperado %v, obtenido %v", tt.expected, result)
+			}
+			for key, value := range tt.expected {
+				if result[key] != value {
+					t.Errorf("Llave %q: esperado %v, obtenido %v", key, value, result[key])
+				}
+			}
+		})
+	}
+}
+
+// TestMap_Pi verifica que pi cuente todos los puntos y que los de adentro sean razonables
+func TestMap_Pi(t *testing.T) {
+	rec := httpmsg.NewRecorder()
+	Map(context.Background(), rec, "pi", map[string]string{"iterations": "100000"}, "")
+
+	var result routes.MapResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || rec.Code != 200 {
+		t.Fatalf("Respuesta inesperada %d: %s", rec.Code, rec.Body.String())
+	}
+	if result["points"] != 100000 {
+		t.Errorf("Esperados 100000 puntos, obtenido %v", result["points"])
+	}
+	if pi := 4 * result["inside"] / result["points"]; pi < 3.1 || pi > 3.2 {
+		t.Errorf("Estimación de pi fuera de rango: %v", pi)
+	}
+}
+
+// TestMap_Errores prueba una función desconocida, parámetros inválidos y la cancelación
+func TestMap_Errores(t *testing.T) {
+	rec := httpmsg.NewRecorder()
+	Map(context.Background(), rec, "nada", nil, "")
+	if rec.Code != 400 || !strings.Contains(rec.Body.String(), "pi, wordfreq, words") {
+		t.Errorf("Esperado 400 con las funciones disponibles, obtenido %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httpmsg.NewRecorder()
+	Map(context.Background(), rec, "pi", map[string]string{"iterations": "0"}, "")
+	if rec.Code != 400 {
+		t.Errorf("Esperado 400 con iterations inválido, obtenido %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = httpmsg.NewRecorder()
+	Map(ctx, rec, "pi", map[string]string{"iterations": "1000000"}, "")
+	if rec.Code != 504 {
+		t.Errorf("Esperado 504 al cancelar, obtenido %d", rec.Code)
+	}
+}
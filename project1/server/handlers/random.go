@@ -3,37 +3,37 @@ package handlers
 import (
 	"fmt"
 	"math/rand"
-	"net"
+	"http-shared/httpmsg"
 	"strconv"
 )
 
-func Random(conn net.Conn, min string, max string, cantidad string, sendResponse SendResponseFunc) {
+func Random(w httpmsg.ResponseWriter, min string, max string, cantidad string) {
 
 	cantidadI, err := strconv.Atoi(cantidad)
 	if err != nil {
-		sendResponse(conn, "400 Bad Request", "Cantidad debe ser un numero valido\n")
+		httpmsg.Text(w, 400, "Cantidad debe ser un numero valido\n")
 		return
 	}
 
 	minI, err := strconv.Atoi(min)
 	if err != nil {
-		sendResponse(conn, "400 Bad Request", "El numero minimo debe ser un numero valido\n")
+		httpmsg.Text(w, 400, "El numero minimo debe ser un numero valido\n")
 		return
 	}
 
 	maxI, err := strconv.Atoi(max)
 	if err != nil {
-		sendResponse(conn, "400 Bad Request", "El numero maximo debe ser un numero valido\n")
+		httpmsg.Text(w, 400, "El numero maximo debe ser un numero valido\n")
 		return
 	}
 
 	if(cantidadI <= 0){
-		sendResponse(conn, "400 Bad Request", "La cantidad debe ser un numero entero positivo\n")
+		httpmsg.Text(w, 400, "La cantidad debe ser un numero entero positivo\n")
 		return
 	}
 
 	if(minI >= maxI){
-		sendResponse(conn, "400 Bad Request", "El minimo debe ser menor al maximo\n")
+		httpmsg.Text(w, 400, "El minimo debe ser menor al maximo\n")
 		return
 	}
 
@@ -52,6 +52,6 @@ func Random(conn net.Conn, min string, max string, cantidad string, sendResponse
 	for i, num := range listaNumRandom {
 		body += fmt.Sprintf("%d\t%d\n", i+1, num)
 	}
-	sendResponse(conn, "200 OK", body)
+	httpmsg.Text(w, 200, body)
 
 }
@@ -2,6 +2,7 @@
 package handlers // Debe ser el mismo paquete que tu función Random
 
 import (
+	"http-shared/httpmsg"
 	"strings"
 	"testing"
 	"math/rand" // Necesario para rand.Seed
@@ -13,65 +14,59 @@ func TestRandom_ValidInput(t *testing.T) {
 	// Si solo te interesa el formato y no los valores exactos, puedes usar time.Now().UnixNano().
 	rand.Seed(42) // Para resultados predecibles en los números aleatorios
 
-	mockConn := &MockConn{} // Crea una conexión falsa
-	testStatus = ""         // Resetea las variables de la respuesta simulada
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
-	// Llama a tu función Random, pero ahora le pasas nuestro 'mockSendResponse'
-	Random(mockConn, "1", "10", "5", mockSendResponse)
+	// Llama a tu función Random con el Recorder
+	Random(rec, "1", "10", "5")
 
-	// Ahora verificamos lo que `mockSendResponse` guardó
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	// Ahora verificamos lo que el Recorder guardó
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	expectedPrefix := "Se generaron 5 numeros aleatorios entre 1 y 10:"
-	if !strings.HasPrefix(testBody, expectedPrefix) {
-		t.Errorf("El cuerpo de la respuesta no empieza como se esperaba.\nEsperado: %s...\nObtenido: %s", expectedPrefix, testBody)
+	if !strings.HasPrefix(rec.Body.String(), expectedPrefix) {
+		t.Errorf("El cuerpo de la respuesta no empieza como se esperaba.\nEsperado: %s...\nObtenido: %s", expectedPrefix, rec.Body.String())
 	}
 
 	// Puedes añadir más verificaciones si quieres:
 	// Por ejemplo, que el cuerpo contenga "Indice\tNumero" y "------\t------"
-	if !strings.Contains(testBody, "Indice\tNumero") || !strings.Contains(testBody, "------\t------") {
-		t.Errorf("El formato de la tabla no es el esperado:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Indice\tNumero") || !strings.Contains(rec.Body.String(), "------\t------") {
+		t.Errorf("El formato de la tabla no es el esperado:\n%s", rec.Body.String())
 	}
 
 	// Puedes verificar que hay 5 líneas de números aleatorios + las líneas de encabezado
-	lines := strings.Split(strings.TrimSpace(testBody), "\n")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
 	if len(lines) != 4+5 { // 4 líneas de encabezado (intro, título, Indice/Numero, ------) + 5 líneas de números
-		t.Errorf("Se esperaban %d líneas de salida, se obtuvieron %d. Salida:\n%s", 4+5, len(lines), testBody)
+		t.Errorf("Se esperaban %d líneas de salida, se obtuvieron %d. Salida:\n%s", 4+5, len(lines), rec.Body.String())
 	}
 }
 
 // Prueba el caso de una cantidad no numérica
 func TestRandom_InvalidCantidad(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
-	Random(mockConn, "3", "10", "abc", mockSendResponse)
+	Random(rec, "3", "10", "abc")
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "Cantidad debe ser un numero valido\n" {
-		t.Errorf("Esperado body 'Cantidad debe ser un numero valido\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Cantidad debe ser un numero valido\n" {
+		t.Errorf("Esperado body 'Cantidad debe ser un numero valido\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
 // Prueba el caso de una cantidad negativa
 func TestRandom_NegativeCantidad(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
-	Random(mockConn, "1", "10", "-5", mockSendResponse)
+	Random(rec, "1", "10", "-5")
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "La cantidad debe ser un numero entero positivo\n" {
-		t.Errorf("Esperado body 'La cantidad debe ser un numero entero positivo\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "La cantidad debe ser un numero entero positivo\n" {
+		t.Errorf("Esperado body 'La cantidad debe ser un numero entero positivo\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
@@ -79,16 +74,14 @@ func TestRandom_NegativeCantidad(t *testing.T) {
 // siguiendo el mismo patrón.
 // Ejemplo:
 func TestRandom_MinGreaterThanMax(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
-	Random(mockConn, "10", "5", "3", mockSendResponse)
+	Random(rec, "10", "5", "3")
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "El minimo debe ser menor al maximo\n" {
-		t.Errorf("Esperado body 'El minimo debe ser menor al maximo\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "El minimo debe ser menor al maximo\n" {
+		t.Errorf("Esperado body 'El minimo debe ser menor al maximo\\n', obtenido '%s'", rec.Body.String())
 	}
 }
\ No newline at end of file
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+    "errors"
+    "http-shared/httpmsg"
+    "io/fs"
+    "strconv"
+    "http-servidor/filestore"
+)
+
+// /readfile?name=filename
+// Acepta el encabezado Range con un solo rango ("bytes=0-99", "bytes=100-", "bytes=-50").
+
+func ReadFile(w httpmsg.ResponseWriter, store *filestore.Store, params map[string]string, rangeHeader string) {
+    name, ok := params["name"]
+    if !ok {
+        httpmsg.Text(w, 400, "Falta el parámetro 'name'\n")
+        return
+    }
+
+    data, _, err := store.ReadFile(name)
+    if errors.Is(err, fs.ErrNotExist) {
+        httpmsg.Text(w, 404, "El archivo no existe\n")
+        return
+    }
+    if err != nil {
+        fileError(w, err, "No se pudo leer el archivo\n")
+        return
+    }
+
+    size := int64(len(data))
+    w.Header().Set("Accept-Ranges", "bytes")
+    r, partial, err := httpmsg.ParseRange(rangeHeader, size)
+    if err != nil {
+        w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+        httpmsg.Text(w, 416, "Rango fuera del archivo\n")
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/octet-stream")
+    if partial {
+        w.Header().Set("Content-Range", r.ContentRange(size))
+        w.WriteHeader(206)
+        w.Write(data[r.Start : r.End+1])
+        return
+    }
+    w.WriteHeader(200)
+    w.Write(data)
+}
@@ -0,0 +1,96 @@
+// readfile_test.go
+package handlers
+
+import (
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
+	"os"
+	"testing"
+)
+
+// TestReadFile_Success prueba la lectura de un archivo existente
+func TestReadFile_Success(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	err := os.MkdirAll("files", 0755)
+	if err != nil {
+		t.Fatalf("No se pudo crear el directorio 'files' para la prueba: %v", err)
+	}
+	defer os.RemoveAll("files") // Limpiar después de la prueba
+
+	err = os.WriteFile("files/leer.txt", []byte("hola\nmundo\n"), 0644)
+	if err != nil {
+		t.Fatalf("No se pudo crear el archivo temporal para la prueba: %v", err)
+	}
+
+	ReadFile(rec, store, map[string]string{"name": "leer.txt"}, "")
+
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
+	}
+	if rec.Body.String() != "hola\nmundo\n" {
+		t.Errorf("Contenido inesperado: '%s'", rec.Body.String())
+	}
+}
+
+// TestReadFile_NotFound prueba que un archivo inexistente retorne 404
+func TestReadFile_NotFound(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	ReadFile(rec, store, map[string]string{"name": "no_existe.txt"}, "")
+
+	if rec.Code != 404 {
+		t.Errorf("Esperado status 404, obtenido %d", rec.Code)
+	}
+}
+
+// TestReadFile_MissingNameParam prueba el caso de falta del parámetro 'name'
+func TestReadFile_MissingNameParam(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	ReadFile(rec, store, map[string]string{}, "")
+
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
+	}
+}
+
+// TestReadFile_Range prueba la descarga parcial con el encabezado Range
+func TestReadFile_Range(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	err := os.MkdirAll("files", 0755)
+	if err != nil {
+		t.Fatalf("No se pudo crear el directorio 'files' para la prueba: %v", err)
+	}
+	defer os.RemoveAll("files") // Limpiar después de la prueba
+	os.WriteFile("files/rango.txt", []byte("0123456789"), 0644)
+
+	tests := []struct {
+		header       string
+		code         int
+		body         string
+		contentRange string
+	}{
+		{"bytes=2-5", 206, "2345", "bytes 2-5/10"},
+		{"bytes=-3", 206, "789", "bytes 7-9/10"},
+		{"bytes=0-1,3-4", 200, "0123456789", ""},
+		{"bytes=20-", 416, "Rango fuera del archivo\n", "bytes */10"},
+	}
+	for _, tt := range tests {
+		rec := httpmsg.NewRecorder()
+		ReadFile(rec, store, map[string]string{"name": "rango.txt"}, tt.header)
+
+		if rec.Code != tt.code || rec.Body.String() != tt.body {
+			t.Errorf("%s: esperado %d '%s', obtenido %d '%s'", tt.header, tt.code, tt.body, rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("Content-Range") != tt.contentRange {
+			t.Errorf("%s: Content-Range inesperado '%s'", tt.header, rec.Header().Get("Content-Range"))
+		}
+		if rec.Header().Get("Accept-Ranges") != "bytes" {
+			t.Errorf("%s: falta Accept-Ranges", tt.header)
+		}
+	}
+}
@@ -1,21 +1,21 @@
 package handlers
 
 import (
-    "net"
+    "http-shared/httpmsg"
     "strings"
 )
 
 // /reverse?text=abc
 
-func Reverse(conn net.Conn, params map[string]string, sendResponse SendResponseFunc) {
+func Reverse(w httpmsg.ResponseWriter, params map[string]string) {
     text, ok := params["text"]
     if !ok || strings.TrimSpace(text) == ""{
-        sendResponse(conn, "400 Bad Request", "Falta el parámetro 'text'\n")
+        httpmsg.Text(w, 400, "Falta el parámetro 'text'\n")
         return
     }
 
     reversed := reverseString(text)
-    sendResponse(conn, "200 OK", reversed + "\n")
+    httpmsg.Text(w, 200, reversed + "\n")
 }
 
 func reverseString(s string) string {
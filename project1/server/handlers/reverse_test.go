@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"http-shared/httpmsg"
 	"testing"
 	"strings"
 )
@@ -24,28 +25,26 @@ func TestReverse_ValidInput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
 			params := map[string]string{"text": tt.inputText}
-			Reverse(mockConn, params, mockSendResponse)
+			Reverse(rec, params)
 
 			// Note: The Reverse handler itself checks for TrimSpace(text) == ""
 			// So an empty string or just spaces will result in a 400 Bad Request
 			if strings.TrimSpace(tt.inputText) == "" {
-				if testStatus != "400 Bad Request" {
-					t.Errorf("Esperado status '400 Bad Request' para '%s', obtenido '%s'", tt.inputText, testStatus)
+				if rec.Code != 400 {
+					t.Errorf("Esperado status 400 para '%s', obtenido %d", tt.inputText, rec.Code)
 				}
-				if testBody != "Falta el parámetro 'text'\n" {
-					t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", testBody)
+				if rec.Body.String() != "Falta el parámetro 'text'\n" {
+					t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", rec.Body.String())
 				}
 			} else {
-				if testStatus != "200 OK" {
-					t.Errorf("Esperado status '200 OK' para '%s', obtenido '%s'", tt.inputText, testStatus)
+				if rec.Code != 200 {
+					t.Errorf("Esperado status 200 para '%s', obtenido %d", tt.inputText, rec.Code)
 				}
-				if testBody != tt.expected {
-					t.Errorf("Esperado body '%s', obtenido '%s' para input '%s'", tt.expected, testBody, tt.inputText)
+				if rec.Body.String() != tt.expected {
+					t.Errorf("Esperado body '%s', obtenido '%s' para input '%s'", tt.expected, rec.Body.String(), tt.inputText)
 				}
 			}
 		})
@@ -54,18 +53,16 @@ func TestReverse_ValidInput(t *testing.T) {
 
 // TestReverse_MissingParam prueba el caso de falta del parámetro 'text'
 func TestReverse_MissingParam(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	params := map[string]string{} // Parámetros sin 'text'
-	Reverse(mockConn, params, mockSendResponse)
+	Reverse(rec, params)
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "Falta el parámetro 'text'\n" {
-		t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Falta el parámetro 'text'\n" {
+		t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
@@ -82,18 +79,16 @@ func TestReverse_EmptyOrWhitespaceText(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
 			params := map[string]string{"text": tt.inputText}
-			Reverse(mockConn, params, mockSendResponse)
+			Reverse(rec, params)
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != "Falta el parámetro 'text'\n" { // The handler returns this for empty/whitespace after TrimSpace
-				t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", testBody)
+			if rec.Body.String() != "Falta el parámetro 'text'\n" { // The handler returns this for empty/whitespace after TrimSpace
+				t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", rec.Body.String())
 			}
 		})
 	}
@@ -1,25 +1,29 @@
 package handlers
 
 import (
-	"net"
+	"context"
+	"http-shared/httpmsg"
 	"strconv"
 	"time"
 )
 
-func Simulate(conn net.Conn, seconds string, nombre string, sendResponse SendResponseFunc) {
+func Simulate(ctx context.Context, w httpmsg.ResponseWriter, seconds string, nombre string) {
 
 	secondsI, err := strconv.Atoi(seconds)
 	if err != nil || secondsI <= 0 {
-		sendResponse(conn, "400 Bad Request", "Seconds debe ser un numero valido, entero y positivo\n")
+		httpmsg.Text(w, 400, "Seconds debe ser un numero valido, entero y positivo\n")
 		return
 	}
 
-	time.Sleep(time.Duration(secondsI) * time.Second)
+	if err := esperar(ctx, time.Duration(secondsI)*time.Second); err != nil {
+		Aborted(w, err)
+		return
+	}
 
 	body := "Simulacion completada\n\n"
 	body += "Nombre de la tarea: " + nombre + "\n"
 	body += "Duracion: " + seconds + " segundos\n"
 	body += "Hora de finalizacion: " + time.Now().Format(time.RFC1123) + "\n"
 	
-	sendResponse(conn, "200 OK", body)
+	httpmsg.Text(w, 200, body)
 }
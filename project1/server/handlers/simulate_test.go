@@ -2,6 +2,8 @@
 package handlers
 
 import (
+	"context"
+	"http-shared/httpmsg"
 	"strings"
 	"testing"
 	"time"
@@ -9,9 +11,7 @@ import (
 
 // TestSimulate_ValidInput prueba un caso exitoso con entrada válida
 func TestSimulate_ValidInput(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	seconds := "1" // Simulación de 1 segundo
 	taskName := "MiTareaDeSimulacion"
@@ -19,13 +19,13 @@ func TestSimulate_ValidInput(t *testing.T) {
 	// Capturar el tiempo antes de llamar a Simulate
 	startTime := time.Now()
 
-	Simulate(mockConn, seconds, taskName, mockSendResponse)
+	Simulate(context.Background(), rec, seconds, taskName)
 
 	// Capturar el tiempo después de que Simulate ha terminado
 	endTime := time.Now()
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	// Verificar que la duración real de la ejecución es aproximadamente la esperada
@@ -38,19 +38,19 @@ func TestSimulate_ValidInput(t *testing.T) {
 	}
 
 	// Verificar el contenido del cuerpo de la respuesta
-	if !strings.Contains(testBody, "Simulacion completada\n\n") {
-		t.Errorf("El cuerpo de la respuesta no contiene el mensaje de completado.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Simulacion completada\n\n") {
+		t.Errorf("El cuerpo de la respuesta no contiene el mensaje de completado.\nObtenido:\n%s", rec.Body.String())
 	}
-	if !strings.Contains(testBody, "Nombre de la tarea: "+taskName+"\n") {
-		t.Errorf("El cuerpo de la respuesta no contiene el nombre de la tarea.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Nombre de la tarea: "+taskName+"\n") {
+		t.Errorf("El cuerpo de la respuesta no contiene el nombre de la tarea.\nObtenido:\n%s", rec.Body.String())
 	}
-	if !strings.Contains(testBody, "Duracion: "+seconds+" segundos\n") {
-		t.Errorf("El cuerpo de la respuesta no contiene la duración correcta.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Duracion: "+seconds+" segundos\n") {
+		t.Errorf("El cuerpo de la respuesta no contiene la duración correcta.\nObtenido:\n%s", rec.Body.String())
 	}
 
 	// Verificar el formato de la hora de finalización (no el valor exacto ya que es dinámico)
-	if !strings.Contains(testBody, "Hora de finalizacion:") {
-		t.Errorf("El cuerpo de la respuesta no contiene la hora de finalización.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Hora de finalizacion:") {
+		t.Errorf("El cuerpo de la respuesta no contiene la hora de finalización.\nObtenido:\n%s", rec.Body.String())
 	}
 	// Podrías intentar parsear la fecha y verificar que es reciente, pero es más complejo.
 	// Por ahora, con el string.Contains es suficiente para el formato.
@@ -71,17 +71,15 @@ func TestSimulate_InvalidSeconds(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
-			Simulate(mockConn, tt.seconds, "AnyTask", mockSendResponse)
+			Simulate(context.Background(), rec, tt.seconds, "AnyTask")
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, testBody)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, rec.Body.String())
 			}
 		})
 	}
@@ -91,40 +89,36 @@ func TestSimulate_InvalidSeconds(t *testing.T) {
 // Aunque la función Simulate no tiene validación explícita para 'nombre' vacío,
 // este test asegura que no cause un pánico y que el resultado incluya el nombre vacío.
 func TestSimulate_EmptyTaskName(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	seconds := "1"
 	taskName := "" // Nombre de tarea vacío
 
-	Simulate(mockConn, seconds, taskName, mockSendResponse)
+	Simulate(context.Background(), rec, seconds, taskName)
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	// Verificar que el cuerpo de la respuesta incluye el nombre vacío
-	if !strings.Contains(testBody, "Nombre de la tarea: \n") {
-		t.Errorf("El cuerpo de la respuesta no contiene el nombre de la tarea vacío.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Nombre de la tarea: \n") {
+		t.Errorf("El cuerpo de la respuesta no contiene el nombre de la tarea vacío.\nObtenido:\n%s", rec.Body.String())
 	}
 }
 
 // TestSimulate_LongDuration prueba con una duración más larga para asegurar que funcione
 func TestSimulate_LongDuration(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	seconds := "2" // Simulación de 2 segundos
 	taskName := "LongRunningTask"
 
 	startTime := time.Now()
-	Simulate(mockConn, seconds, taskName, mockSendResponse)
+	Simulate(context.Background(), rec, seconds, taskName)
 	endTime := time.Now()
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	duration := endTime.Sub(startTime)
@@ -133,10 +127,10 @@ func TestSimulate_LongDuration(t *testing.T) {
 		t.Errorf("Duración de la simulación inesperada. Esperado ~%s, obtenido %s", expectedDuration, duration)
 	}
 
-	if !strings.Contains(testBody, "Nombre de la tarea: "+taskName+"\n") {
-		t.Errorf("El cuerpo de la respuesta no contiene el nombre de la tarea.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Nombre de la tarea: "+taskName+"\n") {
+		t.Errorf("El cuerpo de la respuesta no contiene el nombre de la tarea.\nObtenido:\n%s", rec.Body.String())
 	}
-	if !strings.Contains(testBody, "Duracion: "+seconds+" segundos\n") {
-		t.Errorf("El cuerpo de la respuesta no contiene la duración correcta.\nObtenido:\n%s", testBody)
+	if !strings.Contains(rec.Body.String(), "Duracion: "+seconds+" segundos\n") {
+		t.Errorf("El cuerpo de la respuesta no contiene la duración correcta.\nObtenido:\n%s", rec.Body.String())
 	}
 }
\ No newline at end of file
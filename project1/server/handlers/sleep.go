@@ -1,22 +1,26 @@
 package handlers
 
 import (
-	"net"
+	"context"
+	"http-shared/httpmsg"
 	"strconv"
 	"time"
 )
 
-func Sleep(conn net.Conn, seconds string, sendResponse SendResponseFunc) {
+func Sleep(ctx context.Context, w httpmsg.ResponseWriter, seconds string) {
 	print("Simulate handler called\n")
 
 	secondsI, err := strconv.Atoi(seconds)
 	if err != nil || secondsI <= 0 {
-		sendResponse(conn, "400 Bad Request", "Seconds debe ser un numero valido, entero y postivo\n")
+		httpmsg.Text(w, 400, "Seconds debe ser un numero valido, entero y postivo\n")
 		return
 	}
 
-	time.Sleep(time.Duration(secondsI) * time.Second)
+	if err := esperar(ctx, time.Duration(secondsI)*time.Second); err != nil {
+		Aborted(w, err)
+		return
+	}
 
 	body := "Sleep realizado durante " + seconds + " segundos\n"
-	sendResponse(conn, "200 OK", body)
+	httpmsg.Text(w, 200, body)
 }
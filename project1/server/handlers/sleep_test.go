@@ -2,6 +2,8 @@
 package handlers
 
 import (
+	"context"
+	"http-shared/httpmsg"
 	"testing"
 	"time"
 )
@@ -9,23 +11,21 @@ import (
 
 // TestSleep_ValidInput prueba un caso exitoso con entrada válida
 func TestSleep_ValidInput(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	seconds := "1" // Dormir 1 segundo para la prueba
 
 	// Capturar el tiempo antes de llamar a Sleep
 	startTime := time.Now()
 
-	// Ahora, pasamos mockSendResponse directamente a la función Sleep
-	Sleep(mockConn, seconds, mockSendResponse)
+	// Ahora, pasamos el Recorder directamente a la función Sleep
+	Sleep(context.Background(), rec, seconds)
 
 	// Capturar el tiempo después de que Sleep ha terminado
 	endTime := time.Now()
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	// Verificar que la duración real de la ejecución es aproximadamente la esperada
@@ -38,8 +38,8 @@ func TestSleep_ValidInput(t *testing.T) {
 
 	// Verificar el contenido del cuerpo de la respuesta
 	expectedBody := "Sleep realizado durante " + seconds + " segundos\n"
-	if testBody != expectedBody {
-		t.Errorf("Cuerpo de la respuesta inesperado.\nEsperado:\n%sObtenido:\n%s", expectedBody, testBody)
+	if rec.Body.String() != expectedBody {
+		t.Errorf("Cuerpo de la respuesta inesperado.\nEsperado:\n%sObtenido:\n%s", expectedBody, rec.Body.String())
 	}
 }
 
@@ -58,18 +58,16 @@ func TestSleep_InvalidSeconds(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
-			// Ahora, pasamos mockSendResponse directamente a la función Sleep
-			Sleep(mockConn, tt.seconds, mockSendResponse)
+			// Ahora, pasamos el Recorder directamente a la función Sleep
+			Sleep(context.Background(), rec, tt.seconds)
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, testBody)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s'", tt.expected, rec.Body.String())
 			}
 		})
 	}
@@ -77,19 +75,17 @@ func TestSleep_InvalidSeconds(t *testing.T) {
 
 // TestSleep_LongDuration prueba con una duración más larga para asegurar que funcione
 func TestSleep_LongDuration(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	seconds := "2" // Dormir 2 segundos
 
 	startTime := time.Now()
-	// Ahora, pasamos mockSendResponse directamente a la función Sleep
-	Sleep(mockConn, seconds, mockSendResponse)
+	// Ahora, pasamos el Recorder directamente a la función Sleep
+	Sleep(context.Background(), rec, seconds)
 	endTime := time.Now()
 
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	duration := endTime.Sub(startTime)
@@ -99,7 +95,23 @@ func TestSleep_LongDuration(t *testing.T) {
 	}
 
 	expectedBody := "Sleep realizado durante " + seconds + " segundos\n"
-	if testBody != expectedBody {
-		t.Errorf("Cuerpo de la respuesta inesperado.\nEsperado:\n%sObtenido:\n%s", expectedBody, testBody)
+	if rec.Body.String() != expectedBody {
+		t.Errorf("Cuerpo de la respuesta inesperado.\nEsperado:\n%sObtenido:\n%s", expectedBody, rec.Body.String())
 	}
-}
\ No newline at end of file
+}
+
+// TestSleep_Plazo verifica que Sleep responda 504 cuando vence el contexto
+func TestSleep_Plazo(t *testing.T) {
+	rec := httpmsg.NewRecorder()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	Sleep(ctx, rec, "5")
+	if duration := time.Since(startTime); duration > time.Second {
+		t.Errorf("Sleep no se interrumpió con el plazo, tardó %s", duration)
+	}
+	if rec.Code != 504 {
+		t.Errorf("Esperado status 504, obtenido %d", rec.Code)
+	}
+}
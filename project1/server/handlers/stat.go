@@ -0,0 +1,40 @@
+package handlers
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "http-shared/httpmsg"
+    "http-shared/routes"
+    "io/fs"
+    "http-servidor/filestore"
+)
+
+// /stat?name=filename
+// Retorna tamaño, fecha de modificación y SHA-256 del archivo en JSON.
+
+func Stat(w httpmsg.ResponseWriter, store *filestore.Store, params map[string]string) {
+    name, ok := params["name"]
+    if !ok {
+        httpmsg.Text(w, 400, "Falta el parámetro 'name'\n")
+        return
+    }
+
+    data, info, err := store.ReadFile(name)
+    if errors.Is(err, fs.ErrNotExist) {
+        httpmsg.Text(w, 404, "El archivo no existe\n")
+        return
+    }
+    if err != nil {
+        fileError(w, err, "No se pudo leer el archivo\n")
+        return
+    }
+
+    sum := sha256.Sum256(data)
+    httpmsg.JSON(w, 200, routes.FileInfo{
+        Name:    name,
+        Size:    info.Size(),
+        ModTime: info.ModTime().UTC(),
+        SHA256:  hex.EncodeToString(sum[:]),
+    })
+}
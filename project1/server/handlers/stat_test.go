@@ -0,0 +1,52 @@
+// stat_test.go
+package handlers
+
+import (
+	"encoding/json"
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"os"
+	"testing"
+)
+
+// TestStat_Success prueba los datos de un archivo existente
+func TestStat_Success(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	err := os.MkdirAll("files", 0755)
+	if err != nil {
+		t.Fatalf("No se pudo crear el directorio 'files' para la prueba: %v", err)
+	}
+	defer os.RemoveAll("files") // Limpiar después de la prueba
+	os.WriteFile("files/datos.txt", []byte("hola"), 0644)
+
+	Stat(rec, store, map[string]string{"name": "datos.txt"})
+
+	if rec.Code != 200 {
+		t.Fatalf("Esperado status 200, obtenido %d", rec.Code)
+	}
+	var info routes.FileInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("JSON inválido: %v", err)
+	}
+	if info.Name != "datos.txt" || info.Size != 4 || info.ModTime.IsZero() {
+		t.Errorf("Datos inesperados: %+v", info)
+	}
+	if info.SHA256 != "b221d9dbb083a7f33428d7c2a3c3198ae925614d70210e28716ccaa7cd4ddb79" {
+		t.Errorf("SHA-256 inesperado: %s", info.SHA256)
+	}
+}
+
+// TestStat_NotFound prueba que un archivo inexistente retorne 404
+func TestStat_NotFound(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	Stat(rec, store, map[string]string{"name": "no_existe.txt"})
+
+	if rec.Code != 404 {
+		t.Errorf("Esperado status 404, obtenido %d", rec.Code)
+	}
+}
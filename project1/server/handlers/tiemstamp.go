@@ -1,13 +1,13 @@
 package handlers
 
 import (
-    "net"
+    "http-shared/httpmsg"
     "time"
 )
 
-func Timestamp(conn net.Conn, sendResponse SendResponseFunc) {
+func Timestamp(w httpmsg.ResponseWriter) {
     
     now := time.Now().Format(time.RFC3339)
 
-    sendResponse(conn, "200 OK", `{"timestamp":"`+now+`"}`+"\n")
+    httpmsg.JSON(w, 200, map[string]string{"timestamp": now})
 }
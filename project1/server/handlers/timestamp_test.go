@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"http-shared/httpmsg"
 	"encoding/json"
 	"testing"
 	"time"
@@ -9,29 +10,27 @@ import (
 
 // TestTimestamp_Success prueba que la función devuelve un timestamp válido en formato JSON.
 func TestTimestamp_Success(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	// Llamar a la función Timestamp
-	Timestamp(mockConn, mockSendResponse)
+	Timestamp(rec)
 
 	// Verificar el estado de la respuesta
-	if testStatus != "200 OK" {
-		t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 	}
 
 	// Verificar el cuerpo de la respuesta
 	// Debería ser un JSON con un campo "timestamp"
 	var response map[string]string
-	err := json.Unmarshal([]byte(testBody), &response)
+	err := json.Unmarshal([]byte(rec.Body.String()), &response)
 	if err != nil {
-		t.Fatalf("No se pudo parsear el cuerpo JSON de la respuesta: %v, cuerpo: %s", err, testBody)
+		t.Fatalf("No se pudo parsear el cuerpo JSON de la respuesta: %v, cuerpo: %s", err, rec.Body.String())
 	}
 
 	timestampStr, ok := response["timestamp"]
 	if !ok {
-		t.Errorf("El cuerpo JSON no contiene el campo 'timestamp'. Cuerpo: %s", testBody)
+		t.Errorf("El cuerpo JSON no contiene el campo 'timestamp'. Cuerpo: %s", rec.Body.String())
 	}
 
 	// Intentar parsear el timestamp para asegurar que tiene el formato correcto (RFC3339)
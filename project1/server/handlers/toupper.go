@@ -1,18 +1,18 @@
 package handlers
 
 import (
-    "net"
+    "http-shared/httpmsg"
     "strings"
 )
 
 // /toupper?text=abc
 
-func ToUpper(conn net.Conn, params map[string]string, sendResponse SendResponseFunc) {
+func ToUpper(w httpmsg.ResponseWriter, params map[string]string) {
     text, ok := params["text"]
     if !ok || strings.TrimSpace(text) == "" {
-        sendResponse(conn, "400 Bad Request", "Falta el parámetro 'text'\n")
+        httpmsg.Text(w, 400, "Falta el parámetro 'text'\n")
         return
     }
 
-    sendResponse(conn, "200 OK", strings.ToUpper(text) + "\n")
+    httpmsg.Text(w, 200, strings.ToUpper(text) + "\n")
 }
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"http-shared/httpmsg"
 	"testing"
 )
 
@@ -22,18 +23,16 @@ func TestToUpper_ValidInput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
 			params := map[string]string{"text": tt.inputText}
-			ToUpper(mockConn, params, mockSendResponse)
+			ToUpper(rec, params)
 
-			if testStatus != "200 OK" {
-				t.Errorf("Esperado status '200 OK', obtenido '%s'", testStatus)
+			if rec.Code != 200 {
+				t.Errorf("Esperado status 200, obtenido %d", rec.Code)
 			}
-			if testBody != tt.expected {
-				t.Errorf("Esperado body '%s', obtenido '%s' para input '%s'", tt.expected, testBody, tt.inputText)
+			if rec.Body.String() != tt.expected {
+				t.Errorf("Esperado body '%s', obtenido '%s' para input '%s'", tt.expected, rec.Body.String(), tt.inputText)
 			}
 		})
 	}
@@ -41,18 +40,16 @@ func TestToUpper_ValidInput(t *testing.T) {
 
 // TestToUpper_MissingParam prueba el caso de falta del parámetro 'text'.
 func TestToUpper_MissingParam(t *testing.T) {
-	mockConn := &MockConn{}
-	testStatus = ""
-	testBody = ""
+	rec := httpmsg.NewRecorder()
 
 	params := map[string]string{} // Parámetros sin 'text'
-	ToUpper(mockConn, params, mockSendResponse)
+	ToUpper(rec, params)
 
-	if testStatus != "400 Bad Request" {
-		t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 	}
-	if testBody != "Falta el parámetro 'text'\n" {
-		t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", testBody)
+	if rec.Body.String() != "Falta el parámetro 'text'\n" {
+		t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", rec.Body.String())
 	}
 }
 
@@ -69,18 +66,16 @@ func TestToUpper_EmptyOrWhitespaceText(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockConn := &MockConn{}
-			testStatus = ""
-			testBody = ""
+			rec := httpmsg.NewRecorder()
 
 			params := map[string]string{"text": tt.inputText}
-			ToUpper(mockConn, params, mockSendResponse)
+			ToUpper(rec, params)
 
-			if testStatus != "400 Bad Request" {
-				t.Errorf("Esperado status '400 Bad Request', obtenido '%s'", testStatus)
+			if rec.Code != 400 {
+				t.Errorf("Esperado status 400, obtenido %d", rec.Code)
 			}
-			if testBody != "Falta el parámetro 'text'\n" { // The handler returns this for empty/whitespace after TrimSpace
-				t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", testBody)
+			if rec.Body.String() != "Falta el parámetro 'text'\n" { // The handler returns this for empty/whitespace after TrimSpace
+				t.Errorf("Esperado body 'Falta el parámetro 'text'\\n', obtenido '%s'", rec.Body.String())
 			}
 		})
 	}
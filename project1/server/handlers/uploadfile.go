@@ -0,0 +1,24 @@
+package handlers
+
+import (
+    "http-shared/httpmsg"
+    "http-servidor/filestore"
+)
+
+// POST /uploadfile?name=filename con el contenido en el cuerpo.
+// La usa el dispatcher para copiar un archivo de una réplica a otra.
+
+func UploadFile(w httpmsg.ResponseWriter, store *filestore.Store, params map[string]string, body []byte) {
+    name, ok := params["name"]
+    if !ok {
+        httpmsg.Text(w, 400, "Falta el parámetro 'name'\n")
+        return
+    }
+
+    if err := store.WriteFile(name, body); err != nil {
+        fileError(w, err, "No se pudo guardar el archivo\n")
+        return
+    }
+
+    httpmsg.Text(w, 200, "Archivo guardado exitosamente\n")
+}
@@ -0,0 +1,42 @@
+// uploadfile_test.go
+package handlers
+
+import (
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
+	"os"
+	"testing"
+)
+
+// TestUploadFile_Success prueba que el cuerpo se guarde tal cual, aunque no exista files/
+func TestUploadFile_Success(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+	defer os.RemoveAll("files") // Limpiar después de la prueba
+
+	body := []byte("copia\nde una réplica\n")
+	UploadFile(rec, store, map[string]string{"name": "copia.txt"}, body)
+
+	if rec.Code != 200 {
+		t.Errorf("Esperado status 200, obtenido %d", rec.Code)
+	}
+	content, err := os.ReadFile("files/copia.txt")
+	if err != nil {
+		t.Fatalf("Error al leer el archivo subido: %v", err)
+	}
+	if string(content) != string(body) {
+		t.Errorf("Contenido inesperado: '%s'", content)
+	}
+}
+
+// TestUploadFile_MissingNameParam prueba el caso de falta del parámetro 'name'
+func TestUploadFile_MissingNameParam(t *testing.T) {
+	store := filestore.New("files", filestore.Quota{})
+	rec := httpmsg.NewRecorder()
+
+	UploadFile(rec, store, map[string]string{}, []byte("x"))
+
+	if rec.Code != 400 {
+		t.Errorf("Esperado status 400, obtenido %d", rec.Code)
+	}
+}
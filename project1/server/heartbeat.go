@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"http-shared/routes"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat arma el snapshot de carga que se envía al dispatcher y que también muestra /status
+func (s *Server) Heartbeat(url string) routes.Heartbeat {
+	s.Metrics.Mu.Lock()
+	uptime := time.Since(s.Metrics.TiempoInicio)
+	totalRequests := s.Metrics.TotalRequests
+	s.Metrics.Mu.Unlock()
+
+	hb := routes.Heartbeat{
+		URL:           url,
+		IntervalMs:    s.HeartbeatInterval.Milliseconds(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		TotalRequests: totalRequests,
+		InFlight:      atomic.LoadInt64(&s.inFlight),
+		Draining:      s.isDraining(),
+		Pools:         make(map[string]routes.PoolStats, len(s.CommandPools)),
+	}
+	for ruta, pool := range s.CommandPools {
+		stats := pool.Stats()
+		hb.Pools[ruta] = stats
+		hb.Busy += stats.Busy
+		hb.Queued += stats.Queued
+	}
+	return hb
+}
+
+// sendHeartbeats envía un heartbeat cada HeartbeatInterval hasta que termine el drenaje.
+// El dispatcher decide si el worker está vivo según los heartbeats que deja de recibir;
+// su respuesta indica si hay que volver a registrarse, ver checkRegistration.
+func (s *Server) sendHeartbeats(dispatcherURL, workerURL string) {
+	ticker := time.NewTicker(s.HeartbeatInterval)
+	defer ticker.Stop()
+
+	var seq uint64
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+		}
+
+		seq++
+		hb := s.Heartbeat(workerURL)
+		hb.Seq = seq
+		body, err := json.Marshal(hb)
+		if err != nil {
+			log.Printf("Error generando heartbeat: %v", err)
+			continue
+		}
+
+		resp, err := postToDispatcher(dispatcherURL, "/heartbeat", body)
+		if err != nil {
+			log.Printf("Error enviando heartbeat %d: %v", seq, err)
+			continue
+		}
+		if resp.StatusCode != 200 {
+			log.Printf("El dispatcher rechazó el heartbeat %d (código %d): %s", seq, resp.StatusCode, resp.Body)
+		}
+		s.checkRegistration(resp)
+	}
+}
@@ -0,0 +1,79 @@
+// heartbeat_test.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestHeartbeat_Carga verifica que el heartbeat refleje los workers ocupados y las solicitudes en cola
+func TestHeartbeat_Carga(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/sleep"})
+	server.CommandPools["/sleep"].Start()
+
+	// El pool de /sleep tiene 3 workers: 4 solicitudes dejan una en cola
+	for i := 0; i < 4; i++ {
+		go func() {
+			serve(t, server, "GET /sleep?seconds=1 HTTP/1.1\r\n\r\n")
+		}()
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	hb := server.Heartbeat("worker1:8080")
+	stats := hb.Pools["/sleep"]
+	if stats.Workers != 3 || stats.Busy != 3 || stats.Queued != 1 {
+		t.Errorf("Carga inesperada del pool: %+v", stats)
+	}
+	if hb.Busy != 3 || hb.Queued != 1 || hb.URL != "worker1:8080" || hb.IntervalMs != 2000 {
+		t.Errorf("Heartbeat inesperado: %+v", hb)
+	}
+}
+
+// TestSendHeartbeats verifica que los heartbeats lleguen al dispatcher con secuencia creciente
+func TestSendHeartbeats(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo escuchar: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan routes.Heartbeat, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			req, err := httpmsg.ReadRequest(bufio.NewReader(conn), httpmsg.DefaultLimits)
+			if err == nil && req.Path == "/heartbeat" {
+				body, _ := req.ReadBody()
+				var hb routes.Heartbeat
+				json.Unmarshal(body, &hb)
+				received <- hb
+			}
+			conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+
+	server := NewServer()
+	server.HeartbeatInterval = 20 * time.Millisecond
+	go server.sendHeartbeats("http://"+ln.Addr().String(), "worker1:8080")
+
+	for seq := uint64(1); seq <= 2; seq++ {
+		select {
+		case hb := <-received:
+			if hb.Seq != seq || hb.URL != "worker1:8080" || len(hb.Pools) != len(server.CommandPools) {
+				t.Errorf("Heartbeat inesperado: %+v", hb)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("No llegó el heartbeat %d", seq)
+		}
+	}
+	close(server.doneChan)
+}
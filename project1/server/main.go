@@ -1,15 +1,19 @@
 package main
 
 import (
-	"encoding/json"
-	"http-servidor/utils"
+	"context"
+	"http-servidor/filestore"
+	"http-shared/httpmsg"
+	"http-shared/routes"
 	"log"
 	"net"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 	"bufio"
-	"net/http"
+	"encoding/json"
 	"io"
 	"strings"
 	"math/rand" // Para generar números aleatorios
@@ -19,10 +23,13 @@ import (
 
 // CONSTANTES
 const PORT = ":8080"
+const WorkerVersion = "1.2.0"
+
+// Rutas que todo worker atiende aunque WORKER_ROUTES no las incluya
+var baseRoutes = map[string]bool{"/help": true, "/routes": true, "/status": true, "/ping": true, "/admin/pools": true}
 
 const (
-	maxRetries     = 3
-	retryInterval  = 5 * time.Second
+	IdleTimeout    = 30 * time.Second // Tiempo máximo de espera entre solicitudes de una conexión
 )
 
 var (
@@ -34,21 +41,41 @@ var (
 // Request
 type Request struct {
 	ID           int
-	Conn         net.Conn
+	Writer       httpmsg.ResponseWriter // Respuesta que arma el handler
+	Method       string
 	Ruta         string
-	Parametros   map[string]string
+	Parametros   map[string]string // Primer valor decodificado de cada parámetro
+	Query        httpmsg.Values    // Todos los valores decodificados, en orden
 	TiempoInicio time.Time
 	Listo        chan bool
-	Body		 string 
+	Body         string
+	Header       httpmsg.Header // Encabezados de la solicitud, por ejemplo Range
+	Handler      Handler        // Asignado según la tabla de rutas
+	Ctx          context.Context // Vence con X-Request-Deadline o se cancela si el cliente se va
+	Priority     Priority        // Clase en el pool de la ruta, ver priority.go
 }
 
 // Server
 type Server struct {
-	ServerId     int
-	CommandPools map[string]*WorkerPool
-	Metrics      *Metricas
-	listener     net.Listener  // Socket subyacente
-	doneChan     chan struct{} // Para shutdown
+	ServerId          int
+	CommandPools      map[string]*WorkerPool
+	Routes            *routes.Table      // Rutas publicadas en /help y /routes
+	Handlers          map[string]Handler // Handler por ruta
+	Metrics           *Metricas
+	Files             *filestore.Store // Carpeta de archivos del worker (FILES_ROOT)
+	HeartbeatInterval time.Duration // Cada cuánto se avisa al dispatcher que el worker sigue vivo
+	listener          net.Listener  // Socket subyacente
+	doneChan          chan struct{} // Para shutdown, se cierra al terminar el drenaje
+	draining          int32         // 1 mientras el worker se apaga
+	inFlight          int64         // Solicitudes en curso
+	APIKeys           APIKeys       // Clase de prioridad de cada API key (API_KEYS)
+	GracePeriod       time.Duration // Plazo de gracia del drenaje (SHUTDOWN_GRACE), DrainTimeout si es cero
+	RegisterRetry     time.Duration // Espera antes del primer reintento de registro, ver registration.go
+	epochMu           sync.Mutex
+	epoch             string        // Epoch del dispatcher en el último registro, vacío si no hay
+	reregister        chan struct{} // Pide a superviseRegistration que vuelva a registrar el worker
+	baseCtx           context.Context // Contexto de todas las solicitudes, se cancela si vence el drenaje
+	cancelAll         context.CancelFunc
 }
 
 // Metricas del servidor
@@ -59,31 +86,66 @@ type Metricas struct {
 	ActWorkers    int
 }
 
-// Funcion para inicializar el servidor
+// Funcion para inicializar el servidor con todas las rutas
 func NewServer() *Server {
-	return &Server{
-		ServerId: 1,
-		CommandPools: map[string]*WorkerPool{
-			"/help":       NewWorkerPool(2),
-			"/timestamp":  NewWorkerPool(2),
-			"/fibonacci":  NewWorkerPool(3),
-			"/reverse":    NewWorkerPool(2),
-			"/toupper":    NewWorkerPool(2),
-			"/hash":       NewWorkerPool(2),
-			"/random":     NewWorkerPool(2),
-			"/simulate":   NewWorkerPool(3),
-			"/sleep":      NewWorkerPool(3),
-			"/loadtest":   NewWorkerPool(3),
-			"/createfile": NewWorkerPool(3),
-			"/deletefile": NewWorkerPool(3),
-			"/ping":       NewWorkerPool(2),
-		},
+	return NewServerWithRoutes(nil)
+}
+
+// NewServerWithRoutes crea el servidor solo con las rutas indicadas (más las rutas base).
+// Con enabled vacío se habilitan todas. Los pools se crean a partir de la tabla de rutas.
+func NewServerWithRoutes(enabled []string) *Server {
+	s := &Server{
+		ServerId:     1,
+		CommandPools: make(map[string]*WorkerPool),
+		Handlers:     make(map[string]Handler),
 		Metrics: &Metricas{
 			TiempoInicio:  time.Now(),
 			TotalRequests: 0,
 			ActWorkers:    0,
 		},
-		doneChan: make(chan struct{}),
+		doneChan:          make(chan struct{}),
+		GracePeriod:       DrainTimeout,
+		RegisterRetry:     RegisterBaseDelay,
+		reregister:        make(chan struct{}, 1),
+		HeartbeatInterval: routes.DefaultHeartbeatInterval,
+		Files:             filestore.New(filestore.DefaultRoot, filestore.Quota{}),
+	}
+	s.baseCtx, s.cancelAll = context.WithCancel(context.Background())
+
+	allowed := make(map[string]bool)
+	for _, path := range enabled {
+		allowed[strings.TrimSpace(path)] = true
+	}
+
+	var table []routes.Route
+	for _, r := range workerRoutes(s) {
+		if len(allowed) > 0 && !allowed[r.Path] && !baseRoutes[r.Path] {
+			continue
+		}
+		table = append(table, r.Route)
+		s.Handlers[r.Path] = r.Handler
+		if r.PoolSize > 0 {
+			s.CommandPools[r.Path] = NewElasticPool(r.PoolSize, r.PoolSize*DefaultPoolGrowth)
+		}
+	}
+	s.Routes = routes.NewTable(table)
+	return s
+}
+
+// Capabilities arma el documento que se envía al dispatcher al registrarse.
+// La concurrencia máxima es la suma de los workers de todos los pools.
+func (s *Server) Capabilities(name, url string, labels map[string]string) routes.Capabilities {
+	maxConcurrency := 0
+	for _, r := range s.Routes.Routes() {
+		maxConcurrency += r.PoolSize
+	}
+	return routes.Capabilities{
+		URL:            url,
+		Name:           name,
+		Version:        WorkerVersion,
+		Labels:         labels,
+		MaxConcurrency: maxConcurrency,
+		Routes:         s.Routes.Routes(),
 	}
 }
 
@@ -98,8 +160,35 @@ func main() {
     workerURL := fmt.Sprintf("%s:%s", workerName, workerPort)
     dispatcherURL := getEnv("DISPATCHER_URL", "http://dispatcher:8080")
 
+    labels, err := routes.ParseLabels(os.Getenv("WORKER_LABELS"))
+    if err != nil {
+        log.Fatalf("WORKER_LABELS inválido: %v", err)
+    }
+
+    // WORKER_ROUTES limita las rutas del worker, por ejemplo "/createfile,/deletefile"
+    var enabled []string
+    if raw := os.Getenv("WORKER_ROUTES"); raw != "" {
+        enabled = strings.Split(raw, ",")
+    }
+    Server := NewServerWithRoutes(enabled)
+    Server.Files, err = filesFromEnv()
+    if err != nil {
+        log.Fatalf("Configuración de archivos inválida: %v", err)
+    }
+    if err := Server.configurePriorities(os.Getenv("API_KEYS"), os.Getenv("PRIORITY_AGING")); err != nil {
+        log.Fatalf("Configuración de prioridades inválida: %v", err)
+    }
+    if err := Server.configurePools(os.Getenv("POOL_SCALE_UP_WAIT"), os.Getenv("POOL_COOLDOWN")); err != nil {
+        log.Fatalf("Configuración de pools inválida: %v", err)
+    }
+    if err := Server.configureShutdown(os.Getenv("SHUTDOWN_GRACE")); err != nil {
+        log.Fatalf("Configuración de apagado inválida: %v", err)
+    }
+
     log.Printf("Iniciando %s en %s", workerName, workerURL)
-    go registerWithDispatcher(dispatcherURL, workerURL, workerName)
+    // El supervisor mantiene el registro aunque el dispatcher arranque después o se reinicie
+    go Server.superviseRegistration(dispatcherURL, Server.Capabilities(workerName, workerURL, labels))
+    go Server.sendHeartbeats(dispatcherURL, workerURL)
 
 	rand.Seed(time.Now().UnixNano())
 
@@ -108,7 +197,6 @@ func main() {
 		port = "8080" // Puerto por defecto
 	}
 
-	Server := NewServer()
 	log.Printf("Servidor iniciado en :%s", port)
 	for _, pool := range Server.CommandPools {
 		pool.Start()
@@ -121,13 +209,43 @@ func main() {
 	defer ln.Close()
 	log.Printf("Servidor escuchando en %s", PORT)
 
+	// Con SIGTERM (docker stop) o SIGINT el worker avisa al dispatcher y se drena.
+	// Una segunda señal durante el drenaje sale sin esperar.
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	drained := make(chan error, 1)
+	go func() {
+		sig := <-signals
+		log.Printf("Señal %v recibida", sig)
+		go func() {
+			sig := <-signals
+			log.Printf("Señal %v durante el drenaje, se sale sin esperar", sig)
+			os.Exit(2)
+		}()
+		drained <- Server.Drain(func() error { return deregisterFromDispatcher(dispatcherURL, workerURL) })
+	}()
+
+	Server.Serve(ln)
+	if err := <-drained; err != nil {
+		log.Printf("Worker %s detenido con solicitudes canceladas: %v", workerName, err)
+		os.Exit(1)
+	}
+	log.Printf("Worker %s detenido", workerName)
+}
+
+// Serve acepta conexiones hasta que el listener se cierre por el drenaje
+func (s *Server) Serve(ln net.Listener) {
+	s.listener = ln
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			if s.isDraining() {
+				return
+			}
 			log.Printf("Error aceptando conexión: %v", err)
 			continue
 		}
-		go handleConnection(conn, Server)
+		go handleConnection(conn, s)
 	}
 }
 
@@ -166,6 +284,27 @@ func getWorkerNumber() int {
 	log.Println("ADVERTENCIA: No se pudo determinar el número de worker, usando 1 como fallback")
 	return 1
 }
+// filesFromEnv arma la carpeta de archivos con FILES_ROOT, FILES_MAX_BYTES y
+// FILES_MAX_COUNT. Una cuota vacía o en cero no limita.
+func filesFromEnv() (*filestore.Store, error) {
+	var quota filestore.Quota
+	if raw := os.Getenv("FILES_MAX_BYTES"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("FILES_MAX_BYTES debe ser un entero no negativo: %q", raw)
+		}
+		quota.MaxBytes = n
+	}
+	if raw := os.Getenv("FILES_MAX_COUNT"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("FILES_MAX_COUNT debe ser un entero no negativo: %q", raw)
+		}
+		quota.MaxFiles = n
+	}
+	return filestore.New(getEnv("FILES_ROOT", filestore.DefaultRoot), quota), nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -173,170 +312,175 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-// Gestion las solicitudes que le llegan al servidor
-func handleConnectionOld(conn net.Conn, server *Server) {
+// Gestiona las solicitudes que le llegan al servidor. La conexión se mantiene
+// abierta (HTTP/1.1 keep-alive) hasta que el cliente pida cerrarla o pase IdleTimeout
+// sin recibir una nueva solicitud. Las solicitudes encadenadas (pipelining) se leen
+// del mismo bufio.Reader y se responden en el orden en que llegaron.
+func handleConnection(conn net.Conn, server *Server) {
 	defer conn.Close()
 
-	buffer := make([]byte, 1024)
-	n, err := conn.Read(buffer)
-	if err != nil {
-		log.Printf("Error leyendo: %v", err)
-		return
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(IdleTimeout))
+		req, err := httpmsg.ReadRequest(reader, httpmsg.DefaultLimits)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				log.Printf("Worker: Conexión inactiva por más de %s, cerrando", IdleTimeout)
+				return
+			}
+			if err != io.EOF {
+				log.Printf("Worker: Error leyendo la solicitud: %v", err)
+				w := httpmsg.NewWriter(conn, nil)
+				httpmsg.Text(w, httpmsg.StatusOf(err), err.Error())
+				w.Finish()
+			}
+			return
+		}
+		// El handler puede tardar más que IdleTimeout, el plazo solo aplica entre solicitudes
+		conn.SetReadDeadline(time.Time{})
+
+		w := httpmsg.NewWriter(conn, req)
+		if server.beginRequest() {
+			ctx, cancel := context.WithCancel(server.baseCtx)
+			stopWatch := func() {}
+			// Con cuerpo no se vigila la conexión: el handler todavía lo lee del mismo reader
+			if req.ContentLength == 0 && !req.Chunked {
+				stopWatch = watchPeer(conn, reader, cancel)
+			}
+			handleRequest(ctx, w, req, server)
+			stopWatch()
+			cancel()
+			server.endRequest()
+		} else {
+			rejectDraining(w)
+		}
+		if err := w.Finish(); err != nil {
+			log.Printf("Worker: Error enviando la respuesta: %v", err)
+			return
+		}
+
+		// Lo que el handler no leyó del cuerpo se descarta para llegar a la siguiente solicitud
+		if err := req.Discard(); err != nil {
+			log.Printf("Worker: Error descartando el cuerpo: %v", err)
+			return
+		}
+		if !w.KeepAlive() {
+			return
+		}
 	}
+}
 
-	request := string(buffer[:n])
-	method, path := utils.ParseRequestLine(request)
+// watchPeer cancela la solicitud en curso si el cliente cierra la conexión. Usa Peek
+// para no consumir la siguiente solicitud si ya llegó. Hay que llamar a stop antes
+// de volver a leer de reader.
+func watchPeer(conn net.Conn, reader *bufio.Reader, cancel context.CancelFunc) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := reader.Peek(1); err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return
+			}
+			log.Printf("Worker: El cliente cerró la conexión, se cancela la solicitud")
+			cancel()
+		}
+	}()
+	return func() {
+		conn.SetReadDeadline(time.Now())
+		<-done
+		conn.SetReadDeadline(time.Time{})
+	}
+}
 
-	if method != "GET" || method != "POST" {
-		utils.SendResponse(conn, "405 Method Not Allowed", "Solo se permite GET y POST")
+// Procesa una solicitud ya parseada y arma su respuesta en w. ctx se cancela si el
+// cliente se va y además vence en el plazo de X-Request-Deadline.
+func handleRequest(ctx context.Context, w httpmsg.ResponseWriter, req *httpmsg.Request, server *Server) {
+	method := req.Method
+	route, query, err := httpmsg.ParseRoute(req.Target)
+	if err != nil {
+		httpmsg.Text(w, httpmsg.StatusOf(err), err.Error())
 		return
 	}
-
-	route, params := utils.ParseRoute(path)
+	params := query.Map()
 
 	// Se incrementa el contador de solicitudes
 	server.Metrics.Mu.Lock()
 	server.Metrics.TotalRequests++
+	requestID := server.Metrics.TotalRequests
 	server.Metrics.Mu.Unlock()
 
-	newRequest := Request{
-		ID:           server.Metrics.TotalRequests + 1,
-		Conn:         conn,
-		Ruta:         route,
-		Parametros:   params,
-		TiempoInicio: time.Now(),
-		Listo:        make(chan bool),
-	}
-
-	print("Request ID: ", newRequest.ID, " Ruta: ", newRequest.Ruta, "\n")
-
-	if route == "/status" {
-
-		serverStatus(conn, server)
-
-	} else if pool, exists := server.CommandPools[route]; exists {
-		// Enviar la solicitud al pool correspondiente
-		pool.RequestChan <- newRequest
+	// Se conserva el ID que asigna el dispatcher para poder seguir la tarea en ambos logs
+	if id := req.Header.Get("X-Request-ID"); id != "" {
+		w.Header().Set("X-Request-ID", id)
 	} else {
-		// Ruta no encontrada
-		utils.SendResponse(conn, "404 Not Found", "Ruta no encontrada")
+		w.Header().Set("X-Request-ID", strconv.Itoa(requestID))
 	}
-	newRequest.Listo <- true
-}
-
-// NUEVA FUNCIÓN PARA MANEJAR POST /countchunk y otros comandos
-func handleConnection(conn net.Conn, server *Server) {
-	defer conn.Close()
-	log.Printf("Worker: Handle connection started.")
 
-	reader := bufio.NewReader(conn)
+	log.Printf("Worker: Request ID: %d Method: %s Route: %s Params: %v", requestID, method, route, params)
 
-	requestLineWithCRLF, err := reader.ReadString('\n')
+	// La tabla de rutas decide si la ruta existe, si acepta el método y si los parámetros son válidos
+	info, err := server.Routes.Check(method, route, query)
 	if err != nil {
-		if err != io.EOF {
-			log.Printf("Worker: Error leyendo request line: %v", err)
-			utils.SendResponse(conn, "400 Bad Request", "Error leyendo la solicitud HTTP")
+		if httpmsg.StatusOf(err) == 405 {
+			w.Header().Set("Allow", info.Allow())
 		}
+		httpmsg.Text(w, httpmsg.StatusOf(err), err.Error())
 		return
 	}
 
-	requestLine := strings.TrimSpace(requestLineWithCRLF)
-
-	log.Printf("Worker: Parsed Request Line (trimmed): '%s'", requestLine) // <-- Log crucial
+	ctx, cancel, err := httpmsg.WithDeadline(ctx, req.Header)
+	if err != nil {
+		httpmsg.Text(w, httpmsg.StatusOf(err), err.Error())
+		return
+	}
+	defer cancel()
 
-	// Tu utils.ParseRequestLine devuelve 2 valores, así que se asigna a 2.
-	method, pathAndQuery := utils.ParseRequestLine(requestLine)
-	route, params := utils.ParseRoute(pathAndQuery)
+	priority, err := server.priorityOf(req.Header)
+	if err != nil {
+		httpmsg.Text(w, httpmsg.StatusOf(err), err.Error())
+		return
+	}
 
-	// Leer los encabezados HTTP
-	headers := make(map[string]string)
-	log.Printf("Worker: Starting header read loop...") // Log para depuración
-	for {
-		line, err := reader.ReadString('\n')
+	body := ""
+	if method == "POST" {
+		data, err := req.ReadBody()
 		if err != nil {
-			log.Printf("Worker: Error durante lectura de header: %v", err)
-			utils.SendResponse(conn, "400 Bad Request", "Error leyendo encabezados HTTP")
+			log.Printf("Worker: Error leyendo el cuerpo: %v", err)
+			httpmsg.Text(w, httpmsg.StatusOf(err), err.Error())
 			return
 		}
-
-		trimmedLine := strings.TrimSpace(line) // Esto elimina \r y \n
-		log.Printf("Worker: Leído Header Line: '%s' (Trimmed: '%s')", strings.ReplaceAll(line, "\n", "\\n"), trimmedLine) // <-- Log crucial
-
-		if trimmedLine == "" { // Si es una línea vacía después de trim, es el fin de los encabezados
-			log.Printf("Worker: Línea vacía de encabezado encontrada, terminando lectura de headers.") // Log para depuración
-			break
-		}
-
-		parts := strings.SplitN(trimmedLine, ":", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			headers[strings.ToLower(key)] = value // Guardar en minúsculas para fácil acceso
-			log.Printf("Worker: Encabezado parseado: %s -> %s", key, value) // Log para depuración
-		} else {
-			log.Printf("Worker: No se pudo parsear línea de encabezado: '%s'", trimmedLine) // Log para depuración
-		}
-	}
-	log.Printf("Worker: Mapa final de encabezados: %v", headers) // <-- Log crucial
-
-	// Se incrementa el contador de solicitudes
-	server.Metrics.Mu.Lock()
-	server.Metrics.TotalRequests++
-	server.Metrics.Mu.Unlock()
-
-	log.Printf("Worker: Request ID: %d Method: %s Route: %s Params: %v", server.Metrics.TotalRequests, method, route, params)
-
-	// Lógica para manejar POST /countchunk
-	if method == "POST" && route == "/countchunk" {
-		log.Printf("Worker: Received POST request for /countchunk. Delegando a handleCountChunkInWorker.")
-		handleCountChunkInWorker(conn, headers, reader, server)
-		return // Termina el manejo de la conexión aquí
-	}
-
-	// Lógica para manejar GET /calculatepi
-	if method == "GET" && route == "/calculatepi" {
-		log.Printf("Worker: Received GET request for /calculatepi with params: %v. Delegando a handleCalculatePiInWorker.", params)
-		handleCalculatePiInWorker(conn, params, server)
-		return // Termina el manejo de la conexión aquí
+		body = string(data)
 	}
 
-	// Lógica para otros métodos y rutas (ej. GET /ping, GET /timestamp)
-	if method != "GET" { // Ahora, si no es POST /countchunk o GET /calculatepi, solo permitimos GET
-		utils.SendResponse(conn, "405 Method Not Allowed", "Método no permitido para esta ruta")
-		return
-	}
-
-	// Manejo para GET (ping, timestamp, etc.)
 	newRequest := Request{
-		ID:           server.Metrics.TotalRequests, // Usa el contador actualizado
-		Conn:         conn,
+		ID:           requestID,
+		Writer:       w,
+		Method:       req.Method,
 		Ruta:         route,
 		Parametros:   params,
+		Query:        query,
 		TiempoInicio: time.Now(),
 		Listo:        make(chan bool),
-		Body:         "", // No hay cuerpo para solicitudes GET
+		Body:         body,
+		Header:       req.Header,
+		Handler:      server.Handlers[route],
+		Ctx:          ctx,
+		Priority:     priority,
 	}
 
-	log.Printf("Worker: Request ID: %d Ruta: %s (delegando a CommandPool)", newRequest.ID, newRequest.Ruta)
-
-	if route == "/status" {
-		serverStatus(conn, server) // Asume que serverStatus existe y envía la respuesta
-	} else if route == "/ping" { // Manejar /ping directamente si no está en CommandPools
-		utils.SendResponse(conn, "200 OK", "pong")
-	} else if pool, exists := server.CommandPools[route]; exists {
-		pool.RequestChan <- newRequest
-		// Esperar a que la tarea esté lista si tu sistema de pools lo requiere
-		<-newRequest.Listo // ¡Esto es crucial si quieres que el dispatcher reciba una respuesta!
-	} else {
-		utils.SendResponse(conn, "404 Not Found", "Ruta no encontrada")
+	pool, pooled := server.CommandPools[route]
+	if info.PoolSize == 0 || !pooled {
+		// Rutas livianas como /status se atienden en la goroutine de la conexión
+		HandleRequest(newRequest)
+		return
 	}
-	// Si el canal Listo ya es manejado por el pool (cerrado o enviado), esto podría causar pánico.
-	// Solo descomentar si el pool espera que TÚ cierres el canal.
-	// newRequest.Listo <- true
+	pool.Submit(newRequest)
+	// Esperar a que el worker del pool escriba la respuesta antes de leer la siguiente solicitud
+	<-newRequest.Listo
 }
 
 // Genera el estado del servidor y retornar la respuesta en formato JSON
-func serverStatus(conn net.Conn, s *Server) {
+func serverStatus(w httpmsg.ResponseWriter, s *Server) {
 	s.Metrics.Mu.Lock()
 	uptime := time.Since(s.Metrics.TiempoInicio).Truncate(time.Second).String()
 	totalRequests := s.Metrics.TotalRequests
@@ -348,7 +492,7 @@ func serverStatus(conn net.Conn, s *Server) {
 
 	for ruta, pool := range s.CommandPools {
 		var workers []map[string]interface{}
-		for _, w := range pool.Workers {
+		for _, w := range pool.snapshot() {
 			task := "ninguna"
 			if w.ReqActual != nil {
 				task = w.ReqActual.Ruta
@@ -363,178 +507,122 @@ func serverStatus(conn net.Conn, s *Server) {
 		workersByCommand[ruta] = workers
 	}
 
-	// Estado global
+	// Estado global, con la misma carga que se envía en el heartbeat
+	load := s.Heartbeat("")
 	data := map[string]interface{}{
 		"uptime":            uptime,
 		"main_pid":          s.ServerId,
 		"total_connections": totalRequests,
 		"total_workers":     totalWorkers,
 		"workers":           workersByCommand,
+		"pools":             load.Pools,
+		"busy":              load.Busy,
+		"queued":            load.Queued,
+		"in_flight":         load.InFlight,
+		"draining":          load.Draining,
 	}
-
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		utils.SendResponse(conn, "500 Internal Server Error", "Error generando JSON")
-		return
+	if usage, err := s.Files.Usage(); err == nil {
+		data["files"] = usage
 	}
 
-	utils.SendJSON(conn, "200 OK", jsonData)
+	httpmsg.JSON(w, 200, data)
 }
 
-// handleCountChunkInWorker: Función para procesar el chunk de conteo de palabras
-func handleCountChunkInWorker(conn net.Conn, headers map[string]string, reader *bufio.Reader, server *Server) {
-	contentLengthStr, ok := headers["content-length"] // Los headers los parseamos a minúsculas
-	var contentLength int
-	if ok {
-		var err error
-		contentLength, err = strconv.Atoi(contentLengthStr)
-		if err != nil {
-			utils.SendResponse(conn, "400 Bad Request", "Content-Length inválido")
-			log.Printf("Worker: Error: Content-Length inválido: %v", err)
-			return
-		}
-	} else {
-		log.Println("Worker Advertencia: No Content-Length header. Leyendo hasta EOF/timeout.")
-		// Para POST, es muy recomendable tener Content-Length. Si no está presente,
-		// leer hasta EOF puede ser problemático si la conexión no se cierra inmediatamente.
+// adminPools atiende GET /admin/pools, que lista la carga de los pools (o solo el de
+// route), y POST /admin/pools?route=..., que cambia el tamaño del pool de esa ruta (size)
+// y sus límites (min y max) y responde su nuevo estado.
+func adminPools(w httpmsg.ResponseWriter, s *Server, method string, params map[string]string) {
+	route := params["route"]
+	change := params["size"] != "" || params["min"] != "" || params["max"] != ""
+	if method != "POST" && change {
+		httpmsg.Text(w, 405, "Use POST para cambiar el tamaño o los límites de un pool")
+		return
 	}
-
-	var contentBuilder strings.Builder
-	var bytesRead int
-	buffer := make([]byte, 4096) // Buffer para leer chunks del cuerpo
-	for {
-		n, err := reader.Read(buffer)
-		if n > 0 {
-			contentBuilder.Write(buffer[:n])
-			bytesRead += n
-		}
-		// Condición de salida: si se ha leído todo el Content-Length o se llegó al EOF
-		if err == io.EOF || (contentLength > 0 && bytesRead >= contentLength) {
-			break
-		}
-		if err != nil {
-			utils.SendResponse(conn, "500 Internal Server Error", "Error leyendo el cuerpo del archivo")
-			log.Printf("Worker: Error leyendo el cuerpo del archivo: %v", err)
+	if route == "" {
+		if method == "POST" {
+			httpmsg.Text(w, 400, "Falta el parámetro 'route'")
 			return
 		}
+		pools := make(map[string]routes.PoolStats, len(s.CommandPools))
+		for ruta, pool := range s.CommandPools {
+			pools[ruta] = pool.Stats()
+		}
+		httpmsg.JSON(w, 200, pools)
+		return
 	}
-	chunkContent := contentBuilder.String()
-	log.Printf("Worker: Chunk recibido para conteo, tamaño: %d bytes. Contenido (primeros 100 chars): '%s'", len(chunkContent), chunkContent[:min(len(chunkContent), 100)]) // <-- Log crucial
-
-	wordCount := countWords(chunkContent) // Asume que countWords existe y es correcto
-	log.Printf("Worker: Conteo de palabras para chunk: %d", wordCount)
-
-	utils.SendResponse(conn, "200 OK", fmt.Sprintf("%d", wordCount))
-}
-
-// Función auxiliar para contar palabras 
-func countWords(text string) int {
-	if len(strings.TrimSpace(text)) == 0 {
-		return 0
-	}
-	words := strings.Fields(text)
-	return len(words)
-}
 
-// handleCalculatePiInWorker: Función para calcular Pi usando Monte Carlo
-func handleCalculatePiInWorker(conn net.Conn, params map[string]string, server *Server) {
-	iterationsStr, ok := params["iterations"]
+	pool, ok := s.CommandPools[route]
 	if !ok {
-		utils.SendResponse(conn, "400 Bad Request", "Parámetro 'iterations' requerido")
+		httpmsg.Text(w, 404, fmt.Sprintf("La ruta %s no tiene pool", route))
 		return
 	}
-
-	iterations, err := strconv.Atoi(iterationsStr)
-	if err != nil || iterations <= 0 {
-		utils.SendResponse(conn, "400 Bad Request", "Parámetro 'iterations' debe ser un número entero positivo")
+	if method != "POST" {
+		httpmsg.JSON(w, 200, pool.Stats())
 		return
 	}
+	// La tabla de rutas ya validó que sean enteros positivos; los que faltan quedan en 0
+	size, _ := strconv.Atoi(params["size"])
+	min, _ := strconv.Atoi(params["min"])
+	max, _ := strconv.Atoi(params["max"])
+	if err := pool.Resize(size, min, max); err != nil {
+		httpmsg.Text(w, 400, err.Error())
+		return
+	}
+	log.Printf("Worker: Pool %s ajustado a %d workers (min %d, max %d)", route, pool.Stats().Workers, pool.Stats().Min, pool.Stats().Max)
+	httpmsg.JSON(w, 200, pool.Stats())
+}
 
-	log.Printf("Worker: Calculando Pi con %d iteraciones...", iterations)
-
-	pointsInCircle := 0
-	// `rand.Float64()` genera un float64 pseudoaleatorio en [0.0, 1.0)
-	for i := 0; i < iterations; i++ {
-		x := rand.Float64()
-		y := rand.Float64()
-		if (x*x + y*y) <= 1.0 { // Si el punto cae dentro del cuarto de círculo (radio 1)
-			pointsInCircle++
-		}
+// Registra el worker en el dispatcher con POST /suscribir enviando sus capacidades en JSON.
+// Hace un solo intento; los reintentos los maneja superviseRegistration.
+func registerWithDispatcher(dispatcherURL string, caps []byte) (routes.Registration, error) {
+	var reg routes.Registration
+	resp, err := postToDispatcher(dispatcherURL, "/suscribir", caps)
+	if err != nil {
+		return reg, err
 	}
+	if resp.StatusCode != 200 {
+		return reg, fmt.Errorf("el dispatcher respondió %d: %s", resp.StatusCode, resp.Body)
+	}
+	if err := json.Unmarshal(resp.Body, &reg); err != nil {
+		return reg, fmt.Errorf("respuesta de registro inválida: %v", err)
+	}
+	return reg, nil
+}
 
-	log.Printf("Worker: %d puntos dentro del círculo de %d iteraciones.", pointsInCircle, iterations)
-	utils.SendResponse(conn, "200 OK", fmt.Sprintf("%d", pointsInCircle)) // Devuelve solo el conteo
+// Avisa al dispatcher que el worker se está drenando para que deje de enviarle tareas
+func deregisterFromDispatcher(dispatcherURL, workerURL string) error {
+	query := httpmsg.Values{}
+	query.Set("url", workerURL)
+	resp, err := postToDispatcher(dispatcherURL, "/desuscribir?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("el dispatcher respondió %d: %s", resp.StatusCode, resp.Body)
+	}
+	log.Printf("Dispatcher notificado del drenaje: %s", resp.Body)
+	return nil
 }
 
-func registerWithDispatcher(dispatcherURL, workerURL string, workerName string) {
-
-	
-	cleanWorkerURL := strings.ReplaceAll(workerURL, "%3A", ":")
-    cleanWorkerURL = strings.ReplaceAll(cleanWorkerURL, "%2F", "/")
-	for i := 0; i < maxRetries; i++ {
-		// Construir la URL de registro
-		registrationURL := fmt.Sprintf("%s/suscribir?url=%s", dispatcherURL, cleanWorkerURL)
-		log.Printf("URL de registro: %s", registrationURL)
-		// Crear solicitud HTTP GET con parámetros
-		req, err := http.NewRequest("GET", registrationURL, nil)
-		if err != nil {
-			log.Printf("Error creando solicitud de registro: %v", err)
-			time.Sleep(retryInterval)
-			continue
-		}
-		
-		// Añadir parámetro URL como query parameter
-		q := req.URL.Query()
-		q.Add("url", workerURL)
-		req.URL.RawQuery = q.Encode()
-		
-		// Configurar headers como en sendToWorker
-		req.Header.Set("Host", dispatcherURL)
-		req.Header.Set("X-Worker-Registration", "true")
-		req.Header.Set("X-Worker-URL", workerURL)
-		
-		// Configurar timeout para la solicitud
-		client := &http.Client{
-			Timeout: 10 * time.Second,
-		}
-		
-		// Enviar solicitud
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("Error enviando solicitud de registro (intento %d/%d): %v", i+1, maxRetries, err)
-			time.Sleep(retryInterval)
-			continue
-		}
-		defer resp.Body.Close()
-		
-		// Procesar respuesta como en sendToWorker
-		var responseBuilder strings.Builder
-		responseBuilder.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", resp.StatusCode, resp.Status))
-		
-		for k, v := range resp.Header {
-			responseBuilder.WriteString(fmt.Sprintf("%s: %s\r\n", k, strings.Join(v, ", ")))
-		}
-		responseBuilder.WriteString("\r\n")
-		
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error leyendo respuesta: %v", err)
-			continue
-		}
-		responseBuilder.Write(body)
-		
-		fullResponse := responseBuilder.String()
-		
-		// Verificar respuesta
-		if resp.StatusCode == http.StatusOK {
-			log.Printf("Registrado exitosamente en el dispatcher como %s", workerURL)
-			log.Printf("Respuesta completa:\n%s", fullResponse)
-			return
-		}
-		
-		log.Printf("Respuesta inesperada del dispatcher (código %d):\n%s", resp.StatusCode, fullResponse)
-		time.Sleep(retryInterval)
+// postToDispatcher envía un POST al dispatcher en una conexión nueva y lee la respuesta
+func postToDispatcher(dispatcherURL, target string, body []byte) (*httpmsg.Response, error) {
+	addr := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(dispatcherURL, "http://"), "https://"), "/")
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
 	}
-	
-	log.Printf("No se pudo registrar con el dispatcher después de %d intentos", maxRetries)
-}
\ No newline at end of file
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	header := httpmsg.Header{}
+	header.Set("Host", addr)
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	header.Set("X-Worker-Registration", "true")
+	header.Set("Connection", "close")
+	if err := httpmsg.WriteRequest(conn, "POST", target, header, body); err != nil {
+		return nil, err
+	}
+	return httpmsg.ReadResponse(bufio.NewReader(conn), httpmsg.DefaultLimits)
+}
@@ -0,0 +1,216 @@
+// main_test.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHandleConnection_Pipelining envía dos solicitudes seguidas por la misma conexión
+// y verifica que se respondan en orden y que la conexión se cierre con "Connection: close"
+func TestHandleConnection_Pipelining(t *testing.T) {
+	server := NewServer()
+	for _, pool := range server.CommandPools {
+		pool.Start()
+	}
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	go handleConnection(conn, server)
+
+	go func() {
+		client.Write([]byte("GET /reverse?text=abc HTTP/1.1\r\nHost: worker1\r\n\r\n" +
+			"GET /toupper?text=hola%20mundo HTTP/1.1\r\nHost: worker1\r\nConnection: close\r\n\r\n"))
+	}()
+
+	reader := bufio.NewReader(client)
+	expected := []string{"cba\n", "HOLA MUNDO\n"}
+	for i, body := range expected {
+		resp, err := httpmsg.ReadResponse(reader, httpmsg.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Error leyendo la respuesta %d: %v", i+1, err)
+		}
+		if resp.StatusCode != 200 || string(resp.Body) != body {
+			t.Errorf("Respuesta %d inesperada: %d %q", i+1, resp.StatusCode, resp.Body)
+		}
+	}
+
+	// Después de "Connection: close" el servidor debe cerrar la conexión
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Errorf("Se esperaba que el servidor cerrara la conexión, obtenido %v", err)
+	}
+}
+
+// TestHandleConnection_HTTP10 verifica que una solicitud HTTP/1.0 sin keep-alive cierre la conexión
+func TestHandleConnection_HTTP10(t *testing.T) {
+	server := NewServer()
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	go handleConnection(conn, server)
+
+	go client.Write([]byte("GET /noexiste HTTP/1.0\r\n\r\n"))
+
+	reader := bufio.NewReader(client)
+	resp, err := httpmsg.ReadResponse(reader, httpmsg.DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error leyendo la respuesta: %v", err)
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("Esperado 404, obtenido %d", resp.StatusCode)
+	}
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Errorf("Se esperaba que el servidor cerrara la conexión, obtenido %v", err)
+	}
+}
+
+// serve arma la solicitud cruda y la procesa con handleRequest sobre un Recorder
+func serve(t *testing.T, server *Server, raw string) *httpmsg.Recorder {
+	req, err := httpmsg.ReadRequest(bufio.NewReader(strings.NewReader(raw)), httpmsg.DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error leyendo la solicitud: %v", err)
+	}
+	rec := httpmsg.NewRecorder()
+	handleRequest(context.Background(), rec, req, server)
+	return rec
+}
+
+// TestHandleRequest_TablaDeRutas verifica las respuestas que genera la tabla de rutas
+func TestHandleRequest_TablaDeRutas(t *testing.T) {
+	server := NewServer()
+	for _, pool := range server.CommandPools {
+		pool.Start()
+	}
+
+	tests := []struct {
+		name   string
+		raw    string
+		status int
+		body   string
+	}{
+		{"RutaInexistente", "GET /noexiste HTTP/1.1\r\n\r\n", 404, "Ruta no encontrada"},
+		{"MetodoNoPermitido", "POST /fibonacci?num=3 HTTP/1.1\r\nContent-Length: 0\r\n\r\n", 405, "use GET"},
+		{"ParametroFaltante", "GET /fibonacci HTTP/1.1\r\n\r\n", 400, "'num' requerido"},
+		{"ParametroFueraDeRango", "GET /random?count=0&min=1&max=5 HTTP/1.1\r\n\r\n", 400, "'count' debe ser mayor o igual a 1"},
+		{"Valida", "GET /fibonacci?num=10 HTTP/1.1\r\n\r\n", 200, "55"},
+		{"Map", "POST /map?fn=words HTTP/1.1\r\nContent-Length: 11\r\n\r\nhola a todo", 200, `"words": 3`},
+		{"Help", "GET /help HTTP/1.1\r\n\r\n", 200, "/fibonacci?num=0.."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, server, tt.raw)
+			if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("Esperado %d %q, obtenido %d %q", tt.status, tt.body, rec.Code, rec.Body.String())
+			}
+			if tt.status == 405 && rec.Header().Get("Allow") != "GET" {
+				t.Errorf("Esperado Allow: GET, obtenido %q", rec.Header().Get("Allow"))
+			}
+		})
+	}
+}
+
+// TestHandleRequest_Routes verifica que /routes publique la tabla con pools y parámetros
+func TestHandleRequest_Routes(t *testing.T) {
+	server := NewServer()
+	rec := serve(t, server, "GET /routes HTTP/1.1\r\n\r\n")
+
+	var list []routes.Route
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("JSON inválido: %v", err)
+	}
+	table := routes.NewTable(list)
+	if table.Len() != server.Routes.Len() {
+		t.Errorf("Esperadas %d rutas, obtenidas %d", server.Routes.Len(), table.Len())
+	}
+	r, ok := table.Lookup("/fibonacci")
+	if !ok || r.PoolSize != 3 || len(r.Params) != 1 || *r.Params[0].Min != 0 {
+		t.Errorf("Ruta /fibonacci inesperada: %+v", r)
+	}
+	if len(server.CommandPools) != table.Len()-3 {
+		t.Errorf("Solo /routes, /status y /admin/pools deben atenderse sin pool, pools: %d", len(server.CommandPools))
+	}
+}
+
+// TestNewServerWithRoutes verifica que WORKER_ROUTES limite las rutas y las capacidades publicadas
+func TestNewServerWithRoutes(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/createfile", "/deletefile"})
+
+	caps := server.Capabilities("worker2", "worker2:8080", map[string]string{"files": "true"})
+	if err := caps.Validate(); err != nil {
+		t.Fatalf("Capacidades inválidas: %v", err)
+	}
+	if _, ok := server.Routes.Lookup("/fibonacci"); ok {
+		t.Errorf("/fibonacci no debería estar habilitada")
+	}
+	for _, path := range []string{"/createfile", "/deletefile", "/help", "/ping", "/routes", "/status"} {
+		if _, ok := server.Routes.Lookup(path); !ok {
+			t.Errorf("Falta la ruta %s", path)
+		}
+	}
+	// createfile(3) + deletefile(3) + help(2) + ping(2)
+	if caps.MaxConcurrency != 10 || len(server.CommandPools) != 4 {
+		t.Errorf("Esperada concurrencia 10 y 4 pools, obtenido %d y %d", caps.MaxConcurrency, len(server.CommandPools))
+	}
+	if caps.Version != WorkerVersion || caps.Labels["files"] != "true" {
+		t.Errorf("Capacidades inesperadas: %+v", caps)
+	}
+}
+
+// TestHandleConnection_Plazo verifica que una solicitud con X-Request-Deadline vencido
+// en medio del handler responda 504 sin esperar a que termine
+func TestHandleConnection_Plazo(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/sleep"})
+	for _, pool := range server.CommandPools {
+		pool.Start()
+	}
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	go handleConnection(conn, server)
+	header := httpmsg.Header{}
+	httpmsg.SetDeadline(header, time.Now().Add(200*time.Millisecond))
+	go httpmsg.WriteRequest(client, "GET", "/sleep?seconds=5", header, nil)
+
+	start := time.Now()
+	resp, err := httpmsg.ReadResponse(bufio.NewReader(client), httpmsg.DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error leyendo la respuesta: %v", err)
+	}
+	if resp.StatusCode != 504 {
+		t.Errorf("Esperado 504, obtenido %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("El handler no se interrumpió en el plazo: %v", elapsed)
+	}
+}
+
+// TestHandleConnection_ClienteSeVa verifica que cerrar la conexión libere al worker del pool
+func TestHandleConnection_ClienteSeVa(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/sleep"})
+	for _, pool := range server.CommandPools {
+		pool.Start()
+	}
+
+	client, conn := net.Pipe()
+	go handleConnection(conn, server)
+	httpmsg.WriteRequest(client, "GET", "/sleep?seconds=5", nil, nil)
+	time.Sleep(100 * time.Millisecond)
+	client.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for server.CommandPools["/sleep"].Stats().Busy > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("El worker del pool siguió ocupado después de que el cliente se fue")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
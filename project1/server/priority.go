@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"http-shared/httpmsg"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Priority es la clase de una solicitud dentro del pool de su ruta. Los pools
+// atienden primero las clases más altas.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	numPriorities
+)
+
+const (
+	PriorityHeader       = "X-Priority"
+	APIKeyHeader         = "X-API-Key"
+	DefaultAgingInterval = 2 * time.Second // Cada cuánto de espera una solicitud sube una clase
+)
+
+var priorityNames = []string{"low", "normal", "high"}
+
+func (p Priority) String() string {
+	if p >= 0 && int(p) < len(priorityNames) {
+		return priorityNames[p]
+	}
+	return "unknown"
+}
+
+// ParsePriority acepta el nombre de la clase (low, normal, high) o su número (0 a 2)
+func ParsePriority(value string) (Priority, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for i, name := range priorityNames {
+		if value == name || value == strconv.Itoa(i) {
+			return Priority(i), nil
+		}
+	}
+	return PriorityNormal, &httpmsg.Error{Status: 400, Msg: fmt.Sprintf("%s inválido %q, use low, normal o high", PriorityHeader, value)}
+}
+
+// APIKeys asigna a cada API key la clase de sus solicitudes
+type APIKeys map[string]Priority
+
+// ParseAPIKeys lee una lista "clave=clase", por ejemplo "abc123=high,xyz789=low"
+func ParseAPIKeys(list string) (APIKeys, error) {
+	keys := make(APIKeys)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.Index(item, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("API key inválida %q, use clave=clase", item)
+		}
+		p, err := ParsePriority(item[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		keys[strings.TrimSpace(item[:i])] = p
+	}
+	return keys, nil
+}
+
+// priorityOf retorna la clase de la solicitud: la de su API key si es conocida, si no
+// la de X-Priority, y normal si no trae ninguna
+func (s *Server) priorityOf(h httpmsg.Header) (Priority, error) {
+	if p, ok := s.APIKeys[h.Get(APIKeyHeader)]; ok {
+		return p, nil
+	}
+	if value := h.Get(PriorityHeader); value != "" {
+		return ParsePriority(value)
+	}
+	return PriorityNormal, nil
+}
+
+// configurePriorities aplica API_KEYS y PRIORITY_AGING (por ejemplo "500ms"; "0"
+// desactiva el envejecimiento). Valores vacíos dejan los valores por defecto.
+func (s *Server) configurePriorities(keys, aging string) error {
+	apiKeys, err := ParseAPIKeys(keys)
+	if err != nil {
+		return err
+	}
+	s.APIKeys = apiKeys
+	if aging == "" {
+		return nil
+	}
+	interval := time.Duration(0)
+	if aging != "0" {
+		if interval, err = time.ParseDuration(aging); err != nil || interval < 0 {
+			return fmt.Errorf("PRIORITY_AGING inválido %q, use una duración como 2s", aging)
+		}
+	}
+	for _, pool := range s.CommandPools {
+		pool.Aging = interval
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// priority_test.go
+package main
+
+import (
+	"context"
+	"http-shared/httpmsg"
+	"testing"
+	"time"
+)
+
+// solicitudDePrueba arma una solicitud del pool que anota su nombre en order y
+// espera a release antes de terminar
+func solicitudDePrueba(name string, p Priority, order chan<- string, release <-chan struct{}) Request {
+	return Request{
+		Writer:   httpmsg.NewRecorder(),
+		Listo:    make(chan bool),
+		Ctx:      context.Background(),
+		Priority: p,
+		Handler: func(req Request) {
+			order <- name
+			<-release
+		},
+	}
+}
+
+// ocuparPool deja al único worker del pool atendiendo una solicitud hasta que se cierre release
+func ocuparPool(t *testing.T, pool *WorkerPool, order chan string, release chan struct{}) {
+	pool.Submit(solicitudDePrueba("ocupado", PriorityNormal, order, release))
+	if name := <-order; name != "ocupado" {
+		t.Fatalf("Se esperaba la solicitud que ocupa el pool, llegó %s", name)
+	}
+}
+
+// TestWorkerPool_Prioridad verifica que con el worker ocupado se atiendan primero las
+// clases más altas y, dentro de una clase, en orden de llegada
+func TestWorkerPool_Prioridad(t *testing.T) {
+	pool := NewWorkerPool(1)
+	pool.Aging = 0
+	pool.Start()
+	defer pool.Stop()
+
+	order := make(chan string, 5)
+	release := make(chan struct{})
+	ocuparPool(t, pool, order, release)
+
+	pool.Submit(solicitudDePrueba("baja", PriorityLow, order, release))
+	pool.Submit(solicitudDePrueba("normal", PriorityNormal, order, release))
+	pool.Submit(solicitudDePrueba("alta1", PriorityHigh, order, release))
+	pool.Submit(solicitudDePrueba("alta2", PriorityHigh, order, release))
+
+	stats := pool.Stats()
+	if stats.Priorities["high"].Queued != 2 || stats.Priorities["low"].Queued != 1 {
+		t.Errorf("Colas por clase inesperadas: %+v", stats.Priorities)
+	}
+
+	close(release)
+	for _, expected := range []string{"alta1", "alta2", "normal", "baja"} {
+		if name := <-order; name != expected {
+			t.Errorf("Esperado %s, atendida %s", expected, name)
+		}
+	}
+}
+
+// TestWorkerPool_Envejecimiento verifica que una solicitud baja que esperó lo suficiente
+// pase antes que una alta recién llegada
+func TestWorkerPool_Envejecimiento(t *testing.T) {
+	pool := NewWorkerPool(1)
+	pool.Aging = 50 * time.Millisecond
+	pool.Start()
+	defer pool.Stop()
+
+	order := make(chan string, 3)
+	release := make(chan struct{})
+	ocuparPool(t, pool, order, release)
+
+	pool.Submit(solicitudDePrueba("baja", PriorityLow, order, release))
+	time.Sleep(150 * time.Millisecond)
+	pool.Submit(solicitudDePrueba("alta", PriorityHigh, order, release))
+
+	close(release)
+	if name := <-order; name != "baja" {
+		t.Errorf("La solicitud baja envejecida debía atenderse primero, se atendió %s", name)
+	}
+	<-order
+	stats := pool.Stats().Priorities["low"]
+	if stats.Served != 1 || stats.Aged != 1 || stats.AvgWaitMs < 150 {
+		t.Errorf("Métricas de la clase baja inesperadas: %+v", stats)
+	}
+}
+
+// TestPriorityOf verifica que la API key mande sobre X-Priority
+func TestPriorityOf(t *testing.T) {
+	server := NewServer()
+	if err := server.configurePriorities("oro=high, bronce=low", "1s"); err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected Priority
+	}{
+		{"SinEncabezados", nil, PriorityNormal},
+		{"Encabezado", map[string]string{PriorityHeader: "HIGH"}, PriorityHigh},
+		{"Numero", map[string]string{PriorityHeader: "0"}, PriorityLow},
+		{"APIKey", map[string]string{APIKeyHeader: "bronce", PriorityHeader: "high"}, PriorityLow},
+		{"APIKeyDesconocida", map[string]string{APIKeyHeader: "otra"}, PriorityNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := httpmsg.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			p, err := server.priorityOf(h)
+			if err != nil || p != tt.expected {
+				t.Errorf("Esperado %v, obtenido %v (%v)", tt.expected, p, err)
+			}
+		})
+	}
+
+	h := httpmsg.Header{}
+	h.Set(PriorityHeader, "urgente")
+	if _, err := server.priorityOf(h); httpmsg.StatusOf(err) != 400 {
+		t.Errorf("Una prioridad inválida debería dar 400, obtenido %v", err)
+	}
+	if err := server.configurePriorities("sinclase", ""); err == nil {
+		t.Errorf("API_KEYS sin clase debería fallar")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	RegisterBaseDelay = 1 * time.Second  // Espera antes del primer reintento de registro
+	RegisterMaxDelay  = 30 * time.Second // Tope de la espera entre reintentos
+)
+
+// registerBackoff retorna la espera antes del reintento attempt (desde 0): base y el doble
+// en cada intento hasta RegisterMaxDelay, con la mitad al azar para que los workers que
+// arrancaron juntos no se registren todos a la vez
+func registerBackoff(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 0; i < attempt && delay < RegisterMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > RegisterMaxDelay {
+		delay = RegisterMaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// superviseRegistration registra el worker en el dispatcher y lo vuelve a registrar cada
+// vez que sendHeartbeats detecta que el dispatcher lo olvidó o se reinició. Reintenta sin
+// límite con registerBackoff. Termina con el drenaje.
+func (s *Server) superviseRegistration(dispatcherURL string, caps routes.Capabilities) {
+	body, err := json.Marshal(caps)
+	if err != nil {
+		log.Printf("Error generando las capacidades del worker: %v", err)
+		return
+	}
+
+	for {
+		for attempt := 0; ; attempt++ {
+			if s.isDraining() {
+				return
+			}
+			reg, err := registerWithDispatcher(dispatcherURL, body)
+			if err == nil {
+				s.setEpoch(reg.Epoch)
+				log.Printf("Registrado en el dispatcher como %s (%s, epoch %s)", caps.URL, reg.Status, reg.Epoch)
+				break
+			}
+			delay := registerBackoff(s.RegisterRetry, attempt)
+			log.Printf("No se pudo registrar en el dispatcher (intento %d), se reintenta en %v: %v", attempt+1, delay.Truncate(time.Millisecond), err)
+			select {
+			case <-time.After(delay):
+			case <-s.doneChan:
+				return
+			}
+		}
+
+		// Los pedidos que llegaron mientras se registraba ya quedaron atendidos
+		select {
+		case <-s.reregister:
+		default:
+		}
+		select {
+		case <-s.reregister:
+		case <-s.doneChan:
+			return
+		}
+	}
+}
+
+// checkRegistration revisa la respuesta a un heartbeat. Un 404 indica que el dispatcher
+// no conoce al worker y un epoch distinto que el dispatcher se reinició; en ambos casos
+// se pide un nuevo registro.
+func (s *Server) checkRegistration(resp *httpmsg.Response) {
+	if resp.StatusCode == 404 {
+		s.requestRegistration("el dispatcher no conoce al worker")
+		return
+	}
+	if resp.StatusCode != 200 {
+		return
+	}
+	var reg routes.Registration
+	if err := json.Unmarshal(resp.Body, &reg); err != nil || reg.Epoch == "" {
+		return
+	}
+	if current := s.currentEpoch(); current != "" && current != reg.Epoch {
+		s.requestRegistration("el dispatcher se reinició (epoch " + reg.Epoch + ")")
+	}
+}
+
+// requestRegistration despierta al supervisor sin bloquear. Durante el drenaje no se
+// pide: el dispatcher ya sacó al worker de su lista.
+func (s *Server) requestRegistration(reason string) {
+	if s.isDraining() {
+		return
+	}
+	select {
+	case s.reregister <- struct{}{}:
+		log.Printf("Worker: Se vuelve a registrar, %s", reason)
+	default:
+	}
+}
+
+func (s *Server) setEpoch(epoch string) {
+	s.epochMu.Lock()
+	s.epoch = epoch
+	s.epochMu.Unlock()
+}
+
+func (s *Server) currentEpoch() string {
+	s.epochMu.Lock()
+	defer s.epochMu.Unlock()
+	return s.epoch
+}
@@ -0,0 +1,146 @@
+// registration_test.go
+package main
+
+import (
+	"bufio"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDispatcher responde /suscribir y /heartbeat con el epoch actual. Rechaza los
+// primeros failures registros con 503 y los heartbeats con 404 mientras forgotten sea true.
+type fakeDispatcher struct {
+	url string
+
+	mu        sync.Mutex
+	epoch     string
+	failures  int
+	forgotten bool
+	registers int
+}
+
+func newFakeDispatcher(t *testing.T, failures int) *fakeDispatcher {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo escuchar: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	fd := &fakeDispatcher{url: "http://" + ln.Addr().String(), epoch: "uno", failures: failures}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fd.serve(conn)
+		}
+	}()
+	return fd
+}
+
+func (fd *fakeDispatcher) serve(conn net.Conn) {
+	defer conn.Close()
+	req, err := httpmsg.ReadRequest(bufio.NewReader(conn), httpmsg.DefaultLimits)
+	if err != nil {
+		return
+	}
+	req.ReadBody()
+	w := httpmsg.NewWriter(conn, req)
+	defer w.Finish()
+
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	switch {
+	case req.Path == "/suscribir" && fd.failures > 0:
+		fd.failures--
+		httpmsg.Text(w, 503, "todavía no")
+	case req.Path == "/suscribir":
+		fd.registers++
+		fd.forgotten = false
+		httpmsg.JSON(w, 200, routes.Registration{ID: "1", Status: "registered", Epoch: fd.epoch})
+	case fd.forgotten:
+		httpmsg.Text(w, 404, "Worker no registrado")
+	default:
+		httpmsg.JSON(w, 200, routes.Registration{Status: "ok", Epoch: fd.epoch})
+	}
+}
+
+func (fd *fakeDispatcher) registered() int {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	return fd.registers
+}
+
+// TestSupervisor_ReintentaHastaRegistrar verifica que el worker siga intentando mientras
+// el dispatcher no lo acepte, en vez de rendirse después de unos intentos
+func TestSupervisor_ReintentaHastaRegistrar(t *testing.T) {
+	fd := newFakeDispatcher(t, 4)
+	server := NewServer()
+	server.RegisterRetry = 5 * time.Millisecond
+	defer close(server.doneChan)
+
+	go server.superviseRegistration(fd.url, server.Capabilities("worker1", "worker1:8080", nil))
+	esperarCondicion(t, "El worker no se registró", func() bool { return fd.registered() == 1 })
+	if epoch := server.currentEpoch(); epoch != "uno" {
+		t.Errorf("Epoch inesperado %q", epoch)
+	}
+}
+
+// TestSupervisor_DispatcherReiniciado verifica que un epoch nuevo o un 404 en el heartbeat
+// hagan que el worker se vuelva a registrar, salvo durante el drenaje
+func TestSupervisor_DispatcherReiniciado(t *testing.T) {
+	fd := newFakeDispatcher(t, 0)
+	server := NewServer()
+	server.RegisterRetry = 5 * time.Millisecond
+	server.HeartbeatInterval = 10 * time.Millisecond
+
+	go server.superviseRegistration(fd.url, server.Capabilities("worker1", "worker1:8080", nil))
+	go server.sendHeartbeats(fd.url, "worker1:8080")
+	esperarCondicion(t, "El worker no se registró", func() bool { return fd.registered() == 1 })
+
+	// El dispatcher se reinicia con otro epoch
+	fd.mu.Lock()
+	fd.epoch = "dos"
+	fd.mu.Unlock()
+	esperarCondicion(t, "El worker no notó el reinicio", func() bool { return fd.registered() == 2 && server.currentEpoch() == "dos" })
+
+	// El dispatcher olvida al worker sin cambiar de epoch
+	fd.mu.Lock()
+	fd.forgotten = true
+	fd.mu.Unlock()
+	esperarCondicion(t, "El worker no se registró después del 404", func() bool { return fd.registered() == 3 })
+
+	// Con el epoch al día no hay registros de más
+	time.Sleep(50 * time.Millisecond)
+	if n := fd.registered(); n != 3 {
+		t.Errorf("Esperados 3 registros, hubo %d", n)
+	}
+
+	// Durante el drenaje el 404 no provoca un nuevo registro
+	server.Drain(nil)
+	fd.mu.Lock()
+	fd.forgotten = true
+	fd.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if n := fd.registered(); n != 3 {
+		t.Errorf("El worker se registró durante el drenaje: %d registros", n)
+	}
+}
+
+// TestRegisterBackoff verifica que la espera crezca, tenga tope y varíe al azar
+func TestRegisterBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt, max := range []time.Duration{base, 2 * base, 4 * base} {
+		delay := registerBackoff(base, attempt)
+		if delay < max/2 || delay > max {
+			t.Errorf("Intento %d: espera %v fuera de [%v, %v]", attempt, delay, max/2, max)
+		}
+	}
+	if delay := registerBackoff(base, 50); delay > RegisterMaxDelay || delay < RegisterMaxDelay/2 {
+		t.Errorf("La espera no respeta el tope: %v", delay)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"http-shared/httpmsg"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DrainTimeout      = 60 * time.Second       // Plazo de gracia por defecto para las solicitudes en curso
+	CancelGrace       = 500 * time.Millisecond // Tiempo para que las solicitudes canceladas respondan 504
+	drainPollInterval = 50 * time.Millisecond
+)
+
+// configureShutdown aplica SHUTDOWN_GRACE, por ejemplo "20s". Vacío deja DrainTimeout.
+func (s *Server) configureShutdown(grace string) error {
+	if grace == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(grace)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("SHUTDOWN_GRACE inválido %q, use una duración positiva como 20s", grace)
+	}
+	s.GracePeriod = d
+	return nil
+}
+
+// beginRequest registra una solicitud en curso. Retorna false si el worker se está
+// drenando, en ese caso la solicitud no se atiende.
+func (s *Server) beginRequest() bool {
+	atomic.AddInt64(&s.inFlight, 1)
+	if s.isDraining() {
+		atomic.AddInt64(&s.inFlight, -1)
+		return false
+	}
+	return true
+}
+
+func (s *Server) endRequest() {
+	atomic.AddInt64(&s.inFlight, -1)
+}
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// Drain apaga el worker de forma ordenada: avisa al dispatcher, deja de aceptar
+// conexiones, espera las solicitudes en curso hasta GracePeriod y cierra los pools con
+// ShutDownChan. notify puede ser nil (por ejemplo en las pruebas). Si vence el plazo de
+// gracia se cancelan las solicitudes que quedan, se les da CancelGrace para responder y
+// se retorna un error; las que no revisan su contexto se abandonan y las que seguían en
+// la cola de un pool reciben 503. Al terminar se cierra doneChan.
+func (s *Server) Drain(notify func() error) error {
+	if !atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+		return nil
+	}
+	log.Printf("Worker: Iniciando drenaje")
+
+	if notify != nil {
+		if err := notify(); err != nil {
+			log.Printf("Worker: No se pudo avisar al dispatcher del drenaje: %v", err)
+		}
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	grace := s.GracePeriod
+	if grace <= 0 {
+		grace = DrainTimeout
+	}
+	var result error
+	deadline := time.Now().Add(grace)
+	for atomic.LoadInt64(&s.inFlight) > 0 {
+		if time.Now().After(deadline) {
+			pending := atomic.LoadInt64(&s.inFlight)
+			log.Printf("Worker: Se agotó el plazo de gracia de %v con %d solicitudes en curso, se cancelan", grace, pending)
+			result = fmt.Errorf("%d solicitudes sin terminar después de %v", pending, grace)
+			s.cancelAll()
+			break
+		}
+		time.Sleep(drainPollInterval)
+	}
+
+	if result != nil {
+		deadline = time.Now().Add(CancelGrace)
+	}
+	var abandoned int64
+	for ruta, pool := range s.CommandPools {
+		abandoned += pool.StopUntil(deadline)
+		log.Printf("Worker: Pool %s cerrado", ruta)
+	}
+	if abandoned > 0 {
+		log.Printf("Worker: %d solicitudes abandonadas sin terminar", abandoned)
+		if result == nil {
+			result = fmt.Errorf("%d solicitudes abandonadas sin terminar", abandoned)
+		}
+	}
+	s.logMetrics()
+	close(s.doneChan)
+	log.Printf("Worker: Drenaje completo")
+	return result
+}
+
+// logMetrics deja en el log las métricas finales del worker y vacía la salida del log
+func (s *Server) logMetrics() {
+	s.Metrics.Mu.Lock()
+	uptime := time.Since(s.Metrics.TiempoInicio).Truncate(time.Second)
+	totalRequests := s.Metrics.TotalRequests
+	s.Metrics.Mu.Unlock()
+
+	log.Printf("Worker: Métricas finales: %d solicitudes en %v", totalRequests, uptime)
+	for ruta, pool := range s.CommandPools {
+		stats := pool.Stats()
+		served := 0
+		for _, class := range stats.Priorities {
+			served += class.Served
+		}
+		log.Printf("Worker: Pool %s: %d atendidas, %d workers (%d..%d)", ruta, served, stats.Workers, stats.Min, stats.Max)
+	}
+	if f, ok := log.Writer().(*os.File); ok {
+		f.Sync()
+	}
+}
+
+// rejectDraining responde 503 y cierra la conexión mientras el worker se apaga
+func rejectDraining(w httpmsg.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	w.Header().Set("Retry-After", "1")
+	httpmsg.Text(w, 503, "Worker en drenaje, intente con otro worker")
+}
@@ -0,0 +1,203 @@
+// shutdown_test.go
+package main
+
+import (
+	"bufio"
+	"http-shared/httpmsg"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestDrain verifica que el drenaje avise al dispatcher, termine la solicitud en curso,
+// deje de aceptar conexiones y cierre los pools
+func TestDrain(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/sleep"})
+	for _, pool := range server.CommandPools {
+		pool.Start()
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo escuchar: %v", err)
+	}
+	go server.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("No se pudo conectar: %v", err)
+	}
+	defer conn.Close()
+	httpmsg.WriteRequest(conn, "GET", "/sleep?seconds=1", nil, nil)
+	// Dar tiempo a que la solicitud llegue al pool antes de drenar
+	time.Sleep(100 * time.Millisecond)
+
+	notified := make(chan struct{})
+	go server.Drain(func() error { close(notified); return nil })
+
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatalf("No se avisó al dispatcher")
+	}
+
+	// La solicitud en curso termina aunque el worker se esté drenando
+	resp, err := httpmsg.ReadResponse(bufio.NewReader(conn), httpmsg.DefaultLimits)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("La solicitud en curso debía terminar: %v %+v", err, resp)
+	}
+
+	select {
+	case <-server.doneChan:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("El drenaje no terminó")
+	}
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), 200*time.Millisecond); err == nil {
+		t.Errorf("El worker no debería aceptar conexiones después del drenaje")
+	}
+}
+
+// TestDrain_Rechaza verifica que durante el drenaje las nuevas solicitudes reciban 503
+func TestDrain_Rechaza(t *testing.T) {
+	server := NewServer()
+	server.Drain(nil)
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	go handleConnection(conn, server)
+	go httpmsg.WriteRequest(client, "GET", "/ping", nil, nil)
+
+	resp, err := httpmsg.ReadResponse(bufio.NewReader(client), httpmsg.DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error leyendo la respuesta: %v", err)
+	}
+	if resp.StatusCode != 503 || !resp.Close || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("Respuesta inesperada: %d close=%v %v", resp.StatusCode, resp.Close, resp.Header)
+	}
+}
+
+// lanzar abre n conexiones con la misma solicitud y retorna un canal con el estado de cada respuesta
+func lanzar(t *testing.T, addr, target string, n int) <-chan int {
+	codes := make(chan int, n)
+	for i := 0; i < n; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("No se pudo conectar: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		httpmsg.WriteRequest(conn, "GET", target, nil, nil)
+		go func() {
+			resp, err := httpmsg.ReadResponse(bufio.NewReader(conn), httpmsg.DefaultLimits)
+			if err != nil {
+				codes <- 0
+				return
+			}
+			codes <- resp.StatusCode
+		}()
+	}
+	return codes
+}
+
+// TestDrain_SinPerdidas verifica que ninguna solicitud aceptada se pierda en el drenaje,
+// tampoco las que esperan en la cola del pool
+func TestDrain_SinPerdidas(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/sleep"})
+	for _, pool := range server.CommandPools {
+		pool.ScaleUpWait = 0
+		pool.Start()
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo escuchar: %v", err)
+	}
+	go server.Serve(ln)
+
+	// El pool de /sleep tiene 3 workers: 3 solicitudes en curso y 3 en cola
+	const n = 6
+	codes := lanzar(t, ln.Addr().String(), "/sleep?seconds=1", n)
+	esperarCondicion(t, "Las solicitudes no llegaron al worker", func() bool { return atomic.LoadInt64(&server.inFlight) == n })
+
+	drained := make(chan error, 1)
+	go func() { drained <- server.Drain(nil) }()
+	for i := 0; i < n; i++ {
+		if code := <-codes; code != 200 {
+			t.Errorf("Una solicitud aceptada antes del drenaje obtuvo %d", code)
+		}
+	}
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("El drenaje no debería cancelar solicitudes: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("El drenaje no terminó")
+	}
+}
+
+// TestDrain_PlazoDeGracia verifica que al vencer el plazo de gracia se corten las
+// solicitudes que quedan, todas reciban respuesta y Drain informe el error
+func TestDrain_PlazoDeGracia(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/sleep"})
+	if err := server.configureShutdown("200ms"); err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if err := server.configureShutdown("nunca"); err == nil {
+		t.Errorf("SHUTDOWN_GRACE inválido debería fallar")
+	}
+	for _, pool := range server.CommandPools {
+		pool.ScaleUpWait = 0
+		pool.Start()
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo escuchar: %v", err)
+	}
+	go server.Serve(ln)
+
+	const n = 4
+	codes := lanzar(t, ln.Addr().String(), "/sleep?seconds=5", n)
+	esperarCondicion(t, "Las solicitudes no llegaron al worker", func() bool { return atomic.LoadInt64(&server.inFlight) == n })
+
+	start := time.Now()
+	if err := server.Drain(nil); err == nil {
+		t.Errorf("Drain debería informar las solicitudes canceladas")
+	}
+	for i := 0; i < n; i++ {
+		if code := <-codes; code != 504 && code != 503 {
+			t.Errorf("Esperado 504 o 503 para una solicitud cortada, obtenido %d", code)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("El drenaje no respetó el plazo de gracia: %v", elapsed)
+	}
+}
+
+// Prueba que una solicitud que no revisa su contexto no alargue el apagado más allá del
+// plazo de gracia: se abandona y Drain retorna un error
+func TestDrain_AbandonaSinContexto(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/sleep"})
+	server.GracePeriod = 100 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	server.Handlers["/sleep"] = func(req Request) { <-release } // Ignora req.Ctx
+	for _, pool := range server.CommandPools {
+		pool.ScaleUpWait = 0
+		pool.Start()
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo escuchar: %v", err)
+	}
+	go server.Serve(ln)
+
+	lanzar(t, ln.Addr().String(), "/sleep?seconds=5", 1)
+	esperarCondicion(t, "La solicitud no llegó al worker", func() bool { return atomic.LoadInt64(&server.inFlight) == 1 })
+
+	start := time.Now()
+	if err := server.Drain(nil); err == nil {
+		t.Errorf("Drain debería informar la solicitud abandonada")
+	}
+	if elapsed := time.Since(start); elapsed > server.GracePeriod+CancelGrace+time.Second {
+		t.Errorf("El drenaje esperó a un handler que ignora su contexto: %v", elapsed)
+	}
+}
@@ -1,18 +1,21 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sync/atomic"
+)
 
 // Worker
 type Worker struct {
 	ID           int
 	RequestChan  chan Request
-	WorkerChan   chan chan Request
+	WorkerChan   chan *Worker
 	ReqActual    *Request
 	ShutDownChan chan struct{}
 	Status       string
 }
 
-func NewWorker(id int, workerChan chan chan Request, requestChan chan Request) *Worker {
+func NewWorker(id int, workerChan chan *Worker, requestChan chan Request) *Worker {
 	return &Worker{
 		ID:           id,
 		RequestChan:  requestChan,
@@ -27,21 +30,29 @@ func (w *Worker) Start(wp *WorkerPool) {
 	defer wp.Wg.Done()
 	for {
 		// 1. Notificar al pool que este worker está disponible
-		wp.WorkerChan <- w.RequestChan
+		select {
+		case wp.WorkerChan <- w:
+		case <-w.ShutDownChan:
+			return
+		}
 
 		select {
 		case req := <-w.RequestChan:
 			fmt.Printf("Worker %d recibió solicitud %d", w.ID, req.ID)
 			// 2. Actualizar estado del worker
+			atomic.AddInt64(&wp.queued, -1)
+			atomic.AddInt64(&wp.busy, 1)
 			w.ReqActual = &req
 			w.Status = "ocupado"
 
-			// 3. Procesar la solicitud
+			// 3. Procesar la solicitud y avisar a la conexión que ya se respondió
 			HandleRequest(req)
+			close(req.Listo)
 
 			// 4. Limpiar estado
 			w.ReqActual = nil
 			w.Status = "disponible"
+			atomic.AddInt64(&wp.busy, -1)
 
 		case <-w.ShutDownChan:
 			// 5. Salir si se recibe señal de shutdown
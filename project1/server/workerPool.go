@@ -1,56 +1,434 @@
 package main
 
 import (
+	"fmt"
+	"http-shared/routes"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// WorkerPool
+const (
+	DefaultPoolGrowth    = 4                      // El máximo de un pool es su tamaño inicial por este factor
+	DefaultScaleUpWait   = 500 * time.Millisecond // Espera en cola que agrega un worker al pool
+	DefaultCooldown      = 30 * time.Second       // Tiempo con workers libres antes de retirar uno
+	DefaultScaleInterval = 250 * time.Millisecond // Cada cuánto se revisa si el pool crece o se achica
+)
+
+// WorkerPool es el pool de una ruta. Tiene entre min y max workers: crece cuando una
+// solicitud espera más de ScaleUpWait y retira un worker por cada Cooldown con workers
+// libres. Resize cambia el tamaño y los límites en caliente.
 type WorkerPool struct {
-	cantidadW    int
-	RequestChan  chan Request
-	Wg           sync.WaitGroup
-	WorkerChan   chan chan Request
-	Workers      []*Worker
-	ShutDownChan chan struct{}
+	Wg            sync.WaitGroup
+	WorkerChan    chan *Worker
+	ShutDownChan  chan struct{}
+	Aging         time.Duration // Espera que sube una clase a una solicitud; 0 no sube
+	ScaleUpWait   time.Duration // 0 desactiva el crecimiento automático
+	Cooldown      time.Duration // 0 desactiva el retiro automático
+	ScaleInterval time.Duration // Cada cuánto se revisa el tamaño; se fija antes de Start
+	queued        int64 // Solicitudes enviadas que todavía no toma un worker
+	busy          int64 // Workers atendiendo una solicitud
+
+	mu        sync.Mutex
+	Workers   []*Worker // Workers vivos, leer con snapshot
+	cantidadW int       // Tamaño objetivo; dispatch retira workers libres hasta llegar a él
+	min, max  int
+	nextID    int
+	started   bool
+	stopped   bool
+	idleSince time.Time // Desde cuándo el pool tiene workers libres y nada en cola
+	queues    [numPriorities][]pendingRequest // Una cola FIFO por clase
+	classes   [numPriorities]classStats
+	arrived   chan struct{} // Aviso a dispatch de que llegó una solicitud, buffer 1
+	resized   chan struct{} // Aviso a dispatch de que bajó el tamaño objetivo, buffer 1
+}
+
+// pendingRequest es una solicitud esperando un worker del pool
+type pendingRequest struct {
+	req Request
+	at  time.Time
+}
+
+type classStats struct {
+	served int
+	aged   int
+	wait   time.Duration
 }
 
+// NewWorkerPool crea un pool de tamaño fijo
 func NewWorkerPool(cantidadW int) *WorkerPool {
+	return NewElasticPool(cantidadW, cantidadW)
+}
+
+// NewElasticPool crea un pool que arranca con min workers y puede llegar a max
+func NewElasticPool(min, max int) *WorkerPool {
 	return &WorkerPool{
-		cantidadW:    cantidadW,
-		RequestChan:  make(chan Request),
-		WorkerChan:   make(chan chan Request),
-		ShutDownChan: make(chan struct{}),
+		cantidadW:     min,
+		min:           min,
+		max:           max,
+		WorkerChan:    make(chan *Worker),
+		ShutDownChan:  make(chan struct{}),
+		Aging:         DefaultAgingInterval,
+		ScaleUpWait:   DefaultScaleUpWait,
+		Cooldown:      DefaultCooldown,
+		ScaleInterval: DefaultScaleInterval,
+		arrived:       make(chan struct{}, 1),
+		resized:       make(chan struct{}, 1),
 	}
 }
 
 func (wp *WorkerPool) Start() {
-	for i := 0; i < wp.cantidadW; i++ {
-		worker := NewWorker(i, wp.WorkerChan, wp.RequestChan)
+	wp.mu.Lock()
+	wp.started = true
+	wp.addWorkers()
+	n := len(wp.Workers)
+	wp.mu.Unlock()
+
+	go wp.dispatch()
+	go wp.autoscale()
+	log.Printf("WorkerPool iniciado con %d workers", n)
+}
+
+// addWorkers lanza workers hasta llegar al tamaño objetivo. Se llama con mu tomado;
+// después de Stop no agrega ninguno para no llamar a Wg.Add mientras Stop espera.
+func (wp *WorkerPool) addWorkers() {
+	if !wp.started || wp.stopped {
+		return
+	}
+	for len(wp.Workers) < wp.cantidadW {
+		// Cada worker tiene su propio canal para que solo reciba lo que elige dispatch
+		worker := NewWorker(wp.nextID, wp.WorkerChan, make(chan Request))
+		wp.nextID++
 		wp.Workers = append(wp.Workers, worker)
 		wp.Wg.Add(1)
 		go worker.Start(wp)
 	}
+}
 
-	go wp.dispatch()
-	log.Printf("WorkerPool iniciado con %d workers", wp.cantidadW)
+// retireIfOver retira al worker libre si el pool tiene más workers que su tamaño objetivo
+func (wp *WorkerPool) retireIfOver(worker *Worker) bool {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	if len(wp.Workers) <= wp.cantidadW {
+		return false
+	}
+	for i, w := range wp.Workers {
+		if w == worker {
+			wp.Workers = append(wp.Workers[:i], wp.Workers[i+1:]...)
+			break
+		}
+	}
+	close(worker.ShutDownChan)
+	log.Printf("WorkerPool: worker %d retirado, quedan %d", worker.ID, len(wp.Workers))
+	return true
+}
+
+// Resize cambia el tamaño del pool y, si no son cero, sus límites. El tamaño debe
+// quedar entre min y max. Los workers que sobran se retiran cuando quedan libres.
+func (wp *WorkerPool) Resize(size, min, max int) error {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	if min <= 0 {
+		min = wp.min
+	}
+	if max <= 0 {
+		max = wp.max
+	}
+	if size <= 0 {
+		size = wp.cantidadW
+	}
+	if min > max {
+		return fmt.Errorf("min (%d) no puede ser mayor que max (%d)", min, max)
+	}
+	if size < min || size > max {
+		return fmt.Errorf("size (%d) debe estar entre min (%d) y max (%d)", size, min, max)
+	}
+	wp.min, wp.max = min, max
+	wp.setSize(size)
+	return nil
+}
+
+// setSize cambia el tamaño objetivo. Se llama con mu tomado.
+func (wp *WorkerPool) setSize(size int) {
+	if size == wp.cantidadW {
+		return
+	}
+	log.Printf("WorkerPool: tamaño %d -> %d", wp.cantidadW, size)
+	wp.cantidadW = size
+	wp.addWorkers()
+	select {
+	case wp.resized <- struct{}{}:
+	default:
+	}
+}
+
+// autoscale revisa cada ScaleInterval si el pool debe crecer o achicarse
+func (wp *WorkerPool) autoscale() {
+	ticker := time.NewTicker(wp.ScaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			wp.scale(now)
+		case <-wp.ShutDownChan:
+			return
+		}
+	}
 }
 
+// scale agrega un worker si la solicitud más antigua en cola esperó más de ScaleUpWait,
+// o retira uno si el pool tuvo workers libres y nada en cola durante Cooldown
+func (wp *WorkerPool) scale(now time.Time) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	var oldest time.Time
+	for p := range wp.queues {
+		if len(wp.queues[p]) > 0 && (oldest.IsZero() || wp.queues[p][0].at.Before(oldest)) {
+			oldest = wp.queues[p][0].at
+		}
+	}
+	if !oldest.IsZero() {
+		wp.idleSince = time.Time{}
+		if wp.ScaleUpWait > 0 && now.Sub(oldest) >= wp.ScaleUpWait && wp.cantidadW < wp.max {
+			wp.setSize(wp.cantidadW + 1)
+		}
+		return
+	}
+
+	if int(atomic.LoadInt64(&wp.busy)) >= wp.cantidadW {
+		wp.idleSince = time.Time{}
+		return
+	}
+	if wp.idleSince.IsZero() {
+		wp.idleSince = now
+		return
+	}
+	if wp.Cooldown > 0 && now.Sub(wp.idleSince) >= wp.Cooldown && wp.cantidadW > wp.min {
+		wp.setSize(wp.cantidadW - 1)
+		wp.idleSince = now
+	}
+}
+
+// snapshot retorna una copia de los workers vivos
+func (wp *WorkerPool) snapshot() []*Worker {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	return append([]*Worker(nil), wp.Workers...)
+}
+
+// dispatch espera un worker libre y recién entonces elige la solicitud, así la
+// prioridad se decide con las solicitudes que llegaron mientras los workers estaban
+// ocupados. Un worker libre que sobra según el tamaño objetivo se retira.
 func (wp *WorkerPool) dispatch() {
 	for {
+		// Esperar un worker disponible
+		var worker *Worker
 		select {
-		case req := <-wp.RequestChan:
-			// Esperar un worker disponible
-			workerChan := <-wp.WorkerChan
-			// Enviar la solicitud al worker
-			workerChan <- req
+		case worker = <-wp.WorkerChan:
+		case <-wp.ShutDownChan:
+			wp.closeWorkers()
+			return
+		}
 
+		req, ok := wp.next(worker)
+		if !ok {
+			continue
+		}
+		// Enviar la solicitud al worker, que la espera desde que se anunció
+		worker.RequestChan <- req
+	}
+}
+
+func (wp *WorkerPool) closeWorkers() {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	for _, worker := range wp.Workers {
+		close(worker.ShutDownChan)
+	}
+	wp.Workers = nil
+}
+
+// next espera con el worker libre hasta que haya una solicitud en alguna cola. Retorna
+// false si en la espera el worker se retiró o se cerró el pool.
+func (wp *WorkerPool) next(worker *Worker) (Request, bool) {
+	for {
+		if wp.retireIfOver(worker) {
+			return Request{}, false
+		}
+		if req, ok := wp.pop(time.Now()); ok {
+			return req, true
+		}
+		select {
+		case <-wp.arrived:
+		case <-wp.resized:
 		case <-wp.ShutDownChan:
-			// Cerrar todos los workers
-			for _, worker := range wp.Workers {
-				close(worker.ShutDownChan)
+			// closeWorkers en dispatch cierra también a este worker
+			return Request{}, false
+		}
+	}
+}
+
+// pop saca la solicitud de mayor clase efectiva: su clase más una por cada Aging que
+// lleva esperando, sin pasar de high. Entre iguales gana la que llegó primero.
+func (wp *WorkerPool) pop(now time.Time) (Request, bool) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	best, bestClass := Priority(-1), Priority(-1)
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		if len(wp.queues[p]) == 0 {
+			continue
+		}
+		head := wp.queues[p][0]
+		class := p
+		if wp.Aging > 0 {
+			class += Priority(now.Sub(head.at) / wp.Aging)
+		}
+		if class > PriorityHigh {
+			class = PriorityHigh
+		}
+		if class > bestClass || (class == bestClass && head.at.Before(wp.queues[best][0].at)) {
+			best, bestClass = p, class
+		}
+	}
+	if best < 0 {
+		return Request{}, false
+	}
+
+	head := wp.queues[best][0]
+	wp.queues[best][0] = pendingRequest{}
+	wp.queues[best] = wp.queues[best][1:]
+	stats := &wp.classes[best]
+	stats.served++
+	stats.wait += now.Sub(head.at)
+	if bestClass > best {
+		stats.aged++
+	}
+	return head.req, true
+}
+
+// Stop cierra el pool con ShutDownChan y espera a que los workers terminen su solicitud
+// actual. Las solicitudes que quedaron en cola reciben 503 para que la conexión no espere.
+func (wp *WorkerPool) Stop() {
+	wp.StopUntil(time.Time{})
+}
+
+// StopUntil es como Stop pero deja de esperar a los workers al llegar a deadline; cero no
+// tiene límite. Retorna cuántos workers quedaron atendiendo una solicitud que no revisa
+// su contexto: esas solicitudes se abandonan.
+func (wp *WorkerPool) StopUntil(deadline time.Time) int64 {
+	wp.mu.Lock()
+	wp.stopped = true
+	n := len(wp.Workers)
+	wp.mu.Unlock()
+	close(wp.ShutDownChan)
+
+	finished := make(chan struct{})
+	go func() {
+		wp.Wg.Wait()
+		close(finished)
+	}()
+	var abandoned int64
+	if deadline.IsZero() {
+		<-finished
+	} else {
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-finished:
+		case <-timer.C:
+			abandoned = atomic.LoadInt64(&wp.busy)
+			log.Printf("WorkerPool: %d solicitudes en curso abandonadas al vencer el plazo", abandoned)
+		}
+		timer.Stop()
+	}
+
+	wp.mu.Lock()
+	var pending []pendingRequest
+	for p := range wp.queues {
+		pending = append(pending, wp.queues[p]...)
+		wp.queues[p] = nil
+	}
+	wp.mu.Unlock()
+	for _, p := range pending {
+		atomic.AddInt64(&wp.queued, -1)
+		rejectDraining(p.req.Writer)
+		close(p.req.Listo)
+	}
+	log.Printf("WorkerPool detenido (%d workers, %d solicitudes en cola rechazadas)", n, len(pending))
+	return abandoned
+}
+
+// Submit encola la solicitud en la cola de su clase sin bloquear. Después de Stop nadie
+// vaciaría la cola, así que la solicitud se rechaza con 503 en el momento.
+func (wp *WorkerPool) Submit(req Request) {
+	if req.Priority < PriorityLow || req.Priority > PriorityHigh {
+		req.Priority = PriorityNormal
+	}
+	wp.mu.Lock()
+	if wp.stopped {
+		wp.mu.Unlock()
+		rejectDraining(req.Writer)
+		close(req.Listo)
+		return
+	}
+	atomic.AddInt64(&wp.queued, 1)
+	wp.queues[req.Priority] = append(wp.queues[req.Priority], pendingRequest{req: req, at: time.Now()})
+	wp.mu.Unlock()
+
+	select {
+	case wp.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// Stats retorna la carga actual del pool para /status y el heartbeat
+func (wp *WorkerPool) Stats() routes.PoolStats {
+	wp.mu.Lock()
+	workers, min, max := wp.cantidadW, wp.min, wp.max
+	priorities := make(map[string]routes.ClassStats, numPriorities)
+	for p := PriorityLow; p < numPriorities; p++ {
+		stats := wp.classes[p]
+		class := routes.ClassStats{Queued: len(wp.queues[p]), Served: stats.served, Aged: stats.aged}
+		if stats.served > 0 {
+			class.AvgWaitMs = (stats.wait / time.Duration(stats.served)).Milliseconds()
+		}
+		priorities[p.String()] = class
+	}
+	wp.mu.Unlock()
+
+	return routes.PoolStats{
+		Workers:    workers,
+		Min:        min,
+		Max:        max,
+		Busy:       int(atomic.LoadInt64(&wp.busy)),
+		Queued:     int(atomic.LoadInt64(&wp.queued)),
+		Priorities: priorities,
+	}
+}
+
+// configurePools aplica POOL_SCALE_UP_WAIT y POOL_COOLDOWN a todos los pools, por
+// ejemplo "1s" y "2m"; "0" desactiva el crecimiento o el retiro automático
+func (s *Server) configurePools(scaleUpWait, cooldown string) error {
+	for name, value := range map[string]string{"POOL_SCALE_UP_WAIT": scaleUpWait, "POOL_COOLDOWN": cooldown} {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if value == "0" {
+			d, err = 0, nil
+		}
+		if err != nil || d < 0 {
+			return fmt.Errorf("%s inválido %q, use una duración como 2s", name, value)
+		}
+		for _, pool := range s.CommandPools {
+			if name == "POOL_SCALE_UP_WAIT" {
+				pool.ScaleUpWait = d
+			} else {
+				pool.Cooldown = d
 			}
-			return
 		}
 	}
+	return nil
 }
@@ -0,0 +1,200 @@
+// workerPool_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"http-shared/httpmsg"
+	"http-shared/routes"
+	"testing"
+	"time"
+)
+
+// esperarCondicion reintenta cond durante un segundo
+func esperarCondicion(t *testing.T, msg string, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestWorkerPool_Crece verifica que el pool agregue workers hasta max cuando las
+// solicitudes esperan en cola más de ScaleUpWait
+func TestWorkerPool_Crece(t *testing.T) {
+	pool := NewElasticPool(1, 3)
+	pool.ScaleUpWait = 50 * time.Millisecond
+	pool.Cooldown = 0
+	pool.Start()
+	defer pool.Stop()
+
+	order := make(chan string, 4)
+	release := make(chan struct{})
+	for _, name := range []string{"a", "b", "c", "d"} {
+		pool.Submit(solicitudDePrueba(name, PriorityNormal, order, release))
+	}
+	// Con 3 workers se atienden 3 a la vez y la cuarta sigue en cola
+	for i := 0; i < 3; i++ {
+		select {
+		case <-order:
+		case <-time.After(time.Second):
+			t.Fatalf("El pool no creció: %+v", pool.Stats())
+		}
+	}
+	stats := pool.Stats()
+	if stats.Workers != 3 || len(pool.snapshot()) != 3 || stats.Queued != 1 {
+		t.Errorf("Se esperaban 3 workers y 1 en cola: %+v", stats)
+	}
+	close(release)
+	<-order
+}
+
+// TestWorkerPool_RetiraInactivos verifica que un pool sin carga vuelva a min después de Cooldown
+func TestWorkerPool_RetiraInactivos(t *testing.T) {
+	pool := NewElasticPool(1, 4)
+	pool.Cooldown = 50 * time.Millisecond
+	pool.ScaleInterval = 10 * time.Millisecond // Retira uno por Cooldown: 3 en unos 150ms
+	pool.Start()
+	defer pool.Stop()
+
+	if err := pool.Resize(4, 0, 0); err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if n := len(pool.snapshot()); n != 4 {
+		t.Fatalf("Esperados 4 workers después de Resize, hay %d", n)
+	}
+	esperarCondicion(t, "El pool no retiró los workers inactivos", func() bool {
+		return pool.Stats().Workers == 1 && len(pool.snapshot()) == 1
+	})
+
+	// El worker que queda sigue atendiendo
+	order := make(chan string, 1)
+	release := make(chan struct{})
+	close(release)
+	pool.Submit(solicitudDePrueba("ultima", PriorityNormal, order, release))
+	if name := <-order; name != "ultima" {
+		t.Errorf("Solicitud inesperada %s", name)
+	}
+}
+
+// TestWorkerPool_Resize verifica los límites de Resize y que achicar retire workers libres
+func TestWorkerPool_Resize(t *testing.T) {
+	pool := NewElasticPool(2, 4)
+	pool.ScaleUpWait, pool.Cooldown = 0, 0
+	pool.Start()
+
+	if err := pool.Resize(5, 0, 0); err == nil {
+		t.Errorf("Un tamaño mayor que max debería fallar")
+	}
+	if err := pool.Resize(0, 5, 3); err == nil {
+		t.Errorf("min mayor que max debería fallar")
+	}
+	if err := pool.Resize(6, 1, 8); err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if stats := pool.Stats(); stats.Workers != 6 || stats.Min != 1 || stats.Max != 8 {
+		t.Errorf("Estado inesperado: %+v", stats)
+	}
+	if err := pool.Resize(1, 0, 0); err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	esperarCondicion(t, "No se retiraron los workers que sobran", func() bool { return len(pool.snapshot()) == 1 })
+
+	pool.Stop()
+	if err := pool.Resize(3, 0, 0); err != nil || len(pool.snapshot()) != 0 {
+		t.Errorf("Después de Stop no se deben lanzar workers: %v %d", err, len(pool.snapshot()))
+	}
+}
+
+// TestAdminPools verifica /admin/pools y que el cambio se vea en /status
+func TestAdminPools(t *testing.T) {
+	server := NewServerWithRoutes([]string{"/sleep"})
+	for _, pool := range server.CommandPools {
+		pool.Start()
+		defer pool.Stop()
+	}
+
+	rec := serve(t, server, "POST /admin/pools?route=/sleep&size=5 HTTP/1.1\r\nContent-Length: 0\r\n\r\n")
+	var stats routes.PoolStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil || rec.Code != 200 {
+		t.Fatalf("Respuesta inesperada %d: %s", rec.Code, rec.Body.String())
+	}
+	if stats.Workers != 5 || stats.Min != 3 || stats.Max != 12 {
+		t.Errorf("Pool inesperado: %+v", stats)
+	}
+
+	var status struct {
+		Workers map[string][]map[string]interface{} `json:"workers"`
+		Pools   map[string]routes.PoolStats         `json:"pools"`
+	}
+	rec = serve(t, server, "GET /status HTTP/1.1\r\n\r\n")
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("JSON inválido: %v", err)
+	}
+	if len(status.Workers["/sleep"]) != 5 || status.Pools["/sleep"].Workers != 5 {
+		t.Errorf("/status no refleja el nuevo tamaño: %d workers, %+v", len(status.Workers["/sleep"]), status.Pools["/sleep"])
+	}
+	// Por GET solo se consulta
+	serve(t, server, "GET /admin/pools?route=/sleep&size=4 HTTP/1.1\r\n\r\n")
+	if n := server.CommandPools["/sleep"].Stats().Workers; n != 5 {
+		t.Errorf("GET cambió el pool a %d workers", n)
+	}
+
+	tests := []struct {
+		raw    string
+		status int
+	}{
+		{"GET /admin/pools HTTP/1.1\r\n\r\n", 200},
+		{"GET /admin/pools?route=/sleep HTTP/1.1\r\n\r\n", 200},
+		{"GET /admin/pools?route=/sleep&size=4 HTTP/1.1\r\n\r\n", 405},
+		{"POST /admin/pools?route=/sleep&size=20 HTTP/1.1\r\nContent-Length: 0\r\n\r\n", 400},
+		{"POST /admin/pools?route=/sleep&size=0 HTTP/1.1\r\nContent-Length: 0\r\n\r\n", 400},
+		{"POST /admin/pools?size=4 HTTP/1.1\r\nContent-Length: 0\r\n\r\n", 400},
+		{"POST /admin/pools?route=/noexiste&size=4 HTTP/1.1\r\nContent-Length: 0\r\n\r\n", 404},
+	}
+	for _, tt := range tests {
+		if rec := serve(t, server, tt.raw); rec.Code != tt.status {
+			t.Errorf("%q: esperado %d, obtenido %d (%s)", tt.raw, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// TestWorkerPool_StopRechazaCola verifica que Stop responda 503 a lo que quedó en cola
+func TestWorkerPool_StopRechazaCola(t *testing.T) {
+	pool := NewElasticPool(1, 1)
+	rec := httpmsg.NewRecorder()
+	req := Request{Writer: rec, Listo: make(chan bool), Ctx: context.Background()}
+	pool.Submit(req)
+	pool.Stop()
+
+	select {
+	case <-req.Listo:
+	default:
+		t.Fatalf("La solicitud en cola quedó esperando")
+	}
+	if rec.Code != 503 || pool.Stats().Queued != 0 {
+		t.Errorf("Esperado 503 y cola vacía, obtenido %d, %+v", rec.Code, pool.Stats())
+	}
+}
+
+// TestWorkerPool_SubmitDespuesDeStop verifica que una solicitud que llega después de Stop,
+// por ejemplo una que terminaba de leer su cuerpo, reciba 503 en vez de quedar esperando
+func TestWorkerPool_SubmitDespuesDeStop(t *testing.T) {
+	pool := NewElasticPool(1, 1)
+	pool.Start()
+	pool.Stop()
+
+	rec := httpmsg.NewRecorder()
+	req := Request{Writer: rec, Listo: make(chan bool), Ctx: context.Background()}
+	pool.Submit(req)
+	select {
+	case <-req.Listo:
+	case <-time.After(time.Second):
+		t.Fatalf("La solicitud quedó esperando un pool detenido")
+	}
+	if rec.Code != 503 || pool.Stats().Queued != 0 {
+		t.Errorf("Esperado 503 y cola vacía, obtenido %d, %+v", rec.Code, pool.Stats())
+	}
+}
@@ -0,0 +1,3 @@
+module http-shared
+
+go 1.18
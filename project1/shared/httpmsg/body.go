@@ -0,0 +1,117 @@
+package httpmsg
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// exactReader entrega exactamente Content-Length bytes del cuerpo
+type exactReader struct {
+	r         *bufio.Reader
+	remaining int64
+}
+
+func (e *exactReader) Read(p []byte) (int, error) {
+	if e.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > e.remaining {
+		p = p[:e.remaining]
+	}
+	n, err := e.r.Read(p)
+	e.remaining -= int64(n)
+	if err == io.EOF && e.remaining > 0 {
+		return n, badRequest("cuerpo incompleto: faltan %d bytes", e.remaining)
+	}
+	if e.remaining == 0 && err == nil {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// chunkedReader decodifica Transfer-Encoding: chunked sin pasar de MaxBodyBytes
+type chunkedReader struct {
+	r         *bufio.Reader
+	limits    Limits
+	remaining int64 // Bytes que aún caben en el límite del cuerpo
+	chunkLeft int64 // Bytes pendientes del chunk actual
+	done      bool
+	err       error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.done {
+		return 0, io.EOF
+	}
+
+	if c.chunkLeft == 0 {
+		size, err := c.nextChunkSize()
+		if err != nil {
+			c.err = err
+			return 0, err
+		}
+		if size == 0 {
+			// Último chunk: se leen (y descartan) los trailers
+			if err := readHeaders(c.r, make(Header), c.limits); err != nil {
+				c.err = err
+				return 0, err
+			}
+			c.done = true
+			return 0, io.EOF
+		}
+		if c.limits.MaxBodyBytes > 0 && size > c.remaining {
+			c.err = ErrBodyTooLarge
+			return 0, c.err
+		}
+		c.remaining -= size
+		c.chunkLeft = size
+	}
+
+	if int64(len(p)) > c.chunkLeft {
+		p = p[:c.chunkLeft]
+	}
+	n, err := c.r.Read(p)
+	c.chunkLeft -= int64(n)
+	if err == io.EOF {
+		c.err = badRequest("cuerpo chunked incompleto")
+		return n, c.err
+	}
+	if err != nil {
+		c.err = err
+		return n, err
+	}
+
+	if c.chunkLeft == 0 {
+		// Cada chunk termina con CRLF
+		line, err := readLine(c.r, 0, ErrBadRequest)
+		if err != nil || line != "" {
+			c.err = badRequest("falta CRLF al final del chunk")
+			return n, c.err
+		}
+	}
+	return n, nil
+}
+
+func (c *chunkedReader) nextChunkSize() (int64, error) {
+	line, err := readLine(c.r, c.limits.MaxLineBytes, ErrBadRequest)
+	if err != nil {
+		if err == io.EOF {
+			return 0, badRequest("cuerpo chunked incompleto")
+		}
+		return 0, err
+	}
+	// Se ignoran las extensiones de chunk (";nombre=valor")
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+	if err != nil || size < 0 {
+		return 0, badRequest("tamaño de chunk inválido: %q", line)
+	}
+	return size, nil
+}
@@ -0,0 +1,64 @@
+package httpmsg
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encabezados de plazo. El cliente pide un plazo relativo con Timeout (o el
+// parámetro timeout) y el dispatcher lo reenvía al worker como un instante absoluto.
+const (
+	TimeoutHeader  = "Timeout"
+	DeadlineHeader = "X-Request-Deadline"
+)
+
+// ParseTimeout acepta una duración de Go ("1500ms", "2s") o segundos enteros ("5")
+func ParseTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0, badRequest("timeout inválido %q, debe ser positivo", value)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, badRequest("timeout inválido %q, use segundos o una duración como 500ms", value)
+	}
+	return d, nil
+}
+
+// SetDeadline escribe el plazo en DeadlineHeader
+func SetDeadline(h Header, deadline time.Time) {
+	h.Set(DeadlineHeader, deadline.UTC().Format(time.RFC3339Nano))
+}
+
+// Deadline lee DeadlineHeader. ok es false si la solicitud no trae plazo.
+func Deadline(h Header) (deadline time.Time, ok bool, err error) {
+	value := h.Get(DeadlineHeader)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	deadline, err = time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false, badRequest("%s inválido %q", DeadlineHeader, value)
+	}
+	return deadline, true, nil
+}
+
+// WithDeadline retorna un contexto que vence en el plazo de la solicitud, o uno
+// cancelable sin plazo si no trae DeadlineHeader
+func WithDeadline(parent context.Context, h Header) (context.Context, context.CancelFunc, error) {
+	deadline, ok, err := Deadline(h)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		ctx, cancel := context.WithCancel(parent)
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithDeadline(parent, deadline)
+	return ctx, cancel, nil
+}
@@ -0,0 +1,53 @@
+// deadline_test.go
+package httpmsg
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestParseTimeout prueba los formatos aceptados y los inválidos
+func TestParseTimeout(t *testing.T) {
+	validos := map[string]time.Duration{"5": 5 * time.Second, "1500ms": 1500 * time.Millisecond, " 2s ": 2 * time.Second}
+	for value, expected := range validos {
+		d, err := ParseTimeout(value)
+		if err != nil || d != expected {
+			t.Errorf("ParseTimeout(%q) = %v, %v; esperado %v", value, d, err, expected)
+		}
+	}
+	for _, value := range []string{"", "0", "-3", "abc", "-1s"} {
+		if _, err := ParseTimeout(value); StatusOf(err) != 400 {
+			t.Errorf("ParseTimeout(%q) debería fallar con 400, obtenido %v", value, err)
+		}
+	}
+}
+
+// TestDeadline_IdaYVuelta verifica que el plazo escrito se lea igual y llegue al contexto
+func TestDeadline_IdaYVuelta(t *testing.T) {
+	h := Header{}
+	if _, ok, err := Deadline(h); ok || err != nil {
+		t.Fatalf("Sin encabezado no debería haber plazo: %v %v", ok, err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	SetDeadline(h, deadline)
+	got, ok, err := Deadline(h)
+	if err != nil || !ok || !got.Equal(deadline) {
+		t.Errorf("Plazo leído %v (%v, %v), esperado %v", got, ok, err, deadline)
+	}
+
+	ctx, cancel, err := WithDeadline(context.Background(), h)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(deadline) {
+		t.Errorf("El contexto no tiene el plazo del encabezado: %v", d)
+	}
+
+	h.Set(DeadlineHeader, "mañana")
+	if _, _, err := WithDeadline(context.Background(), h); StatusOf(err) != 400 {
+		t.Errorf("Un plazo inválido debería dar 400, obtenido %v", err)
+	}
+}
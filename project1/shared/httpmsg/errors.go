@@ -0,0 +1,78 @@
+package httpmsg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error es un error de protocolo que ya sabe con qué código HTTP responder
+type Error struct {
+	Status int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Is permite usar errors.Is contra los errores base comparando solo el código
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Status == e.Status
+}
+
+// Errores base. Los errores concretos llevan un mensaje más específico
+// pero siguen cumpliendo errors.Is contra estos.
+var (
+	ErrBadRequest       = &Error{Status: 400, Msg: "solicitud HTTP mal formada"}
+	ErrNotFound         = &Error{Status: 404, Msg: "Ruta no encontrada"}
+	ErrMethodNotAllowed = &Error{Status: 405, Msg: "Método no permitido para esta ruta"}
+	ErrBodyTooLarge     = &Error{Status: 413, Msg: "cuerpo de la solicitud demasiado grande"}
+	ErrHeaderTooLarge   = &Error{Status: 431, Msg: "encabezados de la solicitud demasiado grandes"}
+)
+
+func badRequest(format string, args ...interface{}) error {
+	return &Error{Status: 400, Msg: fmt.Sprintf(format, args...)}
+}
+
+// StatusOf retorna el código HTTP asociado a un error de lectura.
+// Cualquier error que no sea de protocolo se trata como 400.
+func StatusOf(err error) int {
+	var protoErr *Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Status
+	}
+	return 400
+}
+
+var statusText = map[int]string{
+	200: "OK",
+	201: "Created",
+	202: "Accepted",
+	204: "No Content",
+	206: "Partial Content",
+	400: "Bad Request",
+	404: "Not Found",
+	405: "Method Not Allowed",
+	408: "Request Timeout",
+	409: "Conflict",
+	413: "Payload Too Large",
+	416: "Range Not Satisfiable",
+	429: "Too Many Requests",
+	431: "Request Header Fields Too Large",
+	500: "Internal Server Error",
+	502: "Bad Gateway",
+	503: "Service Unavailable",
+	504: "Gateway Timeout",
+	507: "Insufficient Storage",
+}
+
+// StatusText retorna la frase estándar de un código HTTP
+func StatusText(code int) string {
+	return statusText[code]
+}
+
+// StatusLine arma el estado completo, por ejemplo "404 Not Found"
+func StatusLine(code int) string {
+	return fmt.Sprintf("%d %s", code, StatusText(code))
+}
@@ -0,0 +1,76 @@
+package httpmsg
+
+import "strings"
+
+// Header guarda los encabezados HTTP con llaves en minúsculas para que la
+// búsqueda no dependa de cómo las escribió el cliente ("Content-Length" y
+// "content-length" son el mismo encabezado). Un encabezado puede tener varios valores.
+type Header map[string][]string
+
+// CanonicalKey normaliza el nombre de un encabezado
+func CanonicalKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+// DisplayKey formatea el nombre para escribirlo en la red, ej: content-length -> Content-Length
+func DisplayKey(key string) string {
+	b := []byte(CanonicalKey(key))
+	upper := true
+	for i, c := range b {
+		if upper && c >= 'a' && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		}
+		upper = c == '-'
+	}
+	return string(b)
+}
+
+// Add agrega un valor sin borrar los existentes
+func (h Header) Add(key, value string) {
+	key = CanonicalKey(key)
+	h[key] = append(h[key], value)
+}
+
+// Set reemplaza todos los valores del encabezado
+func (h Header) Set(key, value string) {
+	h[CanonicalKey(key)] = []string{value}
+}
+
+// Get retorna el primer valor del encabezado o "" si no existe
+func (h Header) Get(key string) string {
+	values := h[CanonicalKey(key)]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Values retorna todos los valores del encabezado en el orden recibido
+func (h Header) Values(key string) []string {
+	return h[CanonicalKey(key)]
+}
+
+// Has indica si el encabezado está presente
+func (h Header) Has(key string) bool {
+	_, ok := h[CanonicalKey(key)]
+	return ok
+}
+
+// Del elimina el encabezado
+func (h Header) Del(key string) {
+	delete(h, CanonicalKey(key))
+}
+
+// tokens separa un encabezado de lista ("gzip, chunked") en sus elementos en minúsculas
+func (h Header) tokens(key string) []string {
+	var result []string
+	for _, value := range h.Values(key) {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.ToLower(strings.TrimSpace(part))
+			if part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,190 @@
+package httpmsg
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Values guarda los parámetros de la query ya decodificados.
+// Una llave puede repetirse y sus valores se conservan en el orden recibido.
+type Values map[string][]string
+
+// Get retorna el primer valor de la llave o "" si no existe
+func (v Values) Get(key string) string {
+	values := v[key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// All retorna todos los valores de la llave
+func (v Values) All(key string) []string {
+	return v[key]
+}
+
+// Has indica si la llave apareció en la query, aunque sea sin valor
+func (v Values) Has(key string) bool {
+	_, ok := v[key]
+	return ok
+}
+
+// Add agrega un valor al final de la llave
+func (v Values) Add(key, value string) {
+	v[key] = append(v[key], value)
+}
+
+// Set reemplaza los valores de la llave
+func (v Values) Set(key, value string) {
+	v[key] = []string{value}
+}
+
+// Map retorna el primer valor de cada llave, para los handlers que no usan valores repetidos
+func (v Values) Map() map[string]string {
+	result := make(map[string]string, len(v))
+	for key := range v {
+		result[key] = v.Get(key)
+	}
+	return result
+}
+
+// Encode arma la query codificada, con las llaves en orden alfabético
+func (v Values) Encode() string {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range v[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(Escape(key))
+			b.WriteByte('=')
+			b.WriteString(Escape(value))
+		}
+	}
+	return b.String()
+}
+
+// FromMap convierte un mapa simple de parámetros en Values
+func FromMap(params map[string]string) Values {
+	v := make(Values, len(params))
+	for key, value := range params {
+		v.Set(key, value)
+	}
+	return v
+}
+
+// ParseQuery decodifica una query como "text=hola%20mundo&tag=a&tag=b".
+// Solo se parte cada par en el primer '=', así los valores pueden contener '='.
+func ParseQuery(raw string) (Values, error) {
+	v := make(Values)
+	if raw == "" {
+		return v, nil
+	}
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue := pair, ""
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			rawKey, rawValue = pair[:i], pair[i+1:]
+		}
+		key, err := Unescape(rawKey)
+		if err != nil {
+			return nil, err
+		}
+		value, err := Unescape(rawValue)
+		if err != nil {
+			return nil, err
+		}
+		v.Add(key, value)
+	}
+	return v, nil
+}
+
+// ParseRoute separa la ruta de los parámetros decodificados de la query
+func ParseRoute(target string) (string, Values, error) {
+	route, rawQuery := target, ""
+	if i := strings.IndexByte(target, '?'); i >= 0 {
+		route, rawQuery = target[:i], target[i+1:]
+	}
+	params, err := ParseQuery(rawQuery)
+	if err != nil {
+		return route, nil, err
+	}
+	return route, params, nil
+}
+
+// Unescape decodifica %XX y '+' (espacio) y verifica que el resultado sea UTF-8 válido
+func Unescape(s string) (string, error) {
+	if strings.IndexByte(s, '%') < 0 && strings.IndexByte(s, '+') < 0 {
+		if !utf8.ValidString(s) {
+			return "", badRequest("parámetro con UTF-8 inválido: %q", s)
+		}
+		return s, nil
+	}
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '+':
+			b = append(b, ' ')
+		case '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return "", badRequest("secuencia de escape inválida en %q", s)
+			}
+			b = append(b, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+		default:
+			b = append(b, c)
+		}
+	}
+	if !utf8.Valid(b) {
+		return "", badRequest("parámetro con UTF-8 inválido: %q", s)
+	}
+	return string(b), nil
+}
+
+// Escape codifica un valor para usarlo dentro de una query.
+// Solo se dejan sin codificar los caracteres no reservados de RFC 3986.
+func Escape(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isUnreserved(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,76 @@
+// query_test.go
+package httpmsg
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestParseRoute_Decodifica prueba la decodificación de %XX y '+'
+func TestParseRoute_Decodifica(t *testing.T) {
+	route, params, err := ParseRoute("/toupper?text=hola%20mundo&otro=a+b&acento=%C3%B1")
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if route != "/toupper" {
+		t.Errorf("Ruta inesperada: %s", route)
+	}
+	if params.Get("text") != "hola mundo" || params.Get("otro") != "a b" || params.Get("acento") != "ñ" {
+		t.Errorf("Parámetros mal decodificados: %v", params)
+	}
+}
+
+// TestParseQuery_ValoresConIgual verifica que los valores puedan contener '=' (ej. base64)
+func TestParseQuery_ValoresConIgual(t *testing.T) {
+	params, err := ParseQuery("text=aG9sYQ==&url=worker1%3A8080")
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if params.Get("text") != "aG9sYQ==" {
+		t.Errorf("Esperado 'aG9sYQ==', obtenido %q", params.Get("text"))
+	}
+	if params.Get("url") != "worker1:8080" {
+		t.Errorf("Esperado 'worker1:8080', obtenido %q", params.Get("url"))
+	}
+}
+
+// TestParseQuery_LlavesRepetidas verifica que se conserven todos los valores en orden
+func TestParseQuery_LlavesRepetidas(t *testing.T) {
+	params, err := ParseQuery("tag=b&tag=a&vacio&tag=c")
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	tags := params.All("tag")
+	if len(tags) != 3 || tags[0] != "b" || tags[1] != "a" || tags[2] != "c" {
+		t.Errorf("Valores repetidos inesperados: %v", tags)
+	}
+	if !params.Has("vacio") || params.Get("vacio") != "" {
+		t.Errorf("La llave sin valor debería existir vacía: %v", params)
+	}
+}
+
+// TestParseQuery_Invalidos verifica los errores de escape y de UTF-8
+func TestParseQuery_Invalidos(t *testing.T) {
+	for _, raw := range []string{"a=%zz", "a=%4", "a=%ff%fe"} {
+		_, err := ParseQuery(raw)
+		if !errors.Is(err, ErrBadRequest) {
+			t.Errorf("Se esperaba 400 para %q, obtenido %v", raw, err)
+		}
+	}
+}
+
+// TestValues_Encode verifica que Encode y ParseQuery sean inversos
+func TestValues_Encode(t *testing.T) {
+	original := Values{"text": {"hola mundo", "a&b=c"}, "name": {"ñandú.txt"}}
+	encoded := original.Encode()
+	if encoded != "name=%C3%B1and%C3%BA.txt&text=hola%20mundo&text=a%26b%3Dc" {
+		t.Errorf("Codificación inesperada: %s", encoded)
+	}
+	decoded, err := ParseQuery(encoded)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if len(decoded.All("text")) != 2 || decoded.All("text")[1] != "a&b=c" || decoded.Get("name") != "ñandú.txt" {
+		t.Errorf("Ida y vuelta inesperada: %v", decoded)
+	}
+}
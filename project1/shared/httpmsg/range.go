@@ -0,0 +1,76 @@
+package httpmsg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrRangeNotSatisfiable indica que el rango pedido queda fuera del recurso
+var ErrRangeNotSatisfiable = &Error{Status: 416, Msg: "Rango no satisfacible"}
+
+// ByteRange es un rango de bytes inclusivo, como en "bytes=0-99"
+type ByteRange struct {
+	Start, End int64
+}
+
+// Length retorna la cantidad de bytes del rango
+func (r ByteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ContentRange arma el valor del encabezado Content-Range de una respuesta 206
+func (r ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)
+}
+
+// ParseRange interpreta el encabezado Range para un recurso de size bytes. Acepta
+// "bytes=a-b", "bytes=a-" y "bytes=-n". ok es false si no hay encabezado o si no se
+// entiende (incluidos varios rangos); en ese caso se responde el recurso completo.
+// Un rango que empieza después del final retorna ErrRangeNotSatisfiable.
+func ParseRange(header string, size int64) (r ByteRange, ok bool, err error) {
+	spec := strings.TrimSpace(header)
+	if !strings.HasPrefix(spec, "bytes=") || strings.Contains(spec, ",") {
+		return ByteRange{}, false, nil
+	}
+	spec = strings.TrimSpace(strings.TrimPrefix(spec, "bytes="))
+	dash := strings.IndexByte(spec, '-')
+	if dash < 0 {
+		return ByteRange{}, false, nil
+	}
+	first, last := spec[:dash], spec[dash+1:]
+
+	if first == "" {
+		// Sufijo: los últimos n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return ByteRange{}, false, nil
+		}
+		if n == 0 || size == 0 {
+			return ByteRange{}, false, ErrRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return ByteRange{Start: size - n, End: size - 1}, true, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return ByteRange{}, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return ByteRange{}, false, nil
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return ByteRange{}, false, ErrRangeNotSatisfiable
+	}
+	return ByteRange{Start: start, End: end}, true, nil
+}
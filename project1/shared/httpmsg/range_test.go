@@ -0,0 +1,47 @@
+// range_test.go
+package httpmsg
+
+import "testing"
+
+// TestParseRange prueba las formas de Range que se aceptan y las que se ignoran
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header string
+		ok     bool
+		want   ByteRange
+	}{
+		{"bytes=0-4", true, ByteRange{0, 4}},
+		{"bytes=5-", true, ByteRange{5, 9}},
+		{"bytes=-3", true, ByteRange{7, 9}},
+		{"bytes=-30", true, ByteRange{0, 9}},
+		{"bytes=8-100", true, ByteRange{8, 9}},
+		{"", false, ByteRange{}},
+		{"bytes=0-1,4-5", false, ByteRange{}},
+		{"items=0-1", false, ByteRange{}},
+		{"bytes=5-2", false, ByteRange{}},
+		{"bytes=a-b", false, ByteRange{}},
+	}
+	for _, tt := range tests {
+		r, ok, err := ParseRange(tt.header, 10)
+		if err != nil || ok != tt.ok || r != tt.want {
+			t.Errorf("ParseRange(%q): obtenido %v %v %v, esperado %v %v", tt.header, r, ok, err, tt.want, tt.ok)
+		}
+	}
+
+	r, _, _ := ParseRange("bytes=2-5", 10)
+	if r.Length() != 4 || r.ContentRange(10) != "bytes 2-5/10" {
+		t.Errorf("Rango inesperado: %d %s", r.Length(), r.ContentRange(10))
+	}
+}
+
+// TestParseRange_NoSatisfacible prueba los rangos fuera del recurso
+func TestParseRange_NoSatisfacible(t *testing.T) {
+	for _, header := range []string{"bytes=10-", "bytes=20-30", "bytes=-0"} {
+		if _, _, err := ParseRange(header, 10); StatusOf(err) != 416 || err == nil {
+			t.Errorf("ParseRange(%q): esperado 416, obtenido %v", header, err)
+		}
+	}
+	if _, _, err := ParseRange("bytes=-5", 0); err == nil {
+		t.Errorf("Un sufijo sobre un recurso vacío no es satisfacible")
+	}
+}
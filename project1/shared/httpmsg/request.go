@@ -0,0 +1,249 @@
+// Package httpmsg contiene el parser HTTP/1.1 compartido por el dispatcher y los workers.
+package httpmsg
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Limits define los tamaños máximos aceptados al leer una solicitud
+type Limits struct {
+	MaxLineBytes   int   // Línea de solicitud y cada línea de encabezado
+	MaxHeaderBytes int   // Total del bloque de encabezados
+	MaxBodyBytes   int64 // Cuerpo ya decodificado
+}
+
+// DefaultLimits son los límites usados por el dispatcher y los workers
+var DefaultLimits = Limits{
+	MaxLineBytes:   8 << 10,
+	MaxHeaderBytes: 64 << 10,
+	MaxBodyBytes:   32 << 20,
+}
+
+// Request es una solicitud HTTP ya parseada
+type Request struct {
+	Method     string
+	Target     string // request-target tal como llegó, ej: /fibonacci?num=10
+	Path       string // Target sin la query
+	RawQuery   string // Lo que sigue al '?', sin decodificar
+	Proto      string // ej: HTTP/1.1
+	ProtoMajor int
+	ProtoMinor int
+	Header     Header
+
+	// ContentLength es -1 cuando el cuerpo viene en chunks
+	ContentLength int64
+	Chunked       bool
+	Body          io.Reader
+}
+
+// ReadRequest lee una solicitud completa (línea, encabezados y preparación del cuerpo).
+// Si la conexión se cierra antes de recibir un solo byte retorna io.EOF.
+func ReadRequest(r *bufio.Reader, limits Limits) (*Request, error) {
+	// Se toleran líneas vacías antes de la solicitud (RFC 9112, sección 2.2)
+	var line string
+	for {
+		var err error
+		line, err = readLine(r, limits.MaxLineBytes, ErrBadRequest)
+		if err != nil {
+			return nil, err
+		}
+		if line != "" {
+			break
+		}
+	}
+
+	req := &Request{Header: make(Header)}
+	if err := req.parseRequestLine(line); err != nil {
+		return nil, err
+	}
+
+	if err := readHeaders(r, req.Header, limits); err != nil {
+		return nil, err
+	}
+
+	if err := req.setupBody(r, limits); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (req *Request) parseRequestLine(line string) error {
+	parts := strings.Split(line, " ")
+	if len(parts) != 3 {
+		return badRequest("línea de solicitud inválida: %q", line)
+	}
+	req.Method, req.Target, req.Proto = parts[0], parts[1], parts[2]
+
+	if !isToken(req.Method) {
+		return badRequest("método inválido: %q", req.Method)
+	}
+	if !strings.HasPrefix(req.Target, "/") {
+		return badRequest("destino inválido: %q", req.Target)
+	}
+
+	major, minor, ok := parseVersion(req.Proto)
+	if !ok || major != 1 {
+		return badRequest("versión HTTP no soportada: %q", req.Proto)
+	}
+	req.ProtoMajor, req.ProtoMinor = major, minor
+
+	req.Path, req.RawQuery = req.Target, ""
+	if i := strings.IndexByte(req.Target, '?'); i >= 0 {
+		req.Path, req.RawQuery = req.Target[:i], req.Target[i+1:]
+	}
+	return nil
+}
+
+// readHeaders lee encabezados hasta la línea vacía. Se usa también para los trailers de chunked.
+func readHeaders(r *bufio.Reader, header Header, limits Limits) error {
+	total := 0
+	for {
+		line, err := readLine(r, limits.MaxLineBytes, ErrHeaderTooLarge)
+		if err != nil {
+			if err == io.EOF {
+				return badRequest("conexión cerrada antes de terminar los encabezados")
+			}
+			return err
+		}
+		if line == "" {
+			return nil
+		}
+
+		total += len(line)
+		if limits.MaxHeaderBytes > 0 && total > limits.MaxHeaderBytes {
+			return ErrHeaderTooLarge
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			return badRequest("encabezados multilínea no soportados")
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 || !isToken(line[:colon]) {
+			return badRequest("encabezado inválido: %q", line)
+		}
+		header.Add(line[:colon], strings.TrimSpace(line[colon+1:]))
+	}
+}
+
+// setupBody decide cómo se delimita el cuerpo según Transfer-Encoding y Content-Length
+func (req *Request) setupBody(r *bufio.Reader, limits Limits) error {
+	encodings := req.Header.tokens("Transfer-Encoding")
+	lengths := req.Header.Values("Content-Length")
+
+	if len(encodings) > 0 {
+		if len(lengths) > 0 {
+			return badRequest("Transfer-Encoding y Content-Length juntos no están permitidos")
+		}
+		if encodings[len(encodings)-1] != "chunked" || len(encodings) > 1 {
+			return badRequest("Transfer-Encoding no soportado: %s", strings.Join(encodings, ", "))
+		}
+		req.Chunked = true
+		req.ContentLength = -1
+		req.Body = &chunkedReader{r: r, limits: limits, remaining: limits.MaxBodyBytes}
+		return nil
+	}
+
+	if len(lengths) == 0 {
+		req.Body = bytes.NewReader(nil)
+		return nil
+	}
+
+	length, err := parseContentLength(lengths)
+	if err != nil {
+		return err
+	}
+	if limits.MaxBodyBytes > 0 && length > limits.MaxBodyBytes {
+		return ErrBodyTooLarge
+	}
+	req.ContentLength = length
+	req.Body = &exactReader{r: r, remaining: length}
+	return nil
+}
+
+// ReadBody lee el cuerpo completo de la solicitud
+func (req *Request) ReadBody() ([]byte, error) {
+	return io.ReadAll(req.Body)
+}
+
+// Discard consume lo que quede del cuerpo para dejar el lector listo para la siguiente solicitud
+func (req *Request) Discard() error {
+	_, err := io.Copy(io.Discard, req.Body)
+	return err
+}
+
+func parseContentLength(values []string) (int64, error) {
+	var length int64 = -1
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			n, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || n < 0 {
+				return 0, badRequest("Content-Length inválido: %q", value)
+			}
+			if length != -1 && n != length {
+				return 0, badRequest("Content-Length con valores distintos")
+			}
+			length = n
+		}
+	}
+	return length, nil
+}
+
+func parseVersion(proto string) (major, minor int, ok bool) {
+	if !strings.HasPrefix(proto, "HTTP/") || len(proto) != len("HTTP/1.1") || proto[6] != '.' {
+		return 0, 0, false
+	}
+	major, minor = int(proto[5]-'0'), int(proto[7]-'0')
+	if major < 0 || major > 9 || minor < 0 || minor > 9 {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// readLine lee una línea terminada en LF (con o sin CR) sin pasar de max bytes
+func readLine(r *bufio.Reader, max int, tooLong error) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if max > 0 && len(line) > max+2 {
+			return "", tooLong
+		}
+		if err == nil {
+			break
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			return "", badRequest("conexión cerrada a mitad de una línea")
+		}
+		return "", err
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if max > 0 && len(line) > max {
+		return "", tooLong
+	}
+	return string(line), nil
+}
+
+// isToken valida los caracteres permitidos en métodos y nombres de encabezado (RFC 9110)
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
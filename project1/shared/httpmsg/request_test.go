@@ -0,0 +1,131 @@
+// request_test.go
+package httpmsg
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func read(raw string, limits Limits) (*Request, error) {
+	return ReadRequest(bufio.NewReader(strings.NewReader(raw)), limits)
+}
+
+// TestReadRequest_GetConQuery prueba una solicitud GET simple
+func TestReadRequest_GetConQuery(t *testing.T) {
+	req, err := read("GET /fibonacci?num=10 HTTP/1.1\r\nHost: worker1\r\n\r\n", DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if req.Method != "GET" || req.Path != "/fibonacci" || req.RawQuery != "num=10" {
+		t.Errorf("Solicitud mal parseada: %+v", req)
+	}
+	if req.ProtoMajor != 1 || req.ProtoMinor != 1 {
+		t.Errorf("Versión inesperada: %d.%d", req.ProtoMajor, req.ProtoMinor)
+	}
+	body, _ := req.ReadBody()
+	if len(body) != 0 {
+		t.Errorf("Se esperaba cuerpo vacío, obtenido %q", body)
+	}
+}
+
+// TestReadRequest_HeadersSinMayusculas verifica que los encabezados no dependan de mayúsculas
+// y que se conserven los valores repetidos
+func TestReadRequest_HeadersSinMayusculas(t *testing.T) {
+	raw := "POST /countchunk HTTP/1.1\r\nCONTENT-length: 5\r\nX-Tag: a\r\nx-tag: b\r\n\r\nhola!"
+	req, err := read(raw, DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if req.Header.Get("Content-Length") != "5" || req.Header.Get("content-length") != "5" {
+		t.Errorf("Content-Length no encontrado: %v", req.Header)
+	}
+	if got := req.Header.Values("X-TAG"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("Valores repetidos perdidos: %v", got)
+	}
+	body, err := req.ReadBody()
+	if err != nil || string(body) != "hola!" {
+		t.Errorf("Cuerpo inesperado %q (err: %v)", body, err)
+	}
+}
+
+// TestReadRequest_Chunked prueba la decodificación de Transfer-Encoding: chunked
+func TestReadRequest_Chunked(t *testing.T) {
+	raw := "POST /countchunk HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"4\r\nhola\r\n6;ext=1\r\n mundo\r\n0\r\nX-Trailer: si\r\n\r\n" +
+		"GET /ping HTTP/1.1\r\n\r\n"
+	r := bufio.NewReader(strings.NewReader(raw))
+	req, err := ReadRequest(r, DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	body, err := req.ReadBody()
+	if err != nil || string(body) != "hola mundo" {
+		t.Fatalf("Cuerpo inesperado %q (err: %v)", body, err)
+	}
+
+	// La siguiente solicitud debe quedar intacta en el lector
+	next, err := ReadRequest(r, DefaultLimits)
+	if err != nil || next.Path != "/ping" {
+		t.Errorf("No se pudo leer la siguiente solicitud: %v", err)
+	}
+}
+
+// TestReadRequest_EOF verifica que una conexión cerrada sin datos retorne io.EOF
+func TestReadRequest_EOF(t *testing.T) {
+	if _, err := read("", DefaultLimits); err != io.EOF {
+		t.Errorf("Se esperaba io.EOF, obtenido %v", err)
+	}
+}
+
+// TestReadRequest_Errores verifica el código HTTP de cada error de protocolo
+func TestReadRequest_Errores(t *testing.T) {
+	small := Limits{MaxLineBytes: 32, MaxHeaderBytes: 48, MaxBodyBytes: 8}
+	tests := []struct {
+		name   string
+		raw    string
+		status int
+		base   error
+	}{
+		{"línea incompleta", "GET /ping\r\n\r\n", 400, ErrBadRequest},
+		{"versión inválida", "GET /ping HTTP/2.0\r\n\r\n", 400, ErrBadRequest},
+		{"encabezado sin dos puntos", "GET /ping HTTP/1.1\r\nHost\r\n\r\n", 400, ErrBadRequest},
+		{"content-length inválido", "POST /x HTTP/1.1\r\nContent-Length: -1\r\n\r\n", 400, ErrBadRequest},
+		{"content-length distintos", "POST /x HTTP/1.1\r\nContent-Length: 1\r\nContent-Length: 2\r\n\r\n", 400, ErrBadRequest},
+		{"te y cl juntos", "POST /x HTTP/1.1\r\nContent-Length: 1\r\nTransfer-Encoding: chunked\r\n\r\n", 400, ErrBadRequest},
+		{"línea de encabezado larga", "GET /ping HTTP/1.1\r\nX: " + strings.Repeat("a", 40) + "\r\n\r\n", 431, ErrHeaderTooLarge},
+		{"bloque de encabezados grande", "GET /ping HTTP/1.1\r\nA: 12345678901234\r\nB: 12345678901234\r\nC: 12345678901234\r\n\r\n", 431, ErrHeaderTooLarge},
+		{"cuerpo grande", "POST /x HTTP/1.1\r\nContent-Length: 9\r\n\r\n123456789", 413, ErrBodyTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := read(tt.raw, small)
+			if err == nil {
+				t.Fatalf("Se esperaba error")
+			}
+			if StatusOf(err) != tt.status {
+				t.Errorf("Esperado status %d, obtenido %d (%v)", tt.status, StatusOf(err), err)
+			}
+			if !errors.Is(err, tt.base) {
+				t.Errorf("El error %v no cumple errors.Is con %v", err, tt.base)
+			}
+		})
+	}
+}
+
+// TestReadRequest_ChunkedDemasiadoGrande verifica el límite del cuerpo con chunked
+func TestReadRequest_ChunkedDemasiadoGrande(t *testing.T) {
+	limits := Limits{MaxLineBytes: 64, MaxHeaderBytes: 256, MaxBodyBytes: 6}
+	raw := "POST /x HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n4\r\nhola\r\n4\r\nhola\r\n0\r\n\r\n"
+	req, err := read(raw, limits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	_, err = req.ReadBody()
+	if StatusOf(err) != 413 {
+		t.Errorf("Esperado 413, obtenido %v", err)
+	}
+}
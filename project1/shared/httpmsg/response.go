@@ -0,0 +1,145 @@
+package httpmsg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Response es la respuesta de un worker ya leída por completo
+type Response struct {
+	Proto      string
+	StatusCode int
+	Reason     string
+	Header     Header
+	Body       []byte
+
+	// Close indica que la conexión no puede reutilizarse después de esta respuesta
+	Close bool
+}
+
+// KeepAlive indica si el cliente quiere mantener la conexión abierta.
+// HTTP/1.1 es persistente salvo "Connection: close"; HTTP/1.0 solo con "Connection: keep-alive".
+func (req *Request) KeepAlive() bool {
+	return keepAlive(req.ProtoMinor, req.Header)
+}
+
+func keepAlive(protoMinor int, header Header) bool {
+	tokens := header.tokens("Connection")
+	if containsToken(tokens, "close") {
+		return false
+	}
+	if protoMinor == 0 {
+		return containsToken(tokens, "keep-alive")
+	}
+	return true
+}
+
+func containsToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteRequest escribe una solicitud HTTP/1.1 con Content-Length calculado a partir del cuerpo
+func WriteRequest(w io.Writer, method, target string, header Header, body []byte) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", method, target)
+	for key, values := range header {
+		if key == "content-length" || key == "transfer-encoding" {
+			continue
+		}
+		for _, value := range values {
+			fmt.Fprintf(&b, "%s: %s\r\n", DisplayKey(key), value)
+		}
+	}
+	if len(body) > 0 || method == "POST" || method == "PUT" {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(body))
+	}
+	b.WriteString("\r\n")
+	b.Write(body)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// ReadResponse lee una respuesta completa, incluyendo el cuerpo
+func ReadResponse(r *bufio.Reader, limits Limits) (*Response, error) {
+	line, err := readLine(r, limits.MaxLineBytes, ErrBadRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{Header: make(Header)}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		return nil, badRequest("línea de estado inválida: %q", line)
+	}
+	resp.Proto = parts[0]
+	major, minor, ok := parseVersion(resp.Proto)
+	if !ok || major != 1 {
+		return nil, badRequest("versión HTTP no soportada: %q", resp.Proto)
+	}
+	resp.StatusCode, err = strconv.Atoi(parts[1])
+	if err != nil || resp.StatusCode < 100 || resp.StatusCode > 999 {
+		return nil, badRequest("código de estado inválido: %q", parts[1])
+	}
+	if len(parts) == 3 {
+		resp.Reason = parts[2]
+	}
+
+	if err := readHeaders(r, resp.Header, limits); err != nil {
+		return nil, err
+	}
+	resp.Close = !keepAlive(minor, resp.Header)
+
+	// Sin Content-Length ni chunked el cuerpo termina cuando se cierra la conexión
+	holder := &Request{Header: resp.Header}
+	if !resp.Header.Has("Content-Length") && !resp.Header.Has("Transfer-Encoding") {
+		resp.Close = true
+		resp.Body, err = io.ReadAll(limitedBody(r, limits.MaxBodyBytes))
+		return resp, err
+	}
+	if err := holder.setupBody(r, limits); err != nil {
+		return nil, err
+	}
+	resp.Body, err = holder.ReadBody()
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func limitedBody(r io.Reader, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &maxReader{r: r, remaining: max}
+}
+
+// maxReader falla con ErrBodyTooLarge en vez de truncar en silencio
+type maxReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxReader) Read(p []byte) (int, error) {
+	if m.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
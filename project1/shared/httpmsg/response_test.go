@@ -0,0 +1,85 @@
+// response_test.go
+package httpmsg
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestReadResponse_ContentLength prueba dos respuestas seguidas en la misma conexión
+func TestReadResponse_ContentLength(t *testing.T) {
+	raw := "HTTP/1.1 200 OK\r\nContent-Length: 4\r\n\r\npong" +
+		"HTTP/1.1 404 Not Found\r\nContent-Length: 2\r\nConnection: close\r\n\r\nno"
+	r := bufio.NewReader(strings.NewReader(raw))
+
+	first, err := ReadResponse(r, DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if first.StatusCode != 200 || string(first.Body) != "pong" || first.Close {
+		t.Errorf("Primera respuesta inesperada: %+v", first)
+	}
+
+	second, err := ReadResponse(r, DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if second.StatusCode != 404 || second.Reason != "Not Found" || string(second.Body) != "no" || !second.Close {
+		t.Errorf("Segunda respuesta inesperada: %+v", second)
+	}
+}
+
+// TestReadResponse_HastaCierre prueba una respuesta HTTP/1.0 sin Content-Length
+func TestReadResponse_HastaCierre(t *testing.T) {
+	raw := "HTTP/1.0 200 OK\r\nContent-Type: text/plain\r\n\r\nhasta el final"
+	resp, err := ReadResponse(bufio.NewReader(strings.NewReader(raw)), DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if string(resp.Body) != "hasta el final" || !resp.Close {
+		t.Errorf("Respuesta inesperada: %+v", resp)
+	}
+}
+
+// TestWriteRequest verifica que la solicitud escrita se pueda volver a parsear
+func TestWriteRequest(t *testing.T) {
+	var buf bytes.Buffer
+	header := Header{}
+	header.Set("Host", "worker1")
+	if err := WriteRequest(&buf, "POST", "/countchunk", header, []byte("uno dos")); err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+
+	req, err := ReadRequest(bufio.NewReader(&buf), DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	body, _ := req.ReadBody()
+	if req.Header.Get("host") != "worker1" || string(body) != "uno dos" || !req.KeepAlive() {
+		t.Errorf("Solicitud inesperada: %+v, cuerpo %q", req, body)
+	}
+}
+
+// TestKeepAlive verifica las reglas de conexión persistente de HTTP/1.0 y HTTP/1.1
+func TestKeepAlive(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"GET / HTTP/1.1\r\n\r\n", true},
+		{"GET / HTTP/1.1\r\nConnection: close\r\n\r\n", false},
+		{"GET / HTTP/1.0\r\n\r\n", false},
+		{"GET / HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n", true},
+	}
+	for _, tt := range tests {
+		req, err := read(tt.raw, DefaultLimits)
+		if err != nil {
+			t.Fatalf("Error inesperado: %v", err)
+		}
+		if req.KeepAlive() != tt.want {
+			t.Errorf("KeepAlive(%q) = %v, esperado %v", tt.raw, req.KeepAlive(), tt.want)
+		}
+	}
+}
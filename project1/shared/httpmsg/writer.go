@@ -0,0 +1,252 @@
+package httpmsg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ResponseWriter es lo que reciben los handlers para armar la respuesta.
+// Los encabezados se pueden modificar hasta la primera llamada a WriteHeader o Write.
+type ResponseWriter interface {
+	Header() Header
+	WriteHeader(status int)
+	Write(p []byte) (int, error)
+}
+
+// Flusher lo implementan los writers que pueden enviar lo escrito antes de terminar
+type Flusher interface {
+	Flush() error
+}
+
+// Text responde con un cuerpo de texto plano
+func Text(w ResponseWriter, status int, body string) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+// JSON serializa v y responde con Content-Type application/json
+func JSON(w ResponseWriter, status int, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		Text(w, 500, "Error generando JSON")
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	return err
+}
+
+// MaxBufferedBody es lo que se acumula antes de pasar a Transfer-Encoding: chunked
+const MaxBufferedBody = 64 << 10
+
+// Writer escribe la respuesta en la conexión. Si el handler termina sin pasar de
+// MaxBufferedBody se envía con Content-Length; si escribe más o llama a Flush se
+// usa Transfer-Encoding: chunked (o cierre de conexión si el cliente es HTTP/1.0).
+type Writer struct {
+	conn      *bufio.Writer
+	header    Header
+	status    int
+	buf       bytes.Buffer
+	committed bool // Ya se enviaron la línea de estado y los encabezados
+	chunked   bool
+	http10    bool
+	keepAlive bool
+	finished  bool
+	err       error
+}
+
+// NewWriter crea el writer de la respuesta a req. Con req nil (solicitud ilegible)
+// la respuesta es HTTP/1.1 y la conexión se cierra.
+func NewWriter(conn io.Writer, req *Request) *Writer {
+	w := &Writer{conn: bufio.NewWriter(conn), header: make(Header)}
+	if req != nil {
+		w.http10 = req.ProtoMinor == 0
+		w.keepAlive = req.KeepAlive()
+	}
+	return w
+}
+
+func (w *Writer) Header() Header {
+	return w.header
+}
+
+// WriteHeader fija el código de estado. Solo cuenta la primera llamada.
+func (w *Writer) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Status retorna el código que se envió (o se enviará)
+func (w *Writer) Status() int {
+	if w.status == 0 {
+		return 200
+	}
+	return w.status
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.WriteHeader(200)
+	if !w.committed {
+		w.buf.Write(p)
+		if w.buf.Len() > MaxBufferedBody {
+			w.commit(false)
+		}
+		return len(p), w.err
+	}
+	w.writeBody(p)
+	return len(p), w.err
+}
+
+// Flush envía los encabezados y lo escrito hasta ahora sin esperar al final del handler
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.WriteHeader(200)
+	if !w.committed {
+		w.commit(false)
+	}
+	if w.err == nil {
+		w.err = w.conn.Flush()
+	}
+	return w.err
+}
+
+// Finish termina la respuesta. Se llama una vez cuando el handler retorna.
+func (w *Writer) Finish() error {
+	if w.finished {
+		return w.err
+	}
+	w.finished = true
+	w.WriteHeader(200)
+	if !w.committed {
+		w.commit(true)
+	} else if w.chunked && w.err == nil {
+		_, w.err = w.conn.WriteString("0\r\n\r\n")
+	}
+	if w.err == nil {
+		w.err = w.conn.Flush()
+	}
+	return w.err
+}
+
+// KeepAlive indica si la conexión puede atender otra solicitud después de esta respuesta
+func (w *Writer) KeepAlive() bool {
+	return w.keepAlive && w.err == nil
+}
+
+// commit envía la línea de estado y los encabezados. final indica que el cuerpo
+// ya está completo en el buffer y se puede usar Content-Length.
+func (w *Writer) commit(final bool) {
+	w.committed = true
+	if w.header.Get("Content-Type") == "" {
+		w.header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	switch {
+	case final:
+		w.header.Set("Content-Length", fmt.Sprint(w.buf.Len()))
+	case w.http10:
+		// HTTP/1.0 no tiene chunked: el fin del cuerpo se marca cerrando la conexión
+		w.keepAlive = false
+		w.header.Del("Content-Length")
+	default:
+		w.chunked = true
+		w.header.Del("Content-Length")
+		w.header.Set("Transfer-Encoding", "chunked")
+	}
+
+	// El handler puede pedir cerrar la conexión, por ejemplo cuando el worker se está apagando
+	if strings.EqualFold(w.header.Get("Connection"), "close") {
+		w.keepAlive = false
+	}
+	if !w.keepAlive {
+		w.header.Set("Connection", "close")
+	} else if w.http10 {
+		w.header.Set("Connection", "keep-alive")
+	}
+
+	fmt.Fprintf(w.conn, "HTTP/1.1 %s\r\n", StatusLine(w.status))
+	for key, values := range w.header {
+		for _, value := range values {
+			fmt.Fprintf(w.conn, "%s: %s\r\n", DisplayKey(key), value)
+		}
+	}
+	_, w.err = w.conn.WriteString("\r\n")
+
+	body := w.buf.Bytes()
+	w.buf = bytes.Buffer{}
+	if len(body) > 0 {
+		if final {
+			if w.err == nil {
+				_, w.err = w.conn.Write(body)
+			}
+		} else {
+			w.writeBody(body)
+		}
+	}
+}
+
+func (w *Writer) writeBody(p []byte) {
+	if w.err != nil || len(p) == 0 {
+		return
+	}
+	if w.chunked {
+		if _, w.err = fmt.Fprintf(w.conn, "%x\r\n", len(p)); w.err != nil {
+			return
+		}
+		if _, w.err = w.conn.Write(p); w.err != nil {
+			return
+		}
+		_, w.err = w.conn.WriteString("\r\n")
+		return
+	}
+	_, w.err = w.conn.Write(p)
+}
+
+// Recorder guarda la respuesta en memoria para revisarla en las pruebas
+type Recorder struct {
+	Code      int
+	HeaderMap Header
+	Body      bytes.Buffer
+	Flushed   bool
+	wrote     bool
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{Code: 200, HeaderMap: make(Header)}
+}
+
+func (r *Recorder) Header() Header {
+	return r.HeaderMap
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.Code = status
+		r.wrote = true
+	}
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.WriteHeader(200)
+	return r.Body.Write(p)
+}
+
+func (r *Recorder) Flush() error {
+	r.WriteHeader(200)
+	r.Flushed = true
+	return nil
+}
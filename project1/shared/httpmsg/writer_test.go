@@ -0,0 +1,113 @@
+// writer_test.go
+package httpmsg
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func requestFor(t *testing.T, raw string) *Request {
+	req, err := read(raw, DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	return req
+}
+
+// TestWriter_ContentLength verifica que una respuesta corta use Content-Length
+func TestWriter_ContentLength(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, requestFor(t, "GET /ping HTTP/1.1\r\n\r\n"))
+	w.Header().Set("X-Request-ID", "7")
+	Text(w, 200, "pong")
+	if err := w.Finish(); err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+
+	resp, err := ReadResponse(bufio.NewReader(&out), DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if resp.StatusCode != 200 || string(resp.Body) != "pong" || resp.Header.Get("Content-Length") != "4" {
+		t.Errorf("Respuesta inesperada: %+v", resp)
+	}
+	if resp.Header.Get("X-Request-ID") != "7" || resp.Close || !w.KeepAlive() {
+		t.Errorf("Encabezados inesperados: %v", resp.Header)
+	}
+}
+
+// TestWriter_Chunked verifica que Flush cambie a Transfer-Encoding: chunked
+func TestWriter_Chunked(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, requestFor(t, "GET /loadtest HTTP/1.1\r\nConnection: close\r\n\r\n"))
+	w.WriteHeader(202)
+	w.Write([]byte("parte 1\n"))
+	w.Flush()
+	w.Write([]byte("parte 2\n"))
+	w.Finish()
+
+	resp, err := ReadResponse(bufio.NewReader(&out), DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if resp.StatusCode != 202 || resp.Header.Get("Transfer-Encoding") != "chunked" {
+		t.Errorf("Respuesta inesperada: %+v", resp)
+	}
+	if string(resp.Body) != "parte 1\nparte 2\n" || !resp.Close || w.KeepAlive() {
+		t.Errorf("Cuerpo o conexión inesperados: %q close=%v", resp.Body, resp.Close)
+	}
+}
+
+// TestWriter_HTTP10SinLongitud verifica que un cuerpo grande para HTTP/1.0 se delimite cerrando
+func TestWriter_HTTP10SinLongitud(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, requestFor(t, "GET /random HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"))
+	body := strings.Repeat("x", MaxBufferedBody+10)
+	Text(w, 200, body)
+	w.Finish()
+
+	if w.KeepAlive() {
+		t.Errorf("Una respuesta HTTP/1.0 sin longitud no puede mantener la conexión")
+	}
+	resp, err := ReadResponse(bufio.NewReader(&out), DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if len(resp.Body) != len(body) || resp.Header.Has("Content-Length") {
+		t.Errorf("Respuesta inesperada: %d bytes, encabezados %v", len(resp.Body), resp.Header)
+	}
+}
+
+// TestJSON verifica el helper de JSON con el Recorder
+func TestJSON(t *testing.T) {
+	rec := NewRecorder()
+	JSON(rec, 201, map[string]int{"total": 3})
+	if rec.Code != 201 || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Respuesta inesperada: %d %v", rec.Code, rec.HeaderMap)
+	}
+	if !strings.Contains(rec.Body.String(), `"total": 3`) {
+		t.Errorf("Cuerpo inesperado: %s", rec.Body.String())
+	}
+}
+
+// TestWriter_ConnectionClose verifica que el handler pueda pedir cerrar la conexión
+func TestWriter_ConnectionClose(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, requestFor(t, "GET /ping HTTP/1.1\r\n\r\n"))
+	w.Header().Set("Connection", "close")
+	Text(w, 503, "apagando")
+	w.Finish()
+
+	if w.KeepAlive() {
+		t.Errorf("La conexión debería cerrarse")
+	}
+	resp, err := ReadResponse(bufio.NewReader(&out), DefaultLimits)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if resp.StatusCode != 503 || !resp.Close {
+		t.Errorf("Respuesta inesperada: %+v", resp)
+	}
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capabilities es el documento que el worker envía a /suscribir al registrarse.
+// Con él el dispatcher sabe qué rutas atiende cada worker y cuánta carga aguanta.
+type Capabilities struct {
+	URL            string            `json:"url"`
+	Name           string            `json:"name"`
+	Version        string            `json:"version"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	MaxConcurrency int               `json:"max_concurrency"`
+	Routes         []Route           `json:"routes"`
+}
+
+// Registration es la respuesta de /suscribir y /heartbeat. Epoch identifica la instancia
+// del dispatcher: si cambia, el dispatcher se reinició y el worker se vuelve a registrar.
+type Registration struct {
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Epoch  string `json:"epoch"`
+}
+
+// Validate revisa los campos mínimos para registrar al worker
+func (c Capabilities) Validate() error {
+	if strings.TrimSpace(c.URL) == "" {
+		return badParam("Campo 'url' requerido")
+	}
+	if c.MaxConcurrency <= 0 {
+		return badParam("Campo 'max_concurrency' debe ser mayor que 0")
+	}
+	for _, r := range c.Routes {
+		if r.Path == "" || len(r.Methods) == 0 {
+			return badParam("Ruta inválida en capacidades: %+v", r)
+		}
+	}
+	return nil
+}
+
+// ParseLabels convierte "zona=a,disco=ssd" en un mapa. Las entradas vacías se ignoran.
+func ParseLabels(raw string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("etiqueta inválida %q, se espera llave=valor", pair)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
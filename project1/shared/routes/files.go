@@ -0,0 +1,13 @@
+package routes
+
+import "time"
+
+// FileInfo describe un archivo de files/ del worker. Lo retornan /stat y /listfiles;
+// el dispatcher agrega Replicas al unir los listados de varios workers.
+type FileInfo struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	SHA256   string    `json:"sha256,omitempty"`   // Solo en /stat
+	Replicas []string  `json:"replicas,omitempty"` // Workers que tienen el archivo, solo en el dispatcher
+}
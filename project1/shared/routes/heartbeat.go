@@ -0,0 +1,48 @@
+package routes
+
+import "time"
+
+// DefaultHeartbeatInterval es cada cuánto el worker envía su heartbeat al dispatcher
+const DefaultHeartbeatInterval = 2 * time.Second
+
+// PoolStats es la carga de un pool de workers del servidor
+type PoolStats struct {
+	Workers int `json:"workers"` // Tamaño del pool
+	Min     int `json:"min"`     // Mínimo de workers, ver /admin/pools
+	Max     int `json:"max"`     // Máximo de workers
+	Busy    int `json:"busy"`    // Workers atendiendo una solicitud
+	Queued  int `json:"queued"`  // Solicitudes esperando un worker libre
+
+	Priorities map[string]ClassStats `json:"priorities,omitempty"` // Cola de cada clase de prioridad
+}
+
+// ClassStats es la cola de una clase de prioridad dentro de un pool
+type ClassStats struct {
+	Queued    int   `json:"queued"`
+	Served    int   `json:"served"` // Solicitudes que ya tomó un worker
+	Aged      int   `json:"aged"`   // Servidas con una clase mayor por llevar tiempo esperando
+	AvgWaitMs int64 `json:"avg_wait_ms"`
+}
+
+// Heartbeat es el documento que el worker envía a /heartbeat. Trae las mismas
+// métricas que /status para que el dispatcher no tenga que consultarlas.
+type Heartbeat struct {
+	URL           string               `json:"url"`
+	Seq           uint64               `json:"seq"`         // Aumenta en cada envío
+	IntervalMs    int64                `json:"interval_ms"` // Cada cuánto se envía, define los umbrales
+	UptimeSeconds int64                `json:"uptime_seconds"`
+	TotalRequests int                  `json:"total_requests"`
+	InFlight      int64                `json:"in_flight"`
+	Busy          int                  `json:"busy"`   // Suma de Busy de todos los pools
+	Queued        int                  `json:"queued"` // Suma de Queued de todos los pools
+	Draining      bool                 `json:"draining"`
+	Pools         map[string]PoolStats `json:"pools"`
+}
+
+// Interval retorna el intervalo declarado o el valor por defecto si no viene
+func (h Heartbeat) Interval() time.Duration {
+	if h.IntervalMs <= 0 {
+		return DefaultHeartbeatInterval
+	}
+	return time.Duration(h.IntervalMs) * time.Millisecond
+}
@@ -0,0 +1,8 @@
+package routes
+
+// MapRoute es la ruta del worker que procesa un chunk de un trabajo map-reduce
+const MapRoute = "/map"
+
+// MapResult es la respuesta de /map: un valor por llave, por ejemplo {"words": 120}
+// o la frecuencia de cada palabra. El dispatcher combina los de todos los chunks.
+type MapResult map[string]float64
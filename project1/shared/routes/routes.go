@@ -0,0 +1,238 @@
+// Package routes describe las rutas que atiende un worker: métodos, parámetros
+// y tamaño del pool. El worker arma su tabla con estas descripciones y la publica
+// en /routes para que el dispatcher valide las solicitudes antes de reenviarlas.
+// También define los documentos que el worker envía al dispatcher al registrarse
+// (Capabilities) y periódicamente mientras está vivo (Heartbeat), y la descripción
+// de los archivos que guarda (FileInfo).
+package routes
+
+import (
+	"fmt"
+	"http-shared/httpmsg"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tipos de parámetro soportados
+const (
+	TypeInt    = "int"
+	TypeString = "string"
+)
+
+// Param describe un parámetro de la query
+type Param struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	Min      *int64 `json:"min,omitempty"` // Solo para TypeInt
+	Max      *int64 `json:"max,omitempty"` // Solo para TypeInt
+}
+
+// Int crea un parámetro entero sin rango
+func Int(name string, required bool) Param {
+	return Param{Name: name, Type: TypeInt, Required: required}
+}
+
+// IntMin crea un parámetro entero con valor mínimo
+func IntMin(name string, required bool, min int64) Param {
+	p := Int(name, required)
+	p.Min = &min
+	return p
+}
+
+// IntRange crea un parámetro entero con valor mínimo y máximo
+func IntRange(name string, required bool, min, max int64) Param {
+	p := IntMin(name, required, min)
+	p.Max = &max
+	return p
+}
+
+// String crea un parámetro de texto
+func String(name string, required bool) Param {
+	return Param{Name: name, Type: TypeString, Required: required}
+}
+
+// Check valida el valor del parámetro. present indica si apareció en la query.
+func (p Param) Check(value string, present bool) error {
+	if !present {
+		if p.Required {
+			return badParam("Parámetro '%s' requerido", p.Name)
+		}
+		return nil
+	}
+	if p.Type != TypeInt {
+		return nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return badParam("Parámetro '%s' debe ser un número entero", p.Name)
+	}
+	switch {
+	case p.Min != nil && p.Max != nil && (n < *p.Min || n > *p.Max):
+		return badParam("Parámetro '%s' debe estar entre %d y %d", p.Name, *p.Min, *p.Max)
+	case p.Min != nil && n < *p.Min:
+		return badParam("Parámetro '%s' debe ser mayor o igual a %d", p.Name, *p.Min)
+	case p.Max != nil && n > *p.Max:
+		return badParam("Parámetro '%s' debe ser menor o igual a %d", p.Name, *p.Max)
+	}
+	return nil
+}
+
+// placeholder es lo que se muestra en /help en lugar del valor
+func (p Param) placeholder() string {
+	if p.Type != TypeInt {
+		return "texto"
+	}
+	switch {
+	case p.Min != nil && p.Max != nil:
+		return fmt.Sprintf("%d..%d", *p.Min, *p.Max)
+	case p.Min != nil:
+		return fmt.Sprintf("%d..", *p.Min)
+	case p.Max != nil:
+		return fmt.Sprintf("..%d", *p.Max)
+	}
+	return "N"
+}
+
+func badParam(format string, args ...interface{}) error {
+	return &httpmsg.Error{Status: 400, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Route describe una ruta. PoolSize 0 indica que se atiende en la misma
+// goroutine de la conexión, sin pasar por un pool de workers. Idempotent indica
+// que repetir la solicitud no cambia el resultado, así que el dispatcher puede
+// reenviarla a otro worker si el primero falla.
+type Route struct {
+	Path        string   `json:"path"`
+	Methods     []string `json:"methods"`
+	Params      []Param  `json:"params,omitempty"`
+	PoolSize    int      `json:"pool_size"`
+	Idempotent  bool     `json:"idempotent,omitempty"`
+	Description string   `json:"description"`
+}
+
+// Allows indica si el método está permitido en la ruta
+func (r Route) Allows(method string) bool {
+	for _, m := range r.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow retorna el valor del encabezado Allow para una respuesta 405
+func (r Route) Allow() string {
+	return strings.Join(r.Methods, ", ")
+}
+
+// Validate revisa los parámetros de la query contra los declarados
+func (r Route) Validate(query httpmsg.Values) error {
+	for _, p := range r.Params {
+		if err := p.Check(query.Get(p.Name), query.Has(p.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Usage arma el ejemplo de uso que se muestra en /help, por ejemplo
+// "/random?count=1..&min=N&max=N". Los parámetros opcionales van entre corchetes.
+func (r Route) Usage() string {
+	var b strings.Builder
+	b.WriteString(r.Path)
+	for i, p := range r.Params {
+		sep := "&"
+		if i == 0 {
+			sep = "?"
+		}
+		if p.Required {
+			fmt.Fprintf(&b, "%s%s=%s", sep, p.Name, p.placeholder())
+		} else {
+			fmt.Fprintf(&b, "[%s%s=%s]", sep, p.Name, p.placeholder())
+		}
+	}
+	return b.String()
+}
+
+// Table agrupa las rutas por path. Una tabla nil se comporta como vacía.
+type Table struct {
+	list   []Route
+	byPath map[string]int
+}
+
+// NewTable crea la tabla. Si un path se repite gana la última definición.
+func NewTable(list []Route) *Table {
+	t := &Table{byPath: make(map[string]int, len(list))}
+	for _, r := range list {
+		if i, ok := t.byPath[r.Path]; ok {
+			t.list[i] = r
+			continue
+		}
+		t.byPath[r.Path] = len(t.list)
+		t.list = append(t.list, r)
+	}
+	return t
+}
+
+// Lookup busca la ruta por path
+func (t *Table) Lookup(path string) (Route, bool) {
+	if t == nil {
+		return Route{}, false
+	}
+	i, ok := t.byPath[path]
+	if !ok {
+		return Route{}, false
+	}
+	return t.list[i], true
+}
+
+// Routes retorna las rutas en el orden en que se declararon
+func (t *Table) Routes() []Route {
+	if t == nil {
+		return nil
+	}
+	return append([]Route(nil), t.list...)
+}
+
+// Len retorna la cantidad de rutas
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.list)
+}
+
+// Check resuelve la ruta y valida método y parámetros. Los errores son
+// *httpmsg.Error con 404, 405 o 400 según corresponda.
+func (t *Table) Check(method, path string, query httpmsg.Values) (Route, error) {
+	r, ok := t.Lookup(path)
+	if !ok {
+		return Route{}, httpmsg.ErrNotFound
+	}
+	if !r.Allows(method) {
+		return r, &httpmsg.Error{Status: 405, Msg: fmt.Sprintf("Método %s no permitido para %s, use %s", method, path, r.Allow())}
+	}
+	if err := r.Validate(query); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// HelpText arma el texto de /help a partir de las rutas
+func HelpText(list []Route) string {
+	sorted := append([]Route(nil), list...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	var b strings.Builder
+	b.WriteString("Rutas disponibles:\n")
+	for _, r := range sorted {
+		fmt.Fprintf(&b, "  %-6s %s\n", strings.Join(r.Methods, ","), r.Usage())
+		if r.Description != "" {
+			fmt.Fprintf(&b, "         %s\n", r.Description)
+		}
+	}
+	return b.String()
+}
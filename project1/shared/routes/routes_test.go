@@ -0,0 +1,123 @@
+// routes_test.go
+package routes
+
+import (
+	"errors"
+	"http-shared/httpmsg"
+	"strings"
+	"testing"
+)
+
+func testTable() *Table {
+	return NewTable([]Route{
+		{Path: "/fibonacci", Methods: []string{"GET"}, Params: []Param{IntMin("num", true, 0)}, PoolSize: 3},
+		{Path: "/random", Methods: []string{"GET"}, Params: []Param{IntMin("count", true, 1), Int("min", true), Int("max", true)}, PoolSize: 2},
+		{Path: "/countchunk", Methods: []string{"POST"}, PoolSize: 3},
+		{Path: "/sleep", Methods: []string{"GET"}, Params: []Param{IntRange("seconds", false, 1, 60)}, PoolSize: 3},
+	})
+}
+
+// TestTable_Check verifica los errores 404, 405 y 400 que genera la tabla
+func TestTable_Check(t *testing.T) {
+	table := testTable()
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int // 0 si la solicitud es válida
+		msg    string
+	}{
+		{"Válida", "GET", "/fibonacci?num=10", 0, ""},
+		{"RutaInexistente", "GET", "/noexiste", 404, "Ruta no encontrada"},
+		{"MetodoNoPermitido", "GET", "/countchunk", 405, "use POST"},
+		{"FaltaRequerido", "GET", "/random?min=1&max=5", 400, "'count' requerido"},
+		{"NoEsEntero", "GET", "/fibonacci?num=abc", 400, "número entero"},
+		{"MenorAlMinimo", "GET", "/fibonacci?num=-1", 400, "mayor o igual a 0"},
+		{"FueraDeRango", "GET", "/sleep?seconds=61", 400, "entre 1 y 60"},
+		{"OpcionalAusente", "GET", "/sleep", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, query, err := httpmsg.ParseRoute(tt.target)
+			if err != nil {
+				t.Fatalf("Error inesperado: %v", err)
+			}
+			_, err = table.Check(tt.method, path, query)
+			if tt.status == 0 {
+				if err != nil {
+					t.Errorf("Error inesperado: %v", err)
+				}
+				return
+			}
+			if httpmsg.StatusOf(err) != tt.status || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("Esperado %d %q, obtenido %v", tt.status, tt.msg, err)
+			}
+		})
+	}
+}
+
+// TestNewTable_Repetida verifica que la última definición de un path reemplace a la anterior
+func TestNewTable_Repetida(t *testing.T) {
+	table := NewTable(append(testTable().Routes(), Route{Path: "/fibonacci", Methods: []string{"GET", "POST"}}))
+	if table.Len() != 4 {
+		t.Fatalf("Esperadas 4 rutas, obtenidas %d", table.Len())
+	}
+	r, _ := table.Lookup("/fibonacci")
+	if !r.Allows("POST") || r.Allow() != "GET, POST" {
+		t.Errorf("No se reemplazó la ruta: %+v", r)
+	}
+}
+
+// TestTableNil verifica que una tabla nil se comporte como vacía
+func TestTableNil(t *testing.T) {
+	var table *Table
+	if _, err := table.Check("GET", "/ping", nil); !errors.Is(err, httpmsg.ErrNotFound) {
+		t.Errorf("Esperado ErrNotFound, obtenido %v", err)
+	}
+	if table.Len() != 0 || table.Routes() != nil {
+		t.Errorf("Una tabla nil debe estar vacía")
+	}
+}
+
+// TestHelpText verifica el texto generado para /help
+func TestHelpText(t *testing.T) {
+	text := HelpText(testTable().Routes())
+	for _, want := range []string{
+		"Rutas disponibles:",
+		"GET    /fibonacci?num=0..",
+		"POST   /countchunk",
+		"/random?count=1..&min=N&max=N",
+		"/sleep[?seconds=1..60]",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Falta %q en:\n%s", want, text)
+		}
+	}
+}
+
+// TestCapabilities_Validate verifica los campos mínimos del registro
+func TestCapabilities_Validate(t *testing.T) {
+	caps := Capabilities{URL: "worker1:8080", MaxConcurrency: 4, Routes: testTable().Routes()}
+	if err := caps.Validate(); err != nil {
+		t.Errorf("Error inesperado: %v", err)
+	}
+	caps.MaxConcurrency = 0
+	if httpmsg.StatusOf(caps.Validate()) != 400 {
+		t.Errorf("Se esperaba error por max_concurrency")
+	}
+	if err := (Capabilities{MaxConcurrency: 1}).Validate(); err == nil {
+		t.Errorf("Se esperaba error por url faltante")
+	}
+}
+
+// TestParseLabels verifica el formato llave=valor de las etiquetas
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels("files=true, zona = a,,")
+	if err != nil || len(labels) != 2 || labels["files"] != "true" || labels["zona"] != "a" {
+		t.Errorf("Etiquetas inesperadas: %v %v", labels, err)
+	}
+	if _, err := ParseLabels("files"); err == nil {
+		t.Errorf("Se esperaba error por etiqueta sin '='")
+	}
+}